@@ -0,0 +1,234 @@
+// Package backend wraps the package managers that rpm-get hands RPM packages off to.
+package backend
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "slices"
+    "strings"
+
+    // third-party imports
+    "github.com/samber/lo"
+)
+
+// ErrUnsupported is returned when the package manager can't perform the requested operation.
+var ErrUnsupported = errors.New("operation not supported by this package manager")
+
+// ErrNotInstalled is returned when querying the version of a package that isn't installed.
+var ErrNotInstalled = errors.New("package is not installed")
+
+// OS_RELEASE_FILE is the file used to identify the host distribution.
+const OS_RELEASE_FILE string = "/etc/os-release"
+
+// Backend is a package manager that installs, upgrades and removes RPM packages.
+type Backend interface {
+    // Name returns the name of the package manager, e.g. `dnf5`.
+    Name() string
+    // ReposDir returns the directory in which the package manager looks for repo files.
+    ReposDir() string
+    // Install installs local RPM files and packages from the enabled repositories
+    // in a single transaction.
+    Install(files []string, pkgs []string) error
+    // Upgrade upgrades the given packages from the enabled repositories.
+    Upgrade(pkgs ...string) error
+    // Reinstall reinstalls the given packages, which may be package names or local RPM files.
+    Reinstall(pkgs ...string) error
+    // Remove removes the given packages.
+    Remove(pkgs ...string) error
+    // InstalledVersion returns the installed `epoch:version-release` of the given package.
+    // `ErrNotInstalled` is returned if it's not installed.
+    InstalledVersion(pkg string) (string, error)
+    // AddRepo saves the given repo file to the repos directory and returns its file name.
+    // The keys of the repo must already be in the rpm keyring, see `RepoKeys`. When set,
+    // `gpgKeyUrl` is the key of the repo in place of the ones named by the repo file.
+    AddRepo(name string, content []byte, gpgKeyUrl string) (string, error)
+    // AddCoprRepo enables the given Fedora COPR repo and returns the name of its repo file.
+    AddCoprRepo(username string, project string) (string, error)
+    // RemoveRepo removes the given repo file from the repos directory.
+    RemoveRepo(name string) error
+}
+
+// OsRelease holds the key/value pairs of `/etc/os-release`.
+type OsRelease map[string]string
+
+// ReadOsRelease parses the given os-release file.
+func ReadOsRelease(filePath string) (OsRelease, error) {
+    file, err := os.Open(filePath)
+    if err != nil { return nil, fmt.Errorf("Failed to read %s: %w", filePath, err) }
+    //nolint:errcheck
+    defer file.Close()
+
+    result := OsRelease {}
+    scanner := bufio.NewScanner(file)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "#") { continue }
+
+        key, value, ok := strings.Cut(line, "=")
+        if !ok { continue }
+        result[key] = strings.Trim(value, `"'`)
+    }
+
+    return result, scanner.Err()
+}
+
+// IDs returns the distribution `ID` followed by every `ID_LIKE` entry.
+func (o OsRelease) IDs() []string {
+    return append([]string { o["ID"] }, strings.Fields(o["ID_LIKE"])...)
+}
+
+// IsSuse reports whether the distribution is openSUSE/SUSE based.
+func (o OsRelease) IsSuse() bool {
+    return slices.ContainsFunc(o.IDs(), func(id string) bool { return strings.Contains(id, "suse") })
+}
+
+// Detect picks the package manager of the host, based on `/etc/os-release`
+// and the package managers found in the `PATH`.
+func Detect() (Backend, error) { return detect(OS_RELEASE_FILE) }
+
+// detect picks the package manager of the host, based on the given os-release file
+// and the package managers found in the `PATH`.
+func detect(osReleaseFile string) (Backend, error) {
+    osRelease, _ := ReadOsRelease(osReleaseFile)
+
+    if osRelease.IsSuse() && which("zypper") != "" { return NewZypper(), nil }
+
+    if which("dnf5") != "" { return NewDnf5(), nil }
+    if path := which("dnf"); path != "" {
+        // On Fedora 41 and newer `dnf` is a symlink to `dnf5`.
+        if resolved, err := filepath.EvalSymlinks(path); err == nil && filepath.Base(resolved) == "dnf5" {
+            return NewDnf5(), nil
+        }
+        return NewDnf(), nil
+    }
+    if which("yum") != "" { return NewYum(), nil }
+    if which("zypper") != "" { return NewZypper(), nil }
+    if which("rpm") != "" { return NewRpm(), nil }
+
+    return nil, errors.New("No supported package manager was found")
+}
+
+// which looks for the given command in the PATH. An empty string is returned if it's not found.
+func which(cmd string) string {
+    result, err := exec.LookPath(cmd)
+    if err != nil { return "" }
+    return result
+}
+
+// run runs the given command, prefixed with sudo when not running as root.
+func run(cmd string, args ...string) error {
+    if os.Geteuid() != 0 && which("sudo") != "" {
+        args = append([]string { cmd }, args...)
+        cmd = "sudo"
+    }
+
+    command := exec.Command(cmd, args...)
+    command.Stdin, command.Stdout, command.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+    if err := command.Run(); err != nil {
+        return fmt.Errorf("Command `%s %s` failed: %w", cmd, strings.Join(args, " "), err)
+    }
+
+    return nil
+}
+
+// queryVersion returns the installed `epoch:version-release` of the given package using rpm.
+func queryVersion(pkg string) (string, error) {
+    command := exec.Command("rpm", "-q", "--qf", "%{EPOCHNUM}:%{VERSION}-%{RELEASE}\n", pkg)
+    // The "is not installed" message must not be translated.
+    command.Env = append(os.Environ(), "LC_ALL=C")
+    out, err := command.Output()
+
+    exitErr := &exec.ExitError {}
+    if err != nil && !errors.As(err, &exitErr) { return "", fmt.Errorf("Failed to query %s: %w", pkg, err) }
+
+    return parseQueryVersion(pkg, string(out), string(exitErr.Stderr), err != nil)
+}
+
+// parseQueryVersion parses the output of `rpm -q` for the given package. `ErrNotInstalled` is only
+// returned when rpm reports that the package is not installed, so that other failures, such as
+// a locked rpmdb, aren't mistaken for it. `failed` reports whether rpm exited with an error.
+func parseQueryVersion(pkg string, stdout string, stderr string, failed bool) (string, error) {
+    versions := []string {}
+    for _, line := range strings.Split(stdout, "\n") {
+        line = strings.TrimSpace(line)
+        switch {
+        case line == "":
+            continue
+        case line == "package " + pkg + " is not installed":
+            return "", ErrNotInstalled
+        default:
+            versions = append(versions, line)
+        }
+    }
+
+    if failed || len(versions) == 0 {
+        msg := lo.Ternary(strings.TrimSpace(stderr) != "", strings.TrimSpace(stderr), "no version was printed")
+        return "", fmt.Errorf("Failed to query %s: %s", pkg, msg)
+    }
+
+    // Several versions may be installed at once (e.g. kernels), the newest one is listed last.
+    return versions[len(versions) - 1], nil
+}
+
+// QueryFile returns the name and `epoch:version-release` of the given RPM file.
+func QueryFile(file string) (string, string, error) {
+    command := exec.Command("rpm", "-qp", "--qf", "%{NAME} %{EPOCHNUM}:%{VERSION}-%{RELEASE}", file)
+    out, err := command.Output()
+    if err != nil { return "", "", fmt.Errorf("Failed to query %s: %w", file, err) }
+
+    name, evr, ok := strings.Cut(strings.TrimSpace(string(out)), " ")
+    if !ok { return "", "", fmt.Errorf("Failed to query %s: unexpected output %q", file, out) }
+
+    return name, evr, nil
+}
+
+// repoFileName returns the name under which a repo file downloaded as `name` is saved.
+// The query string or fragment of its URL is dropped, and a `.repo` suffix is added when
+// missing, as package managers ignore the files without it.
+func repoFileName(name string) string {
+    name, _, _ = strings.Cut(name, "?")
+    name, _, _ = strings.Cut(name, "#")
+    if !strings.HasSuffix(name, ".repo") { name += ".repo" }
+    return name
+}
+
+// writeRepoFile atomically writes the given repo file to the given directory.
+func writeRepoFile(dir string, name string, content []byte) (string, error) {
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return "", fmt.Errorf("Unable to create %s: %w", dir, err)
+    }
+
+    if filepath.Base(name) != name || name == ".repo" {
+        return "", fmt.Errorf("Invalid repo file name: %q", name)
+    }
+
+    filePath := filepath.Join(dir, name)
+    tmpFilePath := filePath + ".tmp"
+    if err := os.WriteFile(tmpFilePath, content, 0644); err != nil {
+        return "", fmt.Errorf("Failed to write %s: %w", tmpFilePath, err)
+    }
+    if err := os.Rename(tmpFilePath, filePath); err != nil {
+        _ = os.Remove(tmpFilePath)
+        return "", fmt.Errorf("Failed to write %s: %w", filePath, err)
+    }
+
+    return name, nil
+}
+
+// removeRepoFile removes the given repo file from the given directory.
+func removeRepoFile(dir string, name string) error {
+    if name == "" || filepath.Base(name) != name {
+        return fmt.Errorf("Invalid repo file name: %q", name)
+    }
+
+    if err := os.Remove(filepath.Join(dir, name)); err != nil {
+        return fmt.Errorf("Failed to remove repo %s: %w", name, err)
+    }
+
+    return nil
+}
@@ -0,0 +1,158 @@
+package backend
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+    "testing"
+)
+
+// writeOsRelease writes an os-release file with the given content to a temporary directory.
+func writeOsRelease(t *testing.T, content string) string {
+    t.Helper()
+    filePath := filepath.Join(t.TempDir(), "os-release")
+    if err := os.WriteFile(filePath, []byte(content), 0644); err != nil { t.Fatal(err) }
+    return filePath
+}
+
+// fakePath replaces the `PATH` with a directory holding the given commands. Commands written
+// as `name=target` are symlinks to `target`.
+func fakePath(t *testing.T, cmds ...string) {
+    t.Helper()
+    dir := t.TempDir()
+
+    for _, cmd := range cmds {
+        name, target, isLink := strings.Cut(cmd, "=")
+        filePath := filepath.Join(dir, name)
+
+        if isLink {
+            if err := os.Symlink(target, filePath); err != nil { t.Fatal(err) }
+            continue
+        }
+        if err := os.WriteFile(filePath, []byte("#!/bin/sh\n"), 0755); err != nil { t.Fatal(err) }
+    }
+
+    t.Setenv("PATH", dir)
+}
+
+func TestReadOsRelease(t *testing.T) {
+    filePath := writeOsRelease(t, `# comment
+NAME="openSUSE Tumbleweed"
+ID="opensuse-tumbleweed"
+ID_LIKE='opensuse suse'
+
+VERSION_ID=20250101
+INVALID LINE
+`)
+
+    osRelease, err := ReadOsRelease(filePath)
+    if err != nil { t.Fatal(err) }
+
+    tests := map[string]string {
+        "NAME": "openSUSE Tumbleweed",
+        "ID": "opensuse-tumbleweed",
+        "ID_LIKE": "opensuse suse",
+        "VERSION_ID": "20250101",
+    }
+    for key, want := range tests {
+        if osRelease[key] != want { t.Errorf("%s = %q, want %q", key, osRelease[key], want) }
+    }
+    if len(osRelease) != len(tests) { t.Errorf("ReadOsRelease() = %v, want %d keys", osRelease, len(tests)) }
+
+    if ids := osRelease.IDs(); !slices.Equal(ids, []string { "opensuse-tumbleweed", "opensuse", "suse" }) {
+        t.Errorf("IDs() = %v", ids)
+    }
+    if !osRelease.IsSuse() { t.Error("IsSuse() = false, want true") }
+    if (OsRelease { "ID": "fedora" }).IsSuse() { t.Error("IsSuse() = true for fedora") }
+
+    if _, err := ReadOsRelease(filePath + ".missing"); err == nil { t.Error("ReadOsRelease() read a missing file") }
+}
+
+func TestDetect(t *testing.T) {
+    fedora := "ID=fedora\n"
+    suse := "ID=opensuse-leap\nID_LIKE=\"suse opensuse\"\n"
+
+    tests := []struct {
+        osRelease string
+        cmds []string
+        want string
+    }{
+        { fedora, []string { "dnf5", "dnf", "rpm" }, "dnf5" },
+        // On Fedora 41 and newer `dnf` is a symlink to `dnf5`.
+        { fedora, []string { "dnf5.bin", "dnf5=dnf5.bin", "dnf=dnf5" }, "dnf5" },
+        { fedora, []string { "dnf", "yum", "rpm" }, "dnf" },
+        { "ID=centos\nID_LIKE=\"rhel fedora\"\n", []string { "yum", "rpm" }, "yum" },
+        { suse, []string { "zypper", "dnf", "rpm" }, "zypper" },
+        // Without zypper, openSUSE falls back to whatever is available.
+        { suse, []string { "rpm" }, "rpm" },
+        // A missing os-release file only means that the distribution isn't known.
+        { "", []string { "zypper", "rpm" }, "zypper" },
+    }
+
+    for _, test := range tests {
+        osReleaseFile := filepath.Join(t.TempDir(), "missing")
+        if test.osRelease != "" { osReleaseFile = writeOsRelease(t, test.osRelease) }
+        fakePath(t, test.cmds...)
+
+        got, err := detect(osReleaseFile)
+        if err != nil {
+            t.Errorf("detect() with %v failed: %v", test.cmds, err)
+        } else if got.Name() != test.want {
+            t.Errorf("detect() with %v = %s, want %s", test.cmds, got.Name(), test.want)
+        }
+    }
+
+    fakePath(t)
+    if _, err := detect(writeOsRelease(t, fedora)); err == nil { t.Error("detect() succeeded without a package manager") }
+}
+
+func TestRepoFileName(t *testing.T) {
+    tests := map[string]string {
+        "app.repo": "app.repo",
+        "app.repo?token=abc": "app.repo",
+        "app.repo#main": "app.repo",
+        "config.repo.txt": "config.repo.txt.repo",
+        "repo": "repo.repo",
+    }
+    for name, want := range tests {
+        if got := repoFileName(name); got != want { t.Errorf("repoFileName(%q) = %q, want %q", name, got, want) }
+    }
+
+    if _, err := writeRepoFile(t.TempDir(), repoFileName("?x=1"), nil); err == nil {
+        t.Error("writeRepoFile() accepted a repo file without a name")
+    }
+}
+
+func TestParseQueryVersion(t *testing.T) {
+    tests := []struct {
+        name string
+        stdout string
+        stderr string
+        failed bool
+        version string
+        notInstalled bool
+    }{
+        { "installed", "0:1.2.0-1.fc40\n", "", false, "0:1.2.0-1.fc40", false },
+        { "several versions", "0:6.8.9-300.fc40\n0:6.9.7-200.fc40\n", "", false, "0:6.9.7-200.fc40", false },
+        { "not installed", "package app is not installed\n", "", true, "", true },
+        { "locked rpmdb", "", "error: rpmdb: Lock table is out of available locker entries\n", true, "", false },
+        { "another package", "package other is not installed\n", "", true, "", false },
+        { "no output", "", "", false, "", false },
+    }
+
+    for _, test := range tests {
+        version, err := parseQueryVersion("app", test.stdout, test.stderr, test.failed)
+        switch {
+        case version != test.version:
+            t.Errorf("%s: parseQueryVersion() = %q, want %q", test.name, version, test.version)
+        case test.notInstalled && !errors.Is(err, ErrNotInstalled):
+            t.Errorf("%s: parseQueryVersion() = %v, want %v", test.name, err, ErrNotInstalled)
+        case !test.notInstalled && test.version == "" && (err == nil || errors.Is(err, ErrNotInstalled)):
+            t.Errorf("%s: parseQueryVersion() = %v, want another error", test.name, err)
+        case test.version != "" && err != nil:
+            t.Errorf("%s: parseQueryVersion() failed: %v", test.name, err)
+        }
+    }
+}
@@ -0,0 +1,72 @@
+package backend
+
+import (
+    "fmt"
+)
+
+// YUM_REPOS_DIR is the directory where dnf and yum look for repo files.
+const YUM_REPOS_DIR string = "/etc/yum.repos.d"
+
+// dnf implements `Backend` for dnf4, dnf5 and yum, which share most of their command line.
+type dnf struct {
+    // Name of the package manager
+    name string
+    // Command used to upgrade packages
+    upgradeVerb string
+    // Format of the repo file names written by `copr enable`
+    coprRepoFormat string
+}
+
+// NewDnf returns the backend for dnf4 (Fedora 40 and older, RHEL 8 and newer).
+func NewDnf() Backend {
+    return &dnf { name: "dnf", upgradeVerb: "upgrade", coprRepoFormat: "_copr:copr.fedorainfracloud.org:%s:%s.repo" }
+}
+
+// NewDnf5 returns the backend for dnf5 (Fedora 41 and newer).
+func NewDnf5() Backend {
+    return &dnf { name: "dnf5", upgradeVerb: "upgrade", coprRepoFormat: "_copr:copr.fedorainfracloud.org:%s:%s.repo" }
+}
+
+// NewYum returns the backend for yum (RHEL/CentOS 7).
+func NewYum() Backend {
+    return &dnf { name: "yum", upgradeVerb: "update", coprRepoFormat: "_copr_%s-%s.repo" }
+}
+
+func (d *dnf) Name() string { return d.name }
+
+func (d *dnf) ReposDir() string { return YUM_REPOS_DIR }
+
+func (d *dnf) Install(files []string, pkgs []string) error {
+    args := append([]string { "install", "-y" }, files...)
+    return run(d.name, append(args, pkgs...)...)
+}
+
+func (d *dnf) Upgrade(pkgs ...string) error {
+    args := []string { d.upgradeVerb, "-y" }
+    if d.name != "yum" { args = append(args, "--refresh") }
+    return run(d.name, append(args, pkgs...)...)
+}
+
+func (d *dnf) Reinstall(pkgs ...string) error {
+    return run(d.name, append([]string { "reinstall", "-y" }, pkgs...)...)
+}
+
+func (d *dnf) Remove(pkgs ...string) error {
+    return run(d.name, append([]string { "remove", "-y" }, pkgs...)...)
+}
+
+func (d *dnf) InstalledVersion(pkg string) (string, error) { return queryVersion(pkg) }
+
+func (d *dnf) AddRepo(name string, content []byte, _ string) (string, error) {
+    return writeRepoFile(d.ReposDir(), repoFileName(name), content)
+}
+
+func (d *dnf) AddCoprRepo(username string, project string) (string, error) {
+    if err := run(d.name, "copr", "enable", "-y", username + "/" + project); err != nil {
+        return "", err
+    }
+
+    return fmt.Sprintf(d.coprRepoFormat, username, project), nil
+}
+
+func (d *dnf) RemoveRepo(name string) error { return removeRepoFile(d.ReposDir(), name) }
@@ -0,0 +1,237 @@
+package backend
+
+import (
+    "bytes"
+    "crypto/sha1"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/binary"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "os/exec"
+    "regexp"
+    "strings"
+
+    // third-party imports
+    "github.com/samber/lo"
+)
+
+var (
+    // ErrUnsigned is returned by `CheckSignature` for RPMs without a header signature.
+    ErrUnsigned = errors.New("the RPM has no header signature")
+    // ErrUnknownKey is returned by `CheckSignature` for RPMs signed with a key missing from the rpm keyring.
+    ErrUnknownKey = errors.New("the RPM is signed with a key missing from the rpm keyring")
+    // ErrBadSignature is returned by `CheckSignature` for RPMs whose signature or digests don't match.
+    ErrBadSignature = errors.New("the RPM signature is invalid")
+)
+
+// publicKeyTag is the OpenPGP packet tag of primary public keys.
+const publicKeyTag byte = 6
+
+// ImportKey imports the GPG key at the given URL or path into the rpm keyring.
+func ImportKey(keyUrl string) error { return run("rpm", "--import", keyUrl) }
+
+// RemoveKey removes the GPG key with the given fingerprint from the rpm keyring.
+func RemoveKey(fingerprint string) error {
+    name := installedKeyPkg(fingerprint)
+    if name == "" { return fmt.Errorf("The key %s is not in the rpm keyring", fingerprint) }
+    return run("rpm", "-e", "--allmatches", name)
+}
+
+// KeyInstalled reports whether the GPG key with the given fingerprint is in the rpm keyring.
+func KeyInstalled(fingerprint string) bool { return installedKeyPkg(fingerprint) != "" }
+
+// installedKeyPkg returns the name of the `gpg-pubkey` package rpm stores the given key as,
+// or an empty string if it's not in the rpm keyring. rpm 6 names it after the whole fingerprint,
+// while older versions name it after the last 8 hex digits of the key ID.
+func installedKeyPkg(fingerprint string) string {
+    fingerprint = strings.ToLower(fingerprint)
+
+    // The key ID is the end of v4 fingerprints (SHA-1), and the start of v5/v6 fingerprints (SHA-256).
+    keyId := fingerprint
+    if len(fingerprint) == 64 { keyId = fingerprint[:16] }
+    if len(keyId) > 8 { keyId = keyId[len(keyId) - 8:] }
+
+    for _, name := range []string { "gpg-pubkey-" + fingerprint, "gpg-pubkey-" + keyId } {
+        if exec.Command("rpm", "-q", name).Run() == nil { return name }
+    }
+
+    return ""
+}
+
+// CheckSignature verifies the header signature of the given RPM file against the rpm keyring,
+// and returns the ID of the key it was signed with. `ErrUnsigned` is returned for unsigned RPMs,
+// `ErrUnknownKey` for RPMs signed with a key that isn't in the rpm keyring, and `ErrBadSignature`
+// for RPMs that were tampered with.
+func CheckSignature(file string) (string, error) {
+    out, err := exec.Command("rpm", "--checksig", "--verbose", file).CombinedOutput()
+
+    exitErr := &exec.ExitError {}
+    if err != nil && !errors.As(err, &exitErr) {
+        return "", fmt.Errorf("Failed to check the signature of %s: %w", file, err)
+    }
+
+    return parseChecksig(string(out), err != nil)
+}
+
+// signatureRegex matches the signature lines of `rpm --checksig --verbose`, capturing the key ID
+// and the result. rpm 4 prints `Header V4 RSA/SHA256 Signature, key ID 12345678: OK`, while rpm 6
+// prints `Header OpenPGP V4 EdDSA/SHA512 signature, key fingerprint: 6904...fd12: OK`.
+var signatureRegex = regexp.MustCompile(`(?i)signature, key (?:id|fingerprint:?) ([0-9a-f]+): (\w+)$`)
+
+// parseChecksig parses the output of `rpm --checksig --verbose`, as described by `CheckSignature`.
+// `failed` reports whether rpm exited with an error.
+func parseChecksig(output string, failed bool) (string, error) {
+    output = strings.TrimSpace(output)
+
+    // Each signature and digest is reported on its own line.
+    keyId, verified := "", false
+    for _, line := range strings.Split(output, "\n") {
+        line = strings.TrimSpace(line)
+        match := signatureRegex.FindStringSubmatch(line)
+
+        switch {
+        case strings.HasSuffix(line, ": BAD"):
+            return "", fmt.Errorf("%w: %s", ErrBadSignature, line)
+        case match != nil:
+            keyId = match[1]
+            if match[2] == "OK" { verified = true }
+        }
+    }
+
+    switch {
+    case failed && keyId == "":
+        return "", fmt.Errorf("%w: %s", ErrBadSignature, output)
+    case keyId != "" && !verified:
+        return keyId, fmt.Errorf("%w (key ID %s)", ErrUnknownKey, keyId)
+    case keyId == "":
+        return "", ErrUnsigned
+    default:
+        return keyId, nil
+    }
+}
+
+// KeyIdMatches reports whether the given key ID, as printed by rpm, belongs to the key
+// with the given fingerprint.
+func KeyIdMatches(keyId string, fingerprint string) bool {
+    keyId, fingerprint = strings.ToLower(keyId), strings.ToLower(fingerprint)
+    if keyId == "" { return false }
+
+    // v4 key IDs end the fingerprint, v5/v6 ones start it. rpm prints 8 or 16 digits of it,
+    // or the whole fingerprint.
+    if len(fingerprint) == 64 {
+        return strings.HasPrefix(fingerprint, keyId) || strings.HasSuffix(fingerprint[:16], keyId)
+    }
+    return strings.HasSuffix(fingerprint, keyId)
+}
+
+// KeyFingerprints returns the fingerprints of the primary keys of an OpenPGP public key,
+// or keyring, which may be ASCII armored.
+func KeyFingerprints(content []byte) ([]string, error) {
+    data, err := dearmor(content)
+    if err != nil { return nil, err }
+
+    fingerprints := []string {}
+    for len(data) > 0 {
+        tag, body, rest, err := readPacket(data)
+        if err != nil { return nil, err }
+        data = rest
+
+        if tag != publicKeyTag || len(body) == 0 { continue }
+
+        switch version := body[0]; version {
+        case 4:
+            header := []byte { 0x99, byte(len(body) >> 8), byte(len(body)) }
+            sum := sha1.Sum(append(header, body...))
+            fingerprints = append(fingerprints, strings.ToUpper(hex.EncodeToString(sum[:])))
+        case 5, 6:
+            header := []byte { lo.Ternary[byte](version == 5, 0x9a, 0x9b), 0, 0, 0, 0 }
+            binary.BigEndian.PutUint32(header[1:], uint32(len(body)))
+            sum := sha256.Sum256(append(header, body...))
+            fingerprints = append(fingerprints, strings.ToUpper(hex.EncodeToString(sum[:])))
+        default:
+            return nil, fmt.Errorf("Unsupported OpenPGP key version %d", version)
+        }
+    }
+
+    if len(fingerprints) == 0 { return nil, errors.New("No OpenPGP public key was found") }
+    return fingerprints, nil
+}
+
+// dearmor decodes an ASCII armored OpenPGP block. Binary content is returned as is.
+func dearmor(content []byte) ([]byte, error) {
+    text := string(bytes.TrimSpace(content))
+    if !strings.HasPrefix(text, "-----BEGIN PGP") { return content, nil }
+
+    encoded := strings.Builder {}
+    inBody := false
+    for _, line := range strings.Split(text, "\n") {
+        line = strings.TrimSpace(line)
+        switch {
+        case strings.HasPrefix(line, "-----END PGP"):
+            data, err := base64.StdEncoding.DecodeString(encoded.String())
+            if err != nil { return nil, fmt.Errorf("Invalid ASCII armored key: %w", err) }
+            return data, nil
+        case strings.HasPrefix(line, "-----BEGIN PGP"):
+            continue
+        case !inBody:
+            // Armor headers end with an empty line.
+            if line == "" || !strings.Contains(line, ": ") { inBody = true }
+            if line != "" && !strings.Contains(line, ": ") { encoded.WriteString(line) }
+        case strings.HasPrefix(line, "="):
+            // CRC-24 checksum
+            continue
+        default:
+            encoded.WriteString(line)
+        }
+    }
+
+    return nil, errors.New("Invalid ASCII armored key: missing END line")
+}
+
+// readPacket reads an OpenPGP packet, returning its tag, its body and the remaining data.
+func readPacket(data []byte) (byte, []byte, []byte, error) {
+    errTruncated := errors.New("Truncated OpenPGP packet")
+    if len(data) < 2 || data[0] & 0x80 == 0 { return 0, nil, nil, errors.New("Invalid OpenPGP packet") }
+
+    header := data[0]
+    length, offset := 0, 0
+
+    if header & 0x40 == 0 {
+        // Old format: the length type is in the header.
+        tag := (header >> 2) & 0x0f
+        switch header & 0x03 {
+        case 0:
+            length, offset = int(data[1]), 2
+        case 1:
+            if len(data) < 3 { return 0, nil, nil, errTruncated }
+            length, offset = int(binary.BigEndian.Uint16(data[1:3])), 3
+        case 2:
+            if len(data) < 5 { return 0, nil, nil, errTruncated }
+            length, offset = int(binary.BigEndian.Uint32(data[1:5])), 5
+        default:
+            length, offset = len(data) - 1, 1
+        }
+        if offset + length > len(data) { return 0, nil, nil, errTruncated }
+        return tag, data[offset:offset + length], data[offset + length:], nil
+    }
+
+    // New format
+    tag := header & 0x3f
+    switch first := int(data[1]); {
+    case first < 192:
+        length, offset = first, 2
+    case first < 224:
+        if len(data) < 3 { return 0, nil, nil, errTruncated }
+        length, offset = ((first - 192) << 8) + int(data[2]) + 192, 3
+    case first == 255:
+        if len(data) < 6 { return 0, nil, nil, errTruncated }
+        length, offset = int(binary.BigEndian.Uint32(data[2:6])), 6
+    default:
+        return 0, nil, nil, errors.New("Partial OpenPGP packets are not supported in keys")
+    }
+    if offset + length > len(data) { return 0, nil, nil, errTruncated }
+
+    return tag, data[offset:offset + length], data[offset + length:], nil
+}
@@ -0,0 +1,135 @@
+package backend
+
+import (
+    "encoding/base64"
+    "errors"
+    "slices"
+    "testing"
+)
+
+// testKey is an ed25519 key generated with `gpg --quick-gen-key`.
+const testKey string = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mDMEatQ+wxYJKwYBBAHaRw8BAQdAk/ogelfTXVSy+4/7ofPou+TQGwyQjrJxeByH
++xcrjgK0H3JwbS1nZXQgdGVzdCA8dGVzdEBleGFtcGxlLmNvbT6IkAQTFggAOBYh
+BGkEkxeauxrQ4GsoSkeH7XNHwv0SBQJq1D7DAhsDBQsJCAcCBhUKCQgLAgQWAgMB
+Ah4BAheAAAoJEEeH7XNHwv0SSNwBAIDZDNdVbTN7Eu9betGU2YZb31msdyYQDTfA
+bvUtgiSNAQCd766x+H1vVhZnXQgzC9OgC3W1g0p4xSwskLB1e+QZCA==
+=ansC
+-----END PGP PUBLIC KEY BLOCK-----
+`
+
+// testKeyFingerprint is the fingerprint of `testKey`, as printed by `gpg --fingerprint`.
+const testKeyFingerprint string = "690493179ABB1AD0E06B284A4787ED7347C2FD12"
+
+// secondKey is another ed25519 key, in binary form, used to build keyrings.
+const secondKey string = "mDMEatRFUxYJKwYBBAHaRw8BAQdAi+4LtNYlIVYoi/BChm7Ed5tg0IBbO0xMf33V" +
+    "YMri2VG0KHJwbS1nZXQgc2Vjb25kIHRlc3QgPHNlY29uZEBleGFtcGxlLmNvbT6I" +
+    "kAQTFggAOBYhBJUemH7DSnUtnp007b7jpBXdCYQwBQJq1EVTAhsDBQsJCAcCBhUK" +
+    "CQgLAgQWAgMBAh4BAheAAAoJEL7jpBXdCYQwfr4BAKw0MQXT2wGd0Pr/GJC8eljs" +
+    "DdDxTM3uIQcEUZvXvfh4AP9dOvwLRJZazQHHQiaCAt/JIxHPGDLdVTJIzhxYl8l6" +
+    "Cg=="
+
+// secondKeyFingerprint is the fingerprint of `secondKey`.
+const secondKeyFingerprint string = "951E987EC34A752D9E9D34EDBEE3A415DD098430"
+
+func TestKeyFingerprints(t *testing.T) {
+    binary, _ := base64.StdEncoding.DecodeString(
+        "mDMEatQ+wxYJKwYBBAHaRw8BAQdAk/ogelfTXVSy+4/7ofPou+TQGwyQjrJxeByH" +
+        "+xcrjgK0H3JwbS1nZXQgdGVzdCA8dGVzdEBleGFtcGxlLmNvbT6IkAQTFggAOBYh" +
+        "BGkEkxeauxrQ4GsoSkeH7XNHwv0SBQJq1D7DAhsDBQsJCAcCBhUKCQgLAgQWAgMB" +
+        "Ah4BAheAAAoJEEeH7XNHwv0SSNwBAIDZDNdVbTN7Eu9betGU2YZb31msdyYQDTfA" +
+        "bvUtgiSNAQCd766x+H1vVhZnXQgzC9OgC3W1g0p4xSwskLB1e+QZCA==")
+    second, _ := base64.StdEncoding.DecodeString(secondKey)
+
+    tests := map[string]struct {
+        content []byte
+        want []string
+    }{
+        "armored": { []byte(testKey), []string { testKeyFingerprint } },
+        "binary": { binary, []string { testKeyFingerprint } },
+        "keyring": { append(slices.Clone(binary), second...), []string { testKeyFingerprint, secondKeyFingerprint } },
+        "reversed keyring": { append(slices.Clone(second), binary...), []string { secondKeyFingerprint, testKeyFingerprint } },
+    }
+
+    for name, test := range tests {
+        fingerprints, err := KeyFingerprints(test.content)
+        if err != nil {
+            t.Errorf("%s: unexpected error: %v", name, err)
+            continue
+        }
+        if !slices.Equal(fingerprints, test.want) {
+            t.Errorf("%s: got fingerprints %v, want %v", name, fingerprints, test.want)
+        }
+    }
+}
+
+func TestKeyFingerprintsInvalid(t *testing.T) {
+    tests := map[string]string {
+        "empty": "",
+        "html": "<html><body>Not Found</body></html>",
+        "truncated armor": "-----BEGIN PGP PUBLIC KEY BLOCK-----\n\nmDMEatQ+wxYJKwYBBAHaRw8BAQdA\n",
+    }
+
+    for name, content := range tests {
+        if _, err := KeyFingerprints([]byte(content)); err == nil {
+            t.Errorf("%s: expected an error", name)
+        }
+    }
+}
+
+func TestParseChecksig(t *testing.T) {
+    tests := []struct {
+        name string
+        output string
+        failed bool
+        keyId string
+        err error
+    }{
+        {
+            "rpm 4 signed",
+            "app.rpm:\n    Header V4 RSA/SHA256 Signature, key ID 47c2fd12: OK\n    Header SHA256 digest: OK\n" +
+                "    Header SHA1 digest: OK\n    Payload SHA256 digest: OK\n    V4 RSA/SHA256 Signature, key ID 47c2fd12: OK\n    MD5 digest: OK\n",
+            false, "47c2fd12", nil,
+        },
+        {
+            "rpm 4 missing key",
+            "app.rpm:\n    Header V4 RSA/SHA256 Signature, key ID 47c2fd12: NOKEY\n    Header SHA256 digest: OK\n    Payload SHA256 digest: OK\n",
+            true, "47c2fd12", ErrUnknownKey,
+        },
+        {
+            "rpm 6 signed",
+            "app.rpm:\n    Header OpenPGP V4 EdDSA/SHA512 signature, key fingerprint: 690493179abb1ad0e06b284a4787ed7347c2fd12: OK\n" +
+                "    Header SHA256 digest: OK\n    Header SHA3-256 digest: OK\n    Payload SHA256 digest: OK\n    Payload SHA512 digest: OK\n",
+            false, "690493179abb1ad0e06b284a4787ed7347c2fd12", nil,
+        },
+        {
+            "rpm 6 missing key",
+            "app.rpm:\n    Header OpenPGP V4 EdDSA/SHA512 signature, key fingerprint: 690493179abb1ad0e06b284a4787ed7347c2fd12: NOKEY\n" +
+                "    Header SHA256 digest: OK\n    Payload SHA256 digest: OK\n",
+            true, "690493179abb1ad0e06b284a4787ed7347c2fd12", ErrUnknownKey,
+        },
+        {
+            "rpm 6 bad signature",
+            "app.rpm:\n    Header OpenPGP V4 EdDSA/SHA512 signature, key fingerprint: 690493179abb1ad0e06b284a4787ed7347c2fd12: BAD\n",
+            true, "", ErrBadSignature,
+        },
+        {
+            "bad digest",
+            "app.rpm:\n    Header V4 RSA/SHA256 Signature, key ID 47c2fd12: OK\n    Payload SHA256 digest: BAD (Expected abc != def)\n    MD5 digest: BAD\n",
+            true, "", ErrBadSignature,
+        },
+        { "unsigned", "app.rpm:\n    Header SHA256 digest: OK\n    Payload SHA256 digest: OK\n", false, "", ErrUnsigned },
+        { "not an RPM", "error: app.rpm: not an rpm package (or package manifest)\n", true, "", ErrBadSignature },
+    }
+
+    for _, test := range tests {
+        keyId, err := parseChecksig(test.output, test.failed)
+        if keyId != test.keyId || !errors.Is(err, test.err) {
+            t.Errorf("%s: parseChecksig() = %q, %v, want %q, %v", test.name, keyId, err, test.keyId, test.err)
+        }
+        if keyId != "" && !KeyIdMatches(keyId, testKeyFingerprint) {
+            t.Errorf("%s: key ID %s doesn't match %s", test.name, keyId, testKeyFingerprint)
+        }
+    }
+}
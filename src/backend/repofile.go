@@ -0,0 +1,142 @@
+package backend
+
+import (
+    "bufio"
+    "bytes"
+    "fmt"
+    "slices"
+    "strings"
+    "unicode"
+)
+
+// RepoSection is a single `[id]` section of a repo file.
+type RepoSection struct {
+    ID string
+    // Keys in the order they appear in the file
+    Keys []string
+    Values map[string]string
+}
+
+// Get returns the value of the given key, or an empty string if it's not set.
+func (s *RepoSection) Get(key string) string { return s.Values[key] }
+
+// Set sets the given key, keeping the position of existing keys.
+func (s *RepoSection) Set(key string, value string) {
+    if _, ok := s.Values[key]; !ok { s.Keys = append(s.Keys, key) }
+    s.Values[key] = value
+}
+
+// Enabled reports whether the section is enabled, which it is when `enabled` is not set.
+func (s *RepoSection) Enabled() bool {
+    switch strings.ToLower(s.Get("enabled")) {
+    case "0", "false", "no", "off":
+        return false
+    default:
+        return true
+    }
+}
+
+// ParseRepoFile parses the sections of a yum/dnf/zypper repo file.
+func ParseRepoFile(content []byte) ([]*RepoSection, error) {
+    sections := []*RepoSection {}
+    current := (*RepoSection)(nil)
+    lastKey := ""
+
+    scanner := bufio.NewScanner(bytes.NewReader(content))
+    for lineNum := 1; scanner.Scan(); lineNum++ {
+        raw := scanner.Text()
+        line := strings.TrimSpace(raw)
+
+        switch {
+        case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+            continue
+        case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+            current = &RepoSection { ID: strings.TrimSpace(line[1:len(line) - 1]), Values: map[string]string {} }
+            sections = append(sections, current)
+            lastKey = ""
+        case current == nil:
+            return nil, fmt.Errorf("Invalid repo file, line %d is outside of a section", lineNum)
+        case (raw[0] == ' ' || raw[0] == '\t') && lastKey != "":
+            // Continuation lines, as used by multi-line `baseurl` and `gpgkey` values.
+            current.Values[lastKey] += "\n" + line
+        default:
+            key, value, ok := strings.Cut(line, "=")
+            if !ok { return nil, fmt.Errorf("Invalid repo file, line %d is missing '='", lineNum) }
+
+            lastKey = strings.TrimSpace(key)
+            current.Set(lastKey, strings.TrimSpace(value))
+        }
+    }
+
+    if err := scanner.Err(); err != nil { return nil, fmt.Errorf("Failed to read repo file: %w", err) }
+    if len(sections) == 0 { return nil, fmt.Errorf("Invalid repo file, no repositories are defined") }
+
+    return sections, nil
+}
+
+// RepoKeys returns the URLs of the GPG keys named by the `gpgkey` entries of the enabled sections
+// of the given repo file. Entries may be separated by commas or whitespace. Entries using repo
+// variables, such as `$releasever`, are left to the package manager, which expands them.
+func RepoKeys(content []byte) ([]string, error) {
+    sections, err := ParseRepoFile(content)
+    if err != nil { return nil, err }
+
+    result := []string {}
+    for _, section := range sections {
+        if !section.Enabled() { continue }
+
+        keys := strings.FieldsFunc(section.Get("gpgkey"), func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+        for _, key := range keys {
+            if !strings.Contains(key, "$") && !slices.Contains(result, key) { result = append(result, key) }
+        }
+    }
+
+    return result, nil
+}
+
+// FormatRepoFile formats the given sections as a repo file.
+func FormatRepoFile(sections []*RepoSection) []byte {
+    result := bytes.Buffer {}
+
+    for i, section := range sections {
+        if i > 0 { result.WriteString("\n") }
+        fmt.Fprintf(&result, "[%s]\n", section.ID)
+
+        for _, key := range section.Keys {
+            value := strings.ReplaceAll(section.Values[key], "\n", "\n    ")
+            fmt.Fprintf(&result, "%s=%s\n", key, value)
+        }
+    }
+
+    return result.Bytes()
+}
+
+// zypperRepoKeys are the repo file keys that zypper understands.
+var zypperRepoKeys = []string {
+    "name", "enabled", "autorefresh", "baseurl", "mirrorlist", "path", "type", "priority",
+    "keeppackages", "gpgcheck", "repo_gpgcheck", "pkg_gpgcheck", "gpgkey",
+}
+
+// toZypperRepo converts a yum/dnf repo section to the format used in `/etc/zypp/repos.d`.
+// Keys that zypper doesn't understand are dropped.
+func toZypperRepo(section *RepoSection, gpgKeyUrl string) *RepoSection {
+    result := &RepoSection { ID: section.ID, Values: map[string]string {} }
+
+    result.Set("name", section.ID)
+    result.Set("enabled", "1")
+    result.Set("autorefresh", "1")
+    for _, key := range section.Keys {
+        if slices.Contains(zypperRepoKeys, key) { result.Set(key, section.Get(key)) }
+    }
+
+    // zypper can't read metalinks, but treats a mirrorlist the same way.
+    if result.Get("baseurl") == "" && result.Get("mirrorlist") == "" && section.Get("metalink") != "" {
+        result.Set("mirrorlist", section.Get("metalink"))
+    }
+
+    result.Set("type", "rpm-md")
+    if result.Get("gpgkey") == "" && gpgKeyUrl != "" { result.Set("gpgkey", gpgKeyUrl) }
+    if result.Get("gpgkey") != "" && result.Get("gpgcheck") == "" { result.Set("gpgcheck", "1") }
+
+    return result
+}
@@ -0,0 +1,169 @@
+package backend
+
+import (
+    "slices"
+    "testing"
+)
+
+func TestRepoKeys(t *testing.T) {
+    content := []byte(`[app]
+name=App
+baseurl=https://example.com/rpm
+gpgkey=https://example.com/key1.asc
+    https://example.com/key2.asc
+
+[app-beta]
+name=App beta
+baseurl=https://example.com/rpm-beta
+gpgkey=https://example.com/key1.asc
+
+[app-source]
+name=App sources
+baseurl=https://example.com/srpm
+`)
+
+    keys, err := RepoKeys(content)
+    if err != nil { t.Fatal(err) }
+    want := []string { "https://example.com/key1.asc", "https://example.com/key2.asc" }
+    if !slices.Equal(keys, want) { t.Errorf("RepoKeys() = %v, want %v", keys, want) }
+
+    if _, err := RepoKeys([]byte("not a repo file")); err == nil { t.Error("RepoKeys() accepted an invalid repo file") }
+}
+
+func TestRepoKeysFiltered(t *testing.T) {
+    tests := []struct {
+        name string
+        content string
+        want []string
+    }{
+        {
+            "comma separated",
+            "[app]\ngpgkey=https://example.com/key1.asc,https://example.com/key2.asc, https://example.com/key3.asc\n",
+            []string { "https://example.com/key1.asc", "https://example.com/key2.asc", "https://example.com/key3.asc" },
+        },
+        {
+            "repo variables",
+            "[rpmfusion-free]\ngpgkey=file:///etc/pki/rpm-gpg/RPM-GPG-KEY-rpmfusion-free-fedora-$releasever\n" +
+                "    https://example.com/key-${basearch}.asc https://example.com/key.asc\n",
+            []string { "https://example.com/key.asc" },
+        },
+        {
+            "disabled sections",
+            "[app]\nenabled=1\ngpgkey=https://example.com/key1.asc\n" +
+                "[app-testing]\nenabled=0\ngpgkey=https://example.com/testing.asc\n" +
+                "[app-debug]\nenabled=False\ngpgkey=https://example.com/debug.asc\n" +
+                "[app-source]\ngpgkey=https://example.com/source.asc\n",
+            []string { "https://example.com/key1.asc", "https://example.com/source.asc" },
+        },
+        { "only disabled sections", "[app]\nenabled=0\ngpgkey=https://example.com/key1.asc\n", []string {} },
+    }
+
+    for _, test := range tests {
+        keys, err := RepoKeys([]byte(test.content))
+        if err != nil {
+            t.Errorf("%s: unexpected error: %v", test.name, err)
+        } else if !slices.Equal(keys, test.want) {
+            t.Errorf("%s: RepoKeys() = %v, want %v", test.name, keys, test.want)
+        }
+    }
+}
+
+func TestParseRepoFile(t *testing.T) {
+    tests := []struct {
+        name string
+        content string
+        // Expected `id: key=value` entries, in order
+        want []string
+    }{
+        {
+            "comments and blank lines",
+            "# comment\n; comment\n\n[app]\nname = App\n  # not a continuation\nenabled=1\n",
+            []string { "app: name=App", "app: enabled=1" },
+        },
+        {
+            "continuation lines",
+            "[app]\nbaseurl=https://a.example.com/rpm\n\thttps://b.example.com/rpm\ngpgkey=https://example.com/key1.asc\n    https://example.com/key2.asc\n",
+            []string {
+                "app: baseurl=https://a.example.com/rpm\nhttps://b.example.com/rpm",
+                "app: gpgkey=https://example.com/key1.asc\nhttps://example.com/key2.asc",
+            },
+        },
+        {
+            "several sections",
+            "[app]\nname=App\n[app-beta]\nname=App beta\nbaseurl=https://example.com/beta?arch=$basearch\n",
+            []string { "app: name=App", "app-beta: name=App beta", "app-beta: baseurl=https://example.com/beta?arch=$basearch" },
+        },
+    }
+
+    for _, test := range tests {
+        sections, err := ParseRepoFile([]byte(test.content))
+        if err != nil { t.Errorf("%s: %v", test.name, err); continue }
+
+        got := []string {}
+        for _, section := range sections {
+            for _, key := range section.Keys { got = append(got, section.ID + ": " + key + "=" + section.Get(key)) }
+        }
+        if !slices.Equal(got, test.want) { t.Errorf("%s: ParseRepoFile() = %q, want %q", test.name, got, test.want) }
+    }
+
+    for _, invalid := range []string { "", "# only a comment\n", "name=App\n[app]\n", "[app]\nname\n" } {
+        if _, err := ParseRepoFile([]byte(invalid)); err == nil { t.Errorf("ParseRepoFile(%q) succeeded", invalid) }
+    }
+}
+
+func TestToZypperRepo(t *testing.T) {
+    tests := []struct {
+        name string
+        content string
+        gpgKeyUrl string
+        want string
+    }{
+        {
+            "metalink becomes a mirrorlist",
+            "[fedora-app]\nname=App\nmetalink=https://mirrors.example.com/metalink?repo=app\nskip_if_unavailable=True\n",
+            "",
+            "[fedora-app]\nname=App\nenabled=1\nautorefresh=1\nmirrorlist=https://mirrors.example.com/metalink?repo=app\ntype=rpm-md\n",
+        },
+        {
+            "the metalink is dropped when there is a baseurl",
+            "[app]\nbaseurl=https://example.com/rpm\nmetalink=https://mirrors.example.com/metalink\n",
+            "",
+            "[app]\nname=app\nenabled=1\nautorefresh=1\nbaseurl=https://example.com/rpm\ntype=rpm-md\n",
+        },
+        {
+            "the manifest key is injected with gpgcheck",
+            "[app]\nbaseurl=https://example.com/rpm\ntype=yum\nenabled=0\n",
+            "https://example.com/key.asc",
+            "[app]\nname=app\nenabled=0\nautorefresh=1\nbaseurl=https://example.com/rpm\ntype=rpm-md\ngpgkey=https://example.com/key.asc\ngpgcheck=1\n",
+        },
+        {
+            "the keys and gpgcheck of the repo file are kept",
+            "[app]\nbaseurl=https://example.com/rpm\ngpgcheck=0\ngpgkey=https://example.com/repo.asc\n",
+            "https://example.com/key.asc",
+            "[app]\nname=app\nenabled=1\nautorefresh=1\nbaseurl=https://example.com/rpm\ngpgcheck=0\ngpgkey=https://example.com/repo.asc\ntype=rpm-md\n",
+        },
+    }
+
+    for _, test := range tests {
+        sections, err := ParseRepoFile([]byte(test.content))
+        if err != nil { t.Fatalf("%s: %v", test.name, err) }
+
+        for i, section := range sections { sections[i] = toZypperRepo(section, test.gpgKeyUrl) }
+        if got := string(FormatRepoFile(sections)); got != test.want {
+            t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+        }
+    }
+}
+
+func TestFormatRepoFileRoundTrip(t *testing.T) {
+    content := "[app]\nname=App\nbaseurl=https://a.example.com/rpm\n    https://b.example.com/rpm\ngpgcheck=1\n\n[app-beta]\nname=App beta\nenabled=0\n"
+
+    sections, err := ParseRepoFile([]byte(content))
+    if err != nil { t.Fatal(err) }
+    formatted := FormatRepoFile(sections)
+    if string(formatted) != content { t.Errorf("FormatRepoFile() =\n%s\nwant\n%s", formatted, content) }
+
+    reparsed, err := ParseRepoFile(formatted)
+    if err != nil { t.Fatal(err) }
+    if string(FormatRepoFile(reparsed)) != content { t.Error("a second round trip changed the repo file") }
+}
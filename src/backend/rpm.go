@@ -0,0 +1,51 @@
+package backend
+
+import (
+    "fmt"
+)
+
+// rpmBackend implements `Backend` with plain rpm, for hosts without a higher-level package manager.
+// Only local RPM files can be installed, and repositories are not supported.
+type rpmBackend struct{}
+
+// NewRpm returns the backend for plain rpm.
+func NewRpm() Backend { return &rpmBackend {} }
+
+func (r *rpmBackend) Name() string { return "rpm" }
+
+func (r *rpmBackend) ReposDir() string { return "" }
+
+func (r *rpmBackend) Install(files []string, pkgs []string) error {
+    if len(pkgs) > 0 {
+        return fmt.Errorf("Repository packages can't be installed with rpm: %w", ErrUnsupported)
+    }
+
+    return run("rpm", append([]string { "-Uvh" }, files...)...)
+}
+
+func (r *rpmBackend) Upgrade(_ ...string) error {
+    return fmt.Errorf("Repository packages can't be upgraded with rpm: %w", ErrUnsupported)
+}
+
+// Reinstall only accepts local RPM files.
+func (r *rpmBackend) Reinstall(pkgs ...string) error {
+    return run("rpm", append([]string { "-Uvh", "--replacepkgs" }, pkgs...)...)
+}
+
+func (r *rpmBackend) Remove(pkgs ...string) error {
+    return run("rpm", append([]string { "-e" }, pkgs...)...)
+}
+
+func (r *rpmBackend) InstalledVersion(pkg string) (string, error) { return queryVersion(pkg) }
+
+func (r *rpmBackend) AddRepo(_ string, _ []byte, _ string) (string, error) {
+    return "", fmt.Errorf("Repos can't be added with rpm: %w", ErrUnsupported)
+}
+
+func (r *rpmBackend) AddCoprRepo(_ string, _ string) (string, error) {
+    return "", fmt.Errorf("Copr repos can't be enabled with rpm: %w", ErrUnsupported)
+}
+
+func (r *rpmBackend) RemoveRepo(_ string) error {
+    return fmt.Errorf("Repos can't be removed with rpm: %w", ErrUnsupported)
+}
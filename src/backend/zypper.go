@@ -0,0 +1,65 @@
+package backend
+
+import (
+    "fmt"
+)
+
+// ZYPP_REPOS_DIR is the directory where zypper looks for repo files.
+const ZYPP_REPOS_DIR string = "/etc/zypp/repos.d"
+
+// zypper implements `Backend` for zypper (openSUSE Leap and Tumbleweed).
+type zypper struct{}
+
+// NewZypper returns the backend for zypper.
+func NewZypper() Backend { return &zypper {} }
+
+func (z *zypper) Name() string { return "zypper" }
+
+func (z *zypper) ReposDir() string { return ZYPP_REPOS_DIR }
+
+// Install keeps the signature check of zypper, so unsigned local RPMs are refused,
+// as are RPMs signed with a key missing from the keyring.
+func (z *zypper) Install(files []string, pkgs []string) error {
+    args := append([]string { "--non-interactive", "install" }, files...)
+    return run("zypper", append(args, pkgs...)...)
+}
+
+func (z *zypper) Upgrade(pkgs ...string) error {
+    return run("zypper", append([]string { "--non-interactive", "update" }, pkgs...)...)
+}
+
+func (z *zypper) Reinstall(pkgs ...string) error {
+    return run("zypper", append([]string { "--non-interactive", "install", "--force" }, pkgs...)...)
+}
+
+func (z *zypper) Remove(pkgs ...string) error {
+    return run("zypper", append([]string { "--non-interactive", "remove" }, pkgs...)...)
+}
+
+func (z *zypper) InstalledVersion(pkg string) (string, error) { return queryVersion(pkg) }
+
+// AddRepo converts the given yum/dnf repo file to the zypper format, and refreshes only
+// the repositories defined in it.
+func (z *zypper) AddRepo(name string, content []byte, gpgKeyUrl string) (string, error) {
+    sections, err := ParseRepoFile(content)
+    if err != nil { return "", err }
+
+    for i, section := range sections { sections[i] = toZypperRepo(section, gpgKeyUrl) }
+
+    fileName, writeErr := writeRepoFile(z.ReposDir(), repoFileName(name), FormatRepoFile(sections))
+    if writeErr != nil { return "", writeErr }
+
+    for _, section := range sections {
+        if err := run("zypper", "--non-interactive", "refresh", "--repo", section.ID); err != nil {
+            return fileName, err
+        }
+    }
+
+    return fileName, nil
+}
+
+func (z *zypper) AddCoprRepo(_ string, _ string) (string, error) {
+    return "", fmt.Errorf("Copr repos can't be enabled with zypper: %w", ErrUnsupported)
+}
+
+func (z *zypper) RemoveRepo(name string) error { return removeRepoFile(z.ReposDir(), name) }
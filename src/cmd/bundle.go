@@ -0,0 +1,167 @@
+package cmd
+
+import (
+    "archive/tar"
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "path"
+    "path/filepath"
+    "slices"
+    "strings"
+
+    h "github.com/FlawlessCasual17/rpm-get/helpers"
+    "github.com/FlawlessCasual17/rpm-get/manifest"
+    "github.com/klauspost/compress/zstd"
+)
+
+const (
+    // BUNDLE_NAME is the name of the index bundle, a zstd-compressed tarball containing the
+    // packages list and a `manifests` directory, next to the packages list.
+    BUNDLE_NAME string = "index.tar.zst"
+
+    // DIGEST_EXT is the extension of the detached SHA-256 digest of the index bundle,
+    // in the format of `sha256sum`.
+    DIGEST_EXT string = ".sha256"
+)
+
+// MAX_BUNDLE_MEMORY is the most memory the decompression of the index bundle may use.
+const MAX_BUNDLE_MEMORY uint64 = 64 << 20
+
+// maxIndexSize is the largest total size of the files extracted from the index bundle.
+var maxIndexSize int64 = 256 << 20
+
+var (
+    // errNoBundle is returned when the package index isn't published as a bundle.
+    errNoBundle = errors.New("The package index bundle is not available")
+    // errUpToDate is returned when the package index bundle was not modified since the last update.
+    errUpToDate = errors.New("The package index is up to date")
+)
+
+// syncBundle downloads the index bundle and its digest, checks the signature of the digest and
+// the digest itself, and extracts the
+// packages list and the manifests of the listed packages to the staging directory.
+// `errNoBundle` is returned when the bundle can't be downloaded, so that the caller can fall
+// back to downloading each file.
+func (s *indexSync) syncBundle() ([]string, []manifestFailure, error) {
+    bundleUrl := IndexUrl + "/" + BUNDLE_NAME
+
+    headers := map[string]string {}
+    if prev, ok := s.previous[bundleUrl]; ok && fileExists(filepath.Join(indexDir(), PKGS_LIST_NAME)) {
+        headers["If-None-Match"] = prev.ETag
+        headers["If-Modified-Since"] = prev.LastModified
+    }
+
+    content := bytes.Buffer {}
+    resp, bundleErr := download(bundleUrl, &content, "Downloading package index...", headers)
+    if bundleErr != nil { return nil, nil, fmt.Errorf("%w (%s)", errNoBundle, bundleErr) }
+    if resp.StatusCode == http.StatusNotModified { return nil, nil, errUpToDate }
+
+    digest := bytes.Buffer {}
+    if _, err := download(bundleUrl + DIGEST_EXT, &digest, "", nil); err != nil {
+        return nil, nil, fmt.Errorf("Unable to download the digest of the package index: %w", err)
+    }
+    if err := s.verifier.verify(bundleUrl + DIGEST_EXT, digest.Bytes()); err != nil { return nil, nil, err }
+    if err := checkDigest(content.Bytes(), digest.String()); err != nil { return nil, nil, err }
+
+    if err := extractBundle(content.Bytes(), s.dir); err != nil { return nil, nil, err }
+
+    pkgs, listErr := readPkgsList(s.dir)
+    if listErr != nil { return nil, nil, listErr }
+
+    s.current[bundleUrl] = validator { ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified") }
+    return pkgs, s.validateBundle(pkgs), nil
+}
+
+// validateBundle validates the extracted manifests of the given packages, and removes the
+// manifests of unlisted packages. Like in `getPkgManifests`, the manifests that fail keep
+// their version from the current index, when there's one.
+func (s *indexSync) validateBundle(pkgs []string) []manifestFailure {
+    failures := []manifestFailure {}
+
+    entries, _ := os.ReadDir(s.dir)
+    for _, entry := range entries {
+        if pkg, ok := strings.CutSuffix(entry.Name(), ".yaml"); ok && !slices.Contains(pkgs, pkg) {
+            _ = os.Remove(filepath.Join(s.dir, entry.Name()))
+        }
+    }
+
+    for _, pkg := range pkgs {
+        filePath := filepath.Join(s.dir, pkg + ".yaml")
+        err := validateManifest(pkg, filePath)
+        if errors.Is(err, os.ErrNotExist) { err = errors.New("missing from the package index bundle") }
+        if err == nil { continue }
+
+        _ = os.Remove(filePath)
+        failures = append(failures, manifestFailure { pkg: pkg, err: err, kept: s.keepManifest(pkg) })
+    }
+
+    return failures
+}
+
+// checkDigest checks the content against a digest in the format of `sha256sum`.
+func checkDigest(content []byte, digest string) error {
+    fields := strings.Fields(digest)
+    if len(fields) == 0 { return errors.New("The digest of the package index is empty") }
+
+    sum := sha256.Sum256(content)
+    if !strings.EqualFold(fields[0], hex.EncodeToString(sum[:])) {
+        return errors.New("The package index bundle doesn't match its digest, refusing to use it")
+    }
+
+    return nil
+}
+
+// extractBundle extracts the packages list and the manifests of an index bundle to the given
+// directory, flattening the `manifests` directory. Other files are ignored. The files must not
+// add up to more than `maxIndexSize`.
+func extractBundle(content []byte, dir string) error {
+    decoder, zstdErr := zstd.NewReader(bytes.NewReader(content), zstd.WithDecoderMaxMemory(MAX_BUNDLE_MEMORY))
+    if zstdErr != nil { return fmt.Errorf("Failed to decompress the package index: %w", zstdErr) }
+    defer decoder.Close()
+
+    archive := tar.NewReader(decoder)
+    size := int64 (0)
+    for {
+        header, err := archive.Next()
+        if err == io.EOF { break }
+        if err != nil { return fmt.Errorf("Failed to read the package index: %w", err) }
+        if header.Typeflag != tar.TypeReg { continue }
+
+        size += header.Size
+        if size > maxIndexSize {
+            return fmt.Errorf("The package index is larger than %s, refusing to extract it", formatSize(maxIndexSize))
+        }
+
+        name := strings.TrimPrefix(path.Clean(header.Name), "./")
+        fileName := ""
+        if name == PKGS_LIST_NAME {
+            fileName = PKGS_LIST_NAME
+        } else if pkg, ok := strings.CutSuffix(strings.TrimPrefix(name, "manifests/"), ".yaml"); ok &&
+            strings.HasPrefix(name, "manifests/") && manifest.ValidName(pkg) {
+            fileName = pkg + ".yaml"
+        } else {
+            continue
+        }
+
+        file, fileErr := os.OpenFile(filepath.Join(dir, fileName), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+        if fileErr != nil { return fmt.Errorf("Failed to extract %s: %w", name, fileErr) }
+
+        _, copyErr := io.Copy(file, archive)
+        if err := errors.Join(copyErr, file.Close()); err != nil {
+            h.Printc("Failed to extract the package index!", h.ERROR, false)
+            return fmt.Errorf("Failed to extract %s: %w", name, err)
+        }
+    }
+
+    if !fileExists(filepath.Join(dir, PKGS_LIST_NAME)) {
+        return errors.New("The package index bundle has no " + PKGS_LIST_NAME)
+    }
+
+    return nil
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+    "testing"
+)
+
+func TestExtractBundle(t *testing.T) {
+    dir := filepath.Join(t.TempDir(), "staging")
+    if err := os.Mkdir(dir, 0755); err != nil { t.Fatal(err) }
+
+    entries := []bundleEntry {
+        { name: PKGS_LIST_NAME, content: []byte(`["app","tool"]`) },
+        { name: "manifests/app.yaml", content: []byte("app") },
+        { name: "./manifests/tool.yaml", content: []byte("tool") },
+        // Only the packages list and the manifests are extracted, flattened.
+        { name: "README.md", content: []byte("readme") },
+        { name: "manifests/nested/app.yaml", content: []byte("nested") },
+        { name: "manifests/app.json", content: []byte("json") },
+        { name: "manifests/Not Valid.yaml", content: []byte("invalid") },
+        { name: "manifests/.yaml", content: []byte("empty") },
+        // Nothing is written outside of the directory.
+        { name: "../escape.yaml", content: []byte("escape") },
+        { name: "manifests/../../escape.yaml", content: []byte("escape") },
+        { name: "/manifests/absolute.yaml", content: []byte("absolute") },
+        { name: "manifests/link.yaml", link: "../../escape.yaml" },
+    }
+
+    if err := extractBundle(makeBundle(t, entries), dir); err != nil { t.Fatalf("extractBundle() failed: %v", err) }
+
+    files := []string {}
+    //nolint:errcheck
+    filepath.WalkDir(filepath.Dir(dir), func(filePath string, entry os.DirEntry, _ error) error {
+        if !entry.IsDir() { files = append(files, strings.TrimPrefix(filePath, filepath.Dir(dir) + "/")) }
+        return nil
+    })
+    if want := []string { "staging/app.yaml", "staging/" + PKGS_LIST_NAME, "staging/tool.yaml" }; !slices.Equal(files, want) {
+        t.Errorf("extractBundle() wrote %v, want %v", files, want)
+    }
+    if content, _ := os.ReadFile(filepath.Join(dir, "tool.yaml")); string(content) != "tool" {
+        t.Errorf("extractBundle() extracted %q as tool.yaml", content)
+    }
+}
+
+func TestExtractBundleInvalid(t *testing.T) {
+    tests := []struct {
+        name string
+        content func(t *testing.T) []byte
+        want string
+    }{
+        { "not zstd", func(_ *testing.T) []byte { return []byte("not a bundle") }, "Failed to read" },
+        {
+            "no packages list",
+            func(t *testing.T) []byte { return makeBundle(t, []bundleEntry { { name: "manifests/app.yaml", content: []byte("app") } }) },
+            "has no " + PKGS_LIST_NAME,
+        },
+        {
+            "larger than the limit",
+            func(t *testing.T) []byte {
+                return makeBundle(t, []bundleEntry {
+                    { name: PKGS_LIST_NAME, content: []byte(`["app"]`) },
+                    { name: "README.md", content: make([]byte, 1024) },
+                })
+            },
+            "larger than",
+        },
+    }
+
+    previous := maxIndexSize
+    maxIndexSize = 1024
+    t.Cleanup(func() { maxIndexSize = previous })
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            err := extractBundle(test.content(t), t.TempDir())
+            if err == nil || !strings.Contains(err.Error(), test.want) {
+                t.Errorf("extractBundle() = %v, want an error containing %q", err, test.want)
+            }
+        })
+    }
+}
+
+func TestValidateBundle(t *testing.T) {
+    useTestDirs(t)
+    writeIndex(t, map[string]string { "broken": testManifest("broken", "1.0.0", "") })
+
+    dir := t.TempDir()
+    files := map[string]string {
+        "app.yaml": testManifest("app", "2.0.0", ""),
+        "broken.yaml": "name: broken\n",
+        "misnamed.yaml": testManifest("other", "2.0.0", ""),
+        "unlisted.yaml": testManifest("unlisted", "2.0.0", ""),
+    }
+    for name, content := range files {
+        if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil { t.Fatal(err) }
+    }
+
+    index := &indexSync { dir: dir, previous: map[string]validator {}, current: map[string]validator {} }
+    failures := index.validateBundle([]string { "app", "broken", "misnamed", "missing" })
+
+    got := []string {}
+    for _, failure := range failures { got = append(got, failure.pkg) }
+    if want := []string { "broken", "misnamed", "missing" }; !slices.Equal(got, want) {
+        t.Errorf("validateBundle() reported %v, want %v", got, want)
+    }
+    for _, failure := range failures {
+        if failure.kept != (failure.pkg == "broken") { t.Errorf("validateBundle() kept %s: %v", failure.pkg, failure.kept) }
+        if failure.pkg == "missing" && !strings.Contains(failure.err.Error(), "missing from") {
+            t.Errorf("validateBundle() reported %v for a missing manifest", failure.err)
+        }
+    }
+
+    // The broken manifest keeps its version of the current index.
+    entries, _ := os.ReadDir(dir)
+    names := []string {}
+    for _, entry := range entries { names = append(names, entry.Name()) }
+    if want := []string { "app.yaml", "broken.yaml" }; !slices.Equal(names, want) {
+        t.Errorf("validateBundle() left %v, want %v", names, want)
+    }
+    if content, _ := os.ReadFile(filepath.Join(dir, "broken.yaml")); !strings.Contains(string(content), "1.0.0") {
+        t.Errorf("validateBundle() left %q as broken.yaml", content)
+    }
+}
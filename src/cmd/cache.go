@@ -1,45 +1,346 @@
 package cmd
 
 import (
-	"fmt"
-	"os"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "slices"
+    "strconv"
+    "strings"
+    "time"
 
-	h "github.com/FlawlessCasual17/rpm-get/helpers"
-	"github.com/spf13/cobra"
+    h "github.com/FlawlessCasual17/rpm-get/helpers"
+    "github.com/FlawlessCasual17/rpm-get/manifest"
+    "github.com/FlawlessCasual17/rpm-get/release"
+    "github.com/FlawlessCasual17/rpm-get/state"
+    "github.com/samber/lo"
+    "github.com/spf13/cobra"
+)
+
+var (
+    // pruneKeep is set by `cache prune --keep`.
+    pruneKeep int
+    // pruneOlderThan is set by `cache prune --older-than`.
+    pruneOlderThan string
 )
 
 // cacheCmd represents the cache command
 var cacheCmd = &cobra.Command{
     Use:   "cache",
-    Short: "A brief description of your command",
-    Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-    Run: func(cmd *cobra.Command, args []string) {
-        fmt.Println("cache called")
+    Short: "Manage the rpm-get cache",
+    Long: `Manage the rpm-get cache (/var/cache/rpm-get), which holds the downloaded RPMs
+of direct download packages and the responses of the GitHub/GitLab APIs.`,
+    Run: func(cmd *cobra.Command, _ []string) {
+        _ = cmd.Help(); os.Exit(h.USAGE_EXIT_CODE)
+    },
+}
+
+// cacheListCmd represents the cache list command
+var cacheListCmd = &cobra.Command{
+    Use:   "list",
+    Short: "List the cached RPMs and API responses",
+    Long: "List the cached RPMs and API responses, with their size and age.",
+    Run: func(_ *cobra.Command, _ []string) { runCacheCmd(listCache) },
+}
+
+// cacheSizeCmd represents the cache size command
+var cacheSizeCmd = &cobra.Command{
+    Use:   "size",
+    Short: "Show the size of the cache",
+    Long: "Show the size of the cached RPMs and API responses.",
+    Run: func(_ *cobra.Command, _ []string) { runCacheCmd(printCacheSize) },
+}
+
+// cacheCleanCmd represents the cache clean command
+var cacheCleanCmd = &cobra.Command{
+    Use:   "clean [pkg...]",
+    Short: "Remove cached files",
+    Long: `Remove the cached RPMs of the given packages.
+When no package is given, remove every cached RPM and API response.`,
+    Run: func(_ *cobra.Command, args []string) {
+        if !isAdmin() {
+            h.Printc("rpm-get must be run as root!", h.ERROR, false)
+            os.Exit(h.ERROR_EXIT_CODE)
+        }
+
+        runCacheCmd(func() error { return cleanCache(CacheDir, args) })
+    },
+}
+
+// cachePruneCmd represents the cache prune command
+var cachePruneCmd = &cobra.Command{
+    Use:   "prune [--keep N] [--older-than AGE]",
+    Short: "Remove old cached RPMs",
+    Long: `Remove old cached RPMs, keeping only the newest --keep builds of each package,
+and/or only removing the files older than --older-than (e.g. 30d, 2w or 12h).
+When both are provided, only the files matching both are removed.
+The RPMs of installed packages are always kept, as reinstall uses them.
+With --older-than, partial downloads and API responses older than it are removed too.`,
+    Run: func(cmd *cobra.Command, _ []string) {
+        if pruneKeep <= 0 && pruneOlderThan == "" {
+            h.Printc("--keep or --older-than is required", h.ERROR, false)
+            _ = cmd.Usage(); os.Exit(h.USAGE_EXIT_CODE)
+        }
+
+        olderThan, err := parseAge(pruneOlderThan)
+        if err != nil {
+            h.Printc(err.Error(), h.ERROR, false)
+            _ = cmd.Usage(); os.Exit(h.USAGE_EXIT_CODE)
+        }
+
+        if !isAdmin() {
+            h.Printc("rpm-get must be run as root!", h.ERROR, false)
+            os.Exit(h.ERROR_EXIT_CODE)
+        }
+
+        db := readState()
+        installed := lo.Map(db.List(), func(pkg *state.Package, _ int) string { return pkg.File })
+        _ = db.Close()
+
+        runCacheCmd(func() error { return pruneCache(CacheDir, pruneKeep, olderThan, installed) })
     },
 }
 
 func init() {
     rootCmd.AddCommand(cacheCmd)
+    cacheCmd.AddCommand(cacheListCmd, cacheSizeCmd, cacheCleanCmd, cachePruneCmd)
 
-    // Here you will define your flags and configuration settings.
+    cachePruneCmd.Flags().IntVar(&pruneKeep, "keep", 0, "Number of builds to keep for each package")
+    cachePruneCmd.Flags().StringVar(&pruneOlderThan, "older-than", "", "Only remove files older than this age, e.g. 30d")
+}
 
-    // Cobra supports Persistent Flags which will work for this command
-    // and all subcommands, e.g.:
-    // cacheCmd.PersistentFlags().String("foo", "", "A help for foo")
+// cacheFile is a file of the cache.
+type cacheFile struct {
+    // Package the RPM belongs to, empty for API responses
+    pkg string
+    // File name of the RPM, or URL of the API response
+    name string
+    path string
+    size int64
+    // When the RPM was downloaded, or the API response was fetched
+    modTime time.Time
+    // Whether the RPM is a partial download
+    partial bool
+}
 
-    // Cobra supports local flags which will only run when this command
-    // is called directly, e.g.:
-    // cacheCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+// runCacheCmd runs a cache subcommand, exiting with an error if it fails.
+func runCacheCmd(run func() error) {
+    if err := run(); err != nil {
+        h.Printc(err.Error(), h.ERROR, false)
+        os.Exit(h.ERROR_EXIT_CODE)
+    }
+}
+
+// cachedRpms returns the cached RPMs, and partial downloads, of every package in the given
+// cache directory, sorted by package then newest first.
+func cachedRpms(cacheDir string) ([]cacheFile, error) {
+    dirs, err := os.ReadDir(cacheDir)
+    if errors.Is(err, os.ErrNotExist) { return nil, nil }
+    if err != nil { return nil, fmt.Errorf("Failed to read the cache: %w", err) }
+
+    result := []cacheFile {}
+    for _, dir := range dirs {
+        if !dir.IsDir() || dir.Name() == API_CACHE_NAME { continue }
+
+        entries, readErr := os.ReadDir(filepath.Join(cacheDir, dir.Name()))
+        if readErr != nil { return nil, fmt.Errorf("Failed to read the cache: %w", readErr) }
+
+        for _, entry := range entries {
+            info, infoErr := entry.Info()
+            name := entry.Name()
+            partial := strings.HasSuffix(name, ".rpm" + PART_EXT)
+            if infoErr != nil || !info.Mode().IsRegular() || !(partial || strings.HasSuffix(name, ".rpm")) { continue }
+
+            result = append(result, cacheFile {
+                pkg: dir.Name(), name: name, path: filepath.Join(cacheDir, dir.Name(), name),
+                size: info.Size(), modTime: info.ModTime(), partial: partial,
+            })
+        }
+    }
+
+    slices.SortFunc(result, func(a cacheFile, b cacheFile) int {
+        if result := strings.Compare(a.pkg, b.pkg); result != 0 { return result }
+        return b.modTime.Compare(a.modTime)
+    })
+    return result, nil
+}
+
+// cachedResponses returns the cached API responses of the given cache directory, oldest first.
+func cachedResponses(cacheDir string) ([]cacheFile, error) {
+    responses, err := release.NewCache(filepath.Join(cacheDir, API_CACHE_NAME)).List()
+    if err != nil { return nil, err }
+
+    return lo.Map(responses, func(resp release.CachedResponse, _ int) cacheFile {
+        return cacheFile { name: resp.Url, path: resp.Path, size: resp.Size, modTime: resp.FetchedAt }
+    }), nil
+}
+
+// listCache prints the cached RPMs and API responses.
+func listCache() error {
+    rpms, rpmsErr := cachedRpms(CacheDir)
+    if rpmsErr != nil { return rpmsErr }
+    responses, responsesErr := cachedResponses(CacheDir)
+    if responsesErr != nil { return responsesErr }
+
+    for _, file := range rpms {
+        fmt.Printf("%s\t%s%s\t%s\t%s\n", file.pkg, file.name, lo.Ternary(file.partial, " (partial)", ""),
+            formatSize(file.size), formatAge(file.modTime))
+    }
+    for _, file := range responses {
+        fmt.Printf("api\t%s\t%s\t%s\n", file.name, formatSize(file.size), formatAge(file.modTime))
+    }
+
+    return nil
+}
+
+// printCacheSize prints the size of the cached RPMs and API responses.
+func printCacheSize() error {
+    rpms, rpmsErr := cachedRpms(CacheDir)
+    if rpmsErr != nil { return rpmsErr }
+    responses, responsesErr := cachedResponses(CacheDir)
+    if responsesErr != nil { return responsesErr }
+
+    rpmsSize, responsesSize := totalSize(rpms), totalSize(responses)
+    fmt.Printf("RPMs:\t%s\t(%d files)\n", formatSize(rpmsSize), len(rpms))
+    fmt.Printf("API responses:\t%s\t(%d files)\n", formatSize(responsesSize), len(responses))
+    fmt.Printf("Total:\t%s\n", formatSize(rpmsSize + responsesSize))
+
+    return nil
+}
+
+// cleanCache removes the cached RPMs of the given packages from the given cache directory,
+// or the whole cache when none are given.
+func cleanCache(cacheDir string, pkgs []string) error {
+    rpms, rpmsErr := cachedRpms(cacheDir)
+    if rpmsErr != nil { return rpmsErr }
+
+    dirs := []string {}
+    freed := int64(0)
+    if len(pkgs) == 0 {
+        responses, err := cachedResponses(cacheDir)
+        if err != nil { return err }
+
+        dirs = append(dirs, API_CACHE_NAME)
+        dirs = append(dirs, lo.Uniq(lo.Map(rpms, func(file cacheFile, _ int) string { return file.pkg }))...)
+        freed = totalSize(rpms) + totalSize(responses)
+    }
+    for _, pkg := range pkgs {
+        if !manifest.ValidName(pkg) { return fmt.Errorf("Invalid package name: %q", pkg) }
+
+        dirs = append(dirs, pkg)
+        freed += totalSize(lo.Filter(rpms, func(file cacheFile, _ int) bool { return file.pkg == pkg }))
+    }
+
+    for _, dir := range dirs {
+        if err := os.RemoveAll(filepath.Join(cacheDir, dir)); err != nil {
+            return fmt.Errorf("Failed to clean the cache: %w", err)
+        }
+    }
+
+    h.Printc(fmt.Sprintf("Removed %s from the cache", formatSize(freed)), h.INFO, true)
+    return nil
+}
+
+// pruneCache removes the cached RPMs of the given cache directory beyond the newest `keep`
+// builds of each package (every build is kept when `keep` is 0) that are older than `olderThan`
+// (any age when it's 0). The `installed` RPMs are kept. When `olderThan` is set, partial
+// downloads and API responses older than it are removed too.
+func pruneCache(cacheDir string, keep int, olderThan time.Duration, installed []string) error {
+    rpms, rpmsErr := cachedRpms(cacheDir)
+    if rpmsErr != nil { return rpmsErr }
+    responses, responsesErr := cachedResponses(cacheDir)
+    if responsesErr != nil { return responsesErr }
+
+    isOld := func(file cacheFile) bool { return olderThan > 0 && time.Since(file.modTime) > olderThan }
+
+    removed := []cacheFile {}
+    builds := map[string]int {}
+    for _, file := range rpms {
+        if file.partial {
+            if isOld(file) { removed = append(removed, file) }
+            continue
+        }
+
+        // `rpms` is sorted newest first, so this is the number of newer builds of the package.
+        newer := builds[file.pkg]
+        builds[file.pkg]++
+
+        switch {
+        case slices.Contains(installed, file.path):
+            continue
+        case keep > 0 && newer < keep:
+            continue
+        case olderThan > 0 && !isOld(file):
+            continue
+        }
+        removed = append(removed, file)
+    }
+    if olderThan > 0 { removed = append(removed, lo.Filter(responses, func(file cacheFile, _ int) bool { return isOld(file) })...) }
+
+    for _, file := range removed {
+        if err := os.Remove(file.path); err != nil { return fmt.Errorf("Failed to prune the cache: %w", err) }
+        if file.partial { _ = os.Remove(file.path + PART_META_EXT) }
+    }
+
+    msg := fmt.Sprintf("Removed %d files (%s) from the cache", len(removed), formatSize(totalSize(removed)))
+    h.Printc(msg, h.INFO, true)
+    return nil
+}
+
+// totalSize returns the total size of the given files.
+func totalSize(files []cacheFile) int64 {
+    return lo.SumBy(files, func(file cacheFile) int64 { return file.size })
+}
+
+// parseAge parses an age such as `30d`, `2w` or `12h`. An empty string is a zero age.
+func parseAge(age string) (time.Duration, error) {
+    if age == "" { return 0, nil }
+
+    days := map[string]int { "d": 1, "w": 7 }
+    for suffix, multiplier := range days {
+        if number, ok := strings.CutSuffix(age, suffix); ok {
+            count, err := strconv.Atoi(number)
+            if err != nil || count < 0 { return 0, fmt.Errorf("Invalid age: %q", age) }
+            return time.Duration(count * multiplier) * 24 * time.Hour, nil
+        }
+    }
+
+    duration, err := time.ParseDuration(age)
+    if err != nil || duration < 0 { return 0, fmt.Errorf("Invalid age: %q, expected e.g. 30d, 2w or 12h", age) }
+    return duration, nil
+}
+
+// formatSize formats a size in bytes with a binary unit, e.g. `12.5 MiB`.
+func formatSize(size int64) string {
+    if size < 1024 { return fmt.Sprintf("%d B", size) }
+
+    value, unit := float64(size) / 1024, 0
+    units := []string { "KiB", "MiB", "GiB", "TiB" }
+    for value >= 1024 && unit < len(units) - 1 {
+        value /= 1024
+        unit++
+    }
+
+    return fmt.Sprintf("%.1f %s", value, units[unit])
+}
+
+// formatAge formats the time since the given time, e.g. `3d`, `5h` or `12m`.
+func formatAge(since time.Time) string {
+    age := time.Since(since)
+    switch {
+    case age >= 24 * time.Hour:
+        return fmt.Sprintf("%dd", int(age.Hours() / 24))
+    case age >= time.Hour:
+        return fmt.Sprintf("%dh", int(age.Hours()))
+    default:
+        return fmt.Sprintf("%dm", int(age.Minutes()))
+    }
 }
 
 // createCacheDir creates the cache directory.
 func createCacheDir() {
-    if err := os.MkdirAll(CACHE_DIR, 0755); err != nil {
+    if err := os.MkdirAll(CacheDir, 0755); err != nil {
         h.Printc("Unable to create cache dir!", h.ERROR, false)
         os.Exit(h.ERROR_EXIT_CODE)
     }
@@ -47,7 +348,7 @@ func createCacheDir() {
 
 // createEtcDir creates the etc directory.
 func createEtcDir() {
-    if err := os.MkdirAll(ETC_DIR, 0755); err != nil {
+    if err := os.MkdirAll(StateDir, 0755); err != nil {
         h.Printc("Unable to create etc dir!", h.ERROR, false)
         os.Exit(h.ERROR_EXIT_CODE)
     }
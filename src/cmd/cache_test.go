@@ -0,0 +1,157 @@
+package cmd
+
+import (
+    "os"
+    "path/filepath"
+    "slices"
+    "testing"
+    "time"
+)
+
+// cacheFixture is a file of a test cache, relative to the cache directory.
+type cacheFixture struct {
+    path string
+    age time.Duration
+}
+
+// writeCache creates the given files in a new cache directory, with the given modification times.
+func writeCache(t *testing.T, files []cacheFixture) string {
+    t.Helper()
+    dir := t.TempDir()
+
+    for _, file := range files {
+        filePath := filepath.Join(dir, file.path)
+        if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil { t.Fatal(err) }
+
+        content := []byte("rpm")
+        if filepath.Dir(file.path) == API_CACHE_NAME {
+            fetchedAt := time.Now().Add(-file.age).UTC().Format(time.RFC3339)
+            content = []byte(`{"url":"https://api.github.com/` + file.path + `","fetched_at":"` + fetchedAt + `","body":{}}`)
+        }
+        if err := os.WriteFile(filePath, content, 0644); err != nil { t.Fatal(err) }
+
+        modTime := time.Now().Add(-file.age)
+        if err := os.Chtimes(filePath, modTime, modTime); err != nil { t.Fatal(err) }
+    }
+
+    return dir
+}
+
+// remainingFiles returns the files left in the given cache directory, relative to it.
+func remainingFiles(t *testing.T, dir string) []string {
+    t.Helper()
+
+    result := []string {}
+    err := filepath.WalkDir(dir, func(filePath string, entry os.DirEntry, err error) error {
+        if err != nil || entry.IsDir() { return err }
+        rel, relErr := filepath.Rel(dir, filePath)
+        result = append(result, rel)
+        return relErr
+    })
+    if err != nil { t.Fatal(err) }
+
+    slices.Sort(result)
+    return result
+}
+
+func TestCleanCache(t *testing.T) {
+    files := []cacheFixture {
+        { "app/app-1.0.rpm", 0 },
+        { "app/app-2.0.rpm.part", 0 },
+        { "app/app-2.0.rpm.part.json", 0 },
+        { "tool/tool-1.0.rpm", 0 },
+        { API_CACHE_NAME + "/releases.json", 0 },
+        { API_CACHE_NAME + "/rate-limits.json", 0 },
+    }
+
+    dir := writeCache(t, files)
+    if err := cleanCache(dir, []string { "app" }); err != nil { t.Fatal(err) }
+    want := []string { API_CACHE_NAME + "/rate-limits.json", API_CACHE_NAME + "/releases.json", "tool/tool-1.0.rpm" }
+    if got := remainingFiles(t, dir); !slices.Equal(got, want) { t.Errorf("cleanCache(app) left %v, want %v", got, want) }
+
+    dir = writeCache(t, files)
+    if err := cleanCache(dir, nil); err != nil { t.Fatal(err) }
+    if got := remainingFiles(t, dir); len(got) != 0 { t.Errorf("cleanCache() left %v, want nothing", got) }
+
+    if err := cleanCache(dir, []string { "../etc" }); err == nil { t.Error("cleanCache() accepted an invalid package name") }
+}
+
+func TestPruneCache(t *testing.T) {
+    day := 24 * time.Hour
+    files := []cacheFixture {
+        { "app/app-4.0.rpm", 1 * day },
+        { "app/app-3.0.rpm", 10 * day },
+        { "app/app-2.0.rpm", 40 * day },
+        { "app/app-1.0.rpm", 50 * day },
+        { "app/app-5.0.rpm.part", 40 * day },
+        { "tool/tool-1.0.rpm", 40 * day },
+        { API_CACHE_NAME + "/old.json", 40 * day },
+        { API_CACHE_NAME + "/new.json", 0 },
+    }
+
+    tests := []struct {
+        keep int
+        olderThan time.Duration
+        removed []string
+    }{
+        // The installed app-1.0 is kept, whatever its age.
+        { 2, 0, []string { "app/app-2.0.rpm" } },
+        { 1, 0, []string { "app/app-2.0.rpm", "app/app-3.0.rpm" } },
+        // Partial downloads and API responses are only removed by age.
+        { 0, 30 * day, []string { API_CACHE_NAME + "/old.json", "app/app-2.0.rpm", "app/app-5.0.rpm.part", "tool/tool-1.0.rpm" } },
+        // With both, only the files beyond the newest builds that are old enough are removed.
+        { 1, 30 * day, []string { API_CACHE_NAME + "/old.json", "app/app-2.0.rpm", "app/app-5.0.rpm.part" } },
+        { 1, 5 * day, []string { API_CACHE_NAME + "/old.json", "app/app-2.0.rpm", "app/app-3.0.rpm", "app/app-5.0.rpm.part" } },
+    }
+
+    for _, test := range tests {
+        dir := writeCache(t, files)
+        installed := []string { filepath.Join(dir, "app/app-1.0.rpm") }
+        if err := pruneCache(dir, test.keep, test.olderThan, installed); err != nil { t.Fatal(err) }
+
+        remaining := remainingFiles(t, dir)
+        removed := []string {}
+        for _, file := range files {
+            if !slices.Contains(remaining, file.path) { removed = append(removed, file.path) }
+        }
+        slices.Sort(removed)
+
+        if !slices.Equal(removed, test.removed) {
+            t.Errorf("pruneCache(keep %d, older than %v) removed %v, want %v", test.keep, test.olderThan, removed, test.removed)
+        }
+    }
+}
+
+func TestParseAge(t *testing.T) {
+    tests := map[string]time.Duration {
+        "": 0,
+        "30d": 30 * 24 * time.Hour,
+        "2w": 14 * 24 * time.Hour,
+        "12h": 12 * time.Hour,
+        "90m": 90 * time.Minute,
+    }
+    for age, want := range tests {
+        if got, err := parseAge(age); err != nil || got != want {
+            t.Errorf("parseAge(%q) = %v, %v, want %v", age, got, err, want)
+        }
+    }
+
+    for _, age := range []string { "d", "-1d", "1.5d", "soon", "-3h" } {
+        if _, err := parseAge(age); err == nil { t.Errorf("parseAge(%q) succeeded", age) }
+    }
+}
+
+func TestFormatSize(t *testing.T) {
+    tests := map[int64]string {
+        0: "0 B",
+        1023: "1023 B",
+        1536: "1.5 KiB",
+        5 << 20: "5.0 MiB",
+        3 << 30: "3.0 GiB",
+        2 << 40: "2.0 TiB",
+        4096 << 40: "4096.0 TiB",
+    }
+    for size, want := range tests {
+        if got := formatSize(size); got != want { t.Errorf("formatSize(%d) = %q, want %q", size, got, want) }
+    }
+}
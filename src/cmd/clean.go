@@ -5,36 +5,195 @@
 package cmd
 
 import (
+    "errors"
     "fmt"
+    "os"
+    "path/filepath"
+    "slices"
+    "time"
 
+    "github.com/FlawlessCasual17/rpm-get/backend"
+    h "github.com/FlawlessCasual17/rpm-get/helpers"
+    "github.com/FlawlessCasual17/rpm-get/state"
+    "github.com/samber/lo"
     "github.com/spf13/cobra"
 )
 
+// TMP_MIN_AGE is how old a temporary file must be to be considered left over, so that
+// the files of an rpm-get process that is still running are left alone.
+const TMP_MIN_AGE time.Duration = time.Hour
+
+// wantsYes is set by `clean --yes`.
+var wantsYes bool
+
 // cleanCmd represents the clean command
 var cleanCmd = &cobra.Command{
-    Use:   "clean",
-    Short: "A brief description of your command",
-    Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-    Run: func(cmd *cobra.Command, args []string) {
-        fmt.Println("clean called")
+    Use:   "clean [--yes]",
+    Short: "Remove what rpm-get left behind",
+    Long: `List what rpm-get left behind, and remove it when --yes is provided:
+  - repo files added by rpm-get whose packages are no longer installed
+  - temporary files left over by interrupted commands
+  - cached RPMs of packages that are no longer installed
+  - installed packages that were since removed with rpm, or another package manager`,
+    Run: func(_ *cobra.Command, _ []string) {
+        if err := cleanUp(wantsYes); err != nil {
+            h.Printc(err.Error(), h.ERROR, false)
+            os.Exit(h.ERROR_EXIT_CODE)
+        }
     },
 }
 
 func init() {
     rootCmd.AddCommand(cleanCmd)
 
-    // Here you will define your flags and configuration settings.
+    cleanCmd.Flags().BoolVarP(&wantsYes, "yes", "y", false, "Remove what was found")
+}
+
+// leftovers is what rpm-get left behind.
+type leftovers struct {
+    // Packages recorded in the state whose RPM is no longer installed
+    pkgs []*state.Package
+    // Repo files that no installed package uses
+    repos []*state.Repo
+    // Temporary files of interrupted commands
+    tmpFiles []string
+    // Cached RPMs of packages that are no longer installed
+    rpms []cacheFile
+}
+
+// cleanUp lists what rpm-get left behind, and removes it when `apply` is set.
+func cleanUp(apply bool) error {
+    if apply && !isAdmin() {
+        h.Printc("rpm-get must be run as root!", h.ERROR, false)
+        os.Exit(h.ERROR_EXIT_CODE)
+    }
+
+    db := lo.Ternary(apply, openState, readState)()
+    //nolint:errcheck
+    defer db.Close()
+
+    found, err := findLeftovers(db)
+    if err != nil { return err }
+
+    reposDir := pkgManager().ReposDir()
+    printLeftovers("Repo files of packages that are no longer installed",
+        lo.Map(found.repos, func(repo *state.Repo, _ int) string { return filepath.Join(reposDir, repo.File) }))
+    printLeftovers("Leftover temporary files", found.tmpFiles)
+    printLeftovers("Cached RPMs of packages that are no longer installed",
+        lo.Map(found.rpms, func(file cacheFile, _ int) string { return file.path + " (" + formatSize(file.size) + ")" }))
+    printLeftovers("Packages that were removed outside of rpm-get",
+        lo.Map(found.pkgs, func(pkg *state.Package, _ int) string { return pkg.Name + " (" + pkg.RpmName + ")" }))
+
+    if len(found.repos) + len(found.tmpFiles) + len(found.rpms) + len(found.pkgs) == 0 {
+        h.Printc("Nothing to clean up", h.INFO, true)
+        return nil
+    }
+    if !apply {
+        h.Printc("Run `rpm-get clean --yes` to remove them", h.INFO, false)
+        return nil
+    }
+
+    return removeLeftovers(db, found)
+}
+
+// findLeftovers looks for what rpm-get left behind.
+func findLeftovers(db *state.DB) (leftovers, error) {
+    found := leftovers {}
+
+    installed := []*state.Package {}
+    for _, pkg := range db.List() {
+        _, err := pkgManager().InstalledVersion(pkg.RpmName)
+        switch {
+        case errors.Is(err, backend.ErrNotInstalled):
+            found.pkgs = append(found.pkgs, pkg)
+        case err != nil:
+            return found, err
+        default:
+            installed = append(installed, pkg)
+        }
+    }
+
+    reposDir := pkgManager().ReposDir()
+    for _, repo := range db.ListRepos() {
+        used := slices.ContainsFunc(installed, func(pkg *state.Package) bool { return pkg.RepoFile == repo.File })
+        if !used && reposDir != "" && fileExists(filepath.Join(reposDir, repo.File)) { found.repos = append(found.repos, repo) }
+    }
+
+    found.tmpFiles = leftoverTmpFiles(reposDir)
+
+    rpms, err := cachedRpms(CacheDir)
+    if err != nil { return found, err }
+    found.rpms = lo.Filter(rpms, func(file cacheFile, _ int) bool {
+        return !slices.ContainsFunc(installed, func(pkg *state.Package) bool { return pkg.Name == file.pkg })
+    })
+
+    return found, nil
+}
+
+// leftoverTmpFiles returns the temporary files written by `addRepo`, `getUpdates` and the state,
+// older than `TMP_MIN_AGE`. Backends without repos, such as plain rpm, have no `reposDir`.
+func leftoverTmpFiles(reposDir string) []string {
+    patterns := []string {
+        filepath.Join(DataDir, "*.tmp"),
+        filepath.Join(StateDir, "*.tmp"),
+        filepath.Join(CacheDir, API_CACHE_NAME, "*.tmp"),
+    }
+    // An empty directory would match the files of the working directory.
+    if reposDir != "" { patterns = append(patterns, filepath.Join(reposDir, "*.repo.tmp")) }
+
+    result := []string {}
+    for _, pattern := range patterns {
+        matches, _ := filepath.Glob(pattern)
+        for _, match := range matches {
+            // The index symlink is swapped in through a temporary symlink, which must not be followed.
+            info, err := os.Lstat(match)
+            if err != nil || info.IsDir() || time.Since(info.ModTime()) < TMP_MIN_AGE { continue }
+            result = append(result, match)
+        }
+    }
+
+    return lo.Uniq(result)
+}
+
+// printLeftovers prints the given leftovers under a title, unless there are none.
+func printLeftovers(title string, items []string) {
+    if len(items) == 0 { return }
+
+    fmt.Println(title + ":")
+    for _, item := range items { fmt.Println("    " + item) }
+}
+
+// removeLeftovers removes the given leftovers, and forgets about them.
+func removeLeftovers(db *state.DB, found leftovers) error {
+    errs := []error {}
+
+    for _, repo := range found.repos {
+        if err := pkgManager().RemoveRepo(repo.File); err != nil {
+            errs = append(errs, err)
+            continue
+        }
+        db.DeleteRepo(repo.File)
+    }
+
+    for _, tmpFile := range found.tmpFiles {
+        if err := os.Remove(tmpFile); err != nil { errs = append(errs, err) }
+    }
+
+    for _, file := range found.rpms {
+        if err := os.Remove(file.path); err != nil {
+            errs = append(errs, err)
+            continue
+        }
+        if file.partial { _ = os.Remove(file.path + PART_META_EXT) }
+        // Only the package directory is removed, and only once it's empty.
+        _ = os.Remove(filepath.Dir(file.path))
+    }
+
+    for _, pkg := range found.pkgs { db.Delete(pkg.Name) }
 
-    // Cobra supports Persistent Flags which will work for this command
-    // and all subcommands, e.g.:
-    // cleanCmd.PersistentFlags().String("foo", "", "A help for foo")
+    if err := db.Save(); err != nil { errs = append(errs, fmt.Errorf("Failed to record the clean up: %w", err)) }
+    if err := errors.Join(errs...); err != nil { return fmt.Errorf("Failed to clean up: %w", err) }
 
-    // Cobra supports local flags which will only run when this command
-    // is called directly, e.g.:
-    // cleanCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+    h.Printc("Successfully cleaned up", h.INFO, true)
+    return nil
 }
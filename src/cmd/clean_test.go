@@ -0,0 +1,135 @@
+package cmd
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "slices"
+    "testing"
+    "time"
+
+    "github.com/FlawlessCasual17/rpm-get/state"
+)
+
+// writeFile writes a file, creating its directory, with a modification time `age` in the past.
+func writeFile(t *testing.T, filePath string, age time.Duration) {
+    t.Helper()
+
+    if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil { t.Fatal(err) }
+    if err := os.WriteFile(filePath, []byte("content"), 0644); err != nil { t.Fatal(err) }
+
+    modTime := time.Now().Add(-age)
+    if err := os.Chtimes(filePath, modTime, modTime); err != nil { t.Fatal(err) }
+}
+
+// setUpLeftovers records an installed repo package, a repo package and a direct download package
+// that were removed outside of rpm-get, and writes their files and some temporary files.
+func setUpLeftovers(t *testing.T) (*fakeBackend, *state.DB) {
+    t.Helper()
+    useTestDirs(t)
+
+    fake := newFakeBackend(t)
+    fake.reposDir = t.TempDir()
+    fake.installed["app"] = "0:1.0-1"
+
+    db := openTestState(t)
+    db.Put(&state.Package { Name: "app", RpmName: "app", Source: state.SOURCE_REPO, RepoFile: "app.repo" })
+    db.Put(&state.Package { Name: "gone", RpmName: "gone-bin", Source: state.SOURCE_REPO, RepoFile: "gone.repo" })
+    db.Put(&state.Package { Name: "tool", RpmName: "tool", Source: state.SOURCE_DIRECT })
+    for _, file := range []string { "app.repo", "gone.repo", "missing.repo" } {
+        db.PutRepo(&state.Repo { File: file, AddedAt: time.Now() })
+    }
+    if err := db.Save(); err != nil { t.Fatal(err) }
+
+    for _, file := range []string { "app.repo", "gone.repo" } {
+        writeFile(t, filepath.Join(fake.reposDir, file), 0)
+        fake.repos[file] = []byte("[repo]")
+    }
+    writeFile(t, filepath.Join(fake.reposDir, "new.repo.tmp"), 2 * TMP_MIN_AGE)
+    writeFile(t, filepath.Join(DataDir, "validators.json.tmp"), 2 * TMP_MIN_AGE)
+    // Too recent, it may belong to a running rpm-get.
+    writeFile(t, filepath.Join(StateDir, "state.json.tmp"), 0)
+    writeFile(t, filepath.Join(CacheDir, "app", "app-1.0.rpm"), 0)
+    writeFile(t, filepath.Join(CacheDir, "tool", "tool-1.0.rpm"), 0)
+
+    return fake, db
+}
+
+func TestFindLeftovers(t *testing.T) {
+    fake, db := setUpLeftovers(t)
+
+    found, err := findLeftovers(db)
+    if err != nil { t.Fatal(err) }
+
+    pkgs := []string {}
+    for _, pkg := range found.pkgs { pkgs = append(pkgs, pkg.Name) }
+    slices.Sort(pkgs)
+    if want := []string { "gone", "tool" }; !slices.Equal(pkgs, want) { t.Errorf("findLeftovers() packages = %v, want %v", pkgs, want) }
+
+    // Only existing repo files that no installed package uses are left over.
+    if len(found.repos) != 1 || found.repos[0].File != "gone.repo" {
+        t.Errorf("findLeftovers() repos = %v, want gone.repo", found.repos)
+    }
+
+    tmpFiles := []string { filepath.Join(DataDir, "validators.json.tmp"), filepath.Join(fake.reposDir, "new.repo.tmp") }
+    if !slices.Equal(found.tmpFiles, tmpFiles) { t.Errorf("findLeftovers() temporary files = %v, want %v", found.tmpFiles, tmpFiles) }
+
+    if len(found.rpms) != 1 || found.rpms[0].pkg != "tool" {
+        t.Errorf("findLeftovers() RPMs = %v, want the one of tool", found.rpms)
+    }
+}
+
+func TestFindLeftoversWithoutReposDir(t *testing.T) {
+    fake, db := setUpLeftovers(t)
+    fake.reposDir = ""
+
+    // The repo files of the working directory are not temporary files of rpm-get.
+    workDir := t.TempDir()
+    writeFile(t, filepath.Join(workDir, "mine.repo.tmp"), 2 * TMP_MIN_AGE)
+    writeFile(t, filepath.Join(workDir, "gone.repo"), 0)
+    t.Chdir(workDir)
+
+    found, err := findLeftovers(db)
+    if err != nil { t.Fatal(err) }
+    if want := []string { filepath.Join(DataDir, "validators.json.tmp") }; !slices.Equal(found.tmpFiles, want) {
+        t.Errorf("findLeftovers() temporary files = %v, want %v", found.tmpFiles, want)
+    }
+    if len(found.repos) != 0 { t.Errorf("findLeftovers() repos = %v, want none", found.repos) }
+}
+
+func TestFindLeftoversQueryError(t *testing.T) {
+    fake, db := setUpLeftovers(t)
+    fake.queryErr = errors.New("rpmdb is locked")
+
+    // A package manager that can't tell whether a package is installed doesn't make it a leftover.
+    if _, err := findLeftovers(db); !errors.Is(err, fake.queryErr) {
+        t.Errorf("findLeftovers() = %v, want %v", err, fake.queryErr)
+    }
+}
+
+func TestRemoveLeftovers(t *testing.T) {
+    fake, db := setUpLeftovers(t)
+
+    found, err := findLeftovers(db)
+    if err != nil { t.Fatal(err) }
+    if err := removeLeftovers(db, found); err != nil { t.Fatal(err) }
+
+    if db.Get("app") == nil || db.Get("gone") != nil || db.Get("tool") != nil {
+        t.Errorf("removeLeftovers() left the packages %v, want app", db.List())
+    }
+
+    repos := []string {}
+    for _, repo := range db.ListRepos() { repos = append(repos, repo.File) }
+    if want := []string { "app.repo", "missing.repo" }; !slices.Equal(repos, want) {
+        t.Errorf("removeLeftovers() left the repos %v, want %v", repos, want)
+    }
+    if _, ok := fake.repos["gone.repo"]; ok { t.Error("removeLeftovers() didn't remove gone.repo") }
+    if _, ok := fake.repos["app.repo"]; !ok { t.Error("removeLeftovers() removed app.repo") }
+
+    for _, filePath := range append(found.tmpFiles, filepath.Join(CacheDir, "tool")) {
+        if _, err := os.Stat(filePath); !errors.Is(err, os.ErrNotExist) { t.Errorf("removeLeftovers() kept %s", filePath) }
+    }
+    for _, filePath := range []string { filepath.Join(StateDir, "state.json.tmp"), filepath.Join(CacheDir, "app", "app-1.0.rpm") } {
+        if _, err := os.Stat(filePath); err != nil { t.Errorf("removeLeftovers() removed %s", filePath) }
+    }
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+    "errors"
+    "fmt"
+    "os"
+
+    "github.com/goccy/go-json"
+)
+
+// Config is the content of `ConfigFile`.
+type Config struct {
+    // minisign public key trusted to sign the package index, in place of `IndexPublicKey`
+    IndexPublicKey string   `json:"index_public_key,omitempty"`
+    // PEM file of a CA to trust in addition to the system ones, e.g. for a TLS intercepting proxy
+    CaBundle string         `json:"ca_bundle,omitempty"`
+    // GitLab access tokens of self-hosted instances by host, e.g. `gitlab.example.com`.
+    // `GITLAB_TOKEN` is only sent to gitlab.com.
+    GitlabTokens map[string]string   `json:"gitlab_tokens,omitempty"`
+}
+
+// readConfig reads `ConfigFile`. A missing file is the same as an empty one.
+func readConfig() (*Config, error) {
+    config := &Config {}
+
+    content, readErr := os.ReadFile(ConfigFile)
+    if errors.Is(readErr, os.ErrNotExist) { return config, nil }
+    if readErr != nil { return nil, fmt.Errorf("Failed to read config: %w", readErr) }
+
+    if err := json.Unmarshal(content, config); err != nil {
+        return nil, fmt.Errorf("Failed to parse %s: %w", ConfigFile, err)
+    }
+
+    return config, nil
+}
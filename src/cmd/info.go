@@ -1,16 +1,14 @@
 package cmd
 
 import (
-    // "bytes"
+    "errors"
     "fmt"
     "os"
     "path/filepath"
-    // "regexp"
     "strings"
 
     h "github.com/FlawlessCasual17/rpm-get/helpers"
-    // "github.com/goccy/go-json"
-    "github.com/goccy/go-yaml"
+    "github.com/FlawlessCasual17/rpm-get/manifest"
     "github.com/spf13/cobra"
 )
 
@@ -29,182 +27,6 @@
     },
 }
 
-type LicenseObject struct {
-    // Package license based on SPDX license format, and license list: https://spdx.org/licenses/
-    identifier string   `yaml:"identifier"`
-    // License URL
-    url string          `yaml:"url"`
-}
-
-// Package license based on SPDX license format, and license list: https://spdx.org/licenses/
-type License struct {
-    // Package license based on SPDX license format, and license list: https://spdx.org/licenses/
-    licenseString string     `yaml:",omitempty"`
-    // Package license based on SPDX license format, and license list: https://spdx.org/licenses/
-    license *LicenseObject   `yaml:",omitempty"`
-}
-
-type PkgArch struct {
-    // Download URL for the architecture
-    url string    `yaml:"url"`
-}
-
-type UrlRepo struct {
-    // Package repository URL
-    url string         `yaml:"url"`
-    // Repository GPG key URL
-    gpgKeyUrl string   `yaml:"gpg_key_url"`
-}
-
-type CoprRepo struct {
-    // Copr user name
-    username string   `yaml:"username"`
-    // Copr project name
-    project string    `yaml:"project"`
-}
-
-// Information about an RPM/Copr repository
-type Repo struct {
-    urlRepo *UrlRepo     `yaml:",omitempty"`
-    coprRepo *CoprRepo   `yaml:",omitempty"`
-}
-
-// Schema for package manifests
-type Pkg struct {
-    // List of operating systems (that use RPM) supported by this package
-    supported_os []string              `yaml:"supported_os"`
-    // Package version
-    version string                     `yaml:"version"`
-    // Package name
-    name string                        `yaml:"name"`
-    // Package license based on SPDX license format, and license list: https://spdx.org/licenses/
-    license *License                    `yaml:"license"`
-    // Package homepage
-    homepage string                    `yaml:"homepage"`
-    // Package description
-    description string                 `yaml:"description"`
-    // Additional notes about the package
-    notes string                       `yaml:"notes,omitempty"`
-    // Architecture-specific download information
-    pkg_arches []string                `yaml:"pkg_arches"`
-    arch struct {
-        x86_64 *PkgArch                `yaml:"x86_64,omitempty"`
-        x86 *PkgArch                   `yaml:"x86,omitempty"`
-        arm64 *PkgArch                 `yaml:"arm64,omitempty"`
-    }                                  `yaml:"arch"`
-    // Information about an RPM/Copr repository
-    repo *Repo                         `yaml:"repo,omitempty"`
-    // List of package dependencies
-    depends []string                   `yaml:"depends,omitempty"`
-    // List of recommended packages
-    recommends []string                `yaml:"recommends,omitempty"`
-    // List of suggested packages
-    suggests []string                  `yaml:"suggests,omitempty"`
-    // List of conflicting packages
-    conflicts []string                 `yaml:"conflicts,omitempty"`
-    // List of packages that this package replaces
-    replaces []string                  `yaml:"replaces,omitempty"`
-}
-
-// // parseJsonFile parses a JSON file using a given JSONPath and returns the result.
-// func parseJsonFile(filePath string, jsonpathExpr string) (string, error) {
-//     content, readErr := os.ReadFile(filePath)
-//     if readErr != nil {
-//         h.Printc("Failed to read file!", h.ERROR, false)
-//         return "", fmt.Errorf("Failed to read file: %w", readErr)
-//     }
-//
-//     value, err := parseJson(content, ".", "", jsonpathExpr)
-//     if err != nil {
-//         h.Printc("Failed to parse JSON!", h.ERROR, true)
-//         return "", fmt.Errorf("Failed to parse JSON: %w", err)
-//     }
-//
-//     return value, nil
-// }
-//
-// // parseJson parses JSON content and returns the matches of a given regex.
-// func parseJson(content []byte, regexStr string, regexRepl string, jsonpathExpr string) (string, error) {
-//     result := ""
-//     data := any (nil)
-//
-//     // Compile regex from string
-//     regex, regexErr := regexp.Compile(regexStr)
-//     if regexErr != nil {
-//         h.Printc("Failed to parse regex!", h.ERROR, false)
-//         return result, fmt.Errorf("Failed to parse regex: %w", regexErr)
-//     }
-//
-//     // Compile JSONPath from string
-//     jpath, jpathErr := json.CreatePath(jsonpathExpr)
-//     if jpathErr != nil {
-//         h.Printc("Failed to parse JSONPath!", h.ERROR, false)
-//         return result, fmt.Errorf("Failed to parse JSONPath: %w", jpathErr)
-//     }
-//
-//     if err := jpath.Unmarshal(content, &data); err != nil {
-//         h.Printc("Failed to parse JSON with JSONPath!", h.ERROR, false)
-//         return result, fmt.Errorf("Failed to parse JSON with JSONPath: %w", err)
-//     }
-//
-//     strValue, ok := data.(string)
-//     if !ok {
-//         println("JSONPath did not return a string value!")
-//         return result, fmt.Errorf("JSONPath did not return a string value: %T, expected string", data)
-//     }
-//
-//     if regexRepl != "" {
-//         matches := regex.ReplaceAllString(strValue, regexRepl)
-//         if regex.MatchString(strValue) { result = matches }
-//     } else {
-//         matches := regex.ReplaceAllString(strValue, "$1")
-//         if regex.MatchString(strValue) { result = matches }
-//     }
-//
-//     return result, nil
-// }
-//
-// func parseYaml(content []byte, regexStr string, regexRepl string, yamlpathExpr string) (string, error) {
-//     result := ""
-//     data := any (nil)
-//
-//     // Compile regex from string
-//     regex, regexErr := regexp.Compile(regexStr)
-//     if regexErr != nil {
-//         h.Printc("Failed to parse regex!", h.ERROR, false)
-//         return result, fmt.Errorf("Failed to parse regex: %w", regexErr)
-//     }
-//
-//     // Compile yamlpath from string
-//     yamlpath, yamlpathErr := yaml.PathString(yamlpathExpr)
-//     if yamlpathErr != nil {
-//         h.Printc("Failed to parse YAMLPath!", h.ERROR, false)
-//         return result, fmt.Errorf("Failed to parse YAMLPath: %w", yamlpathErr)
-//     }
-//
-//     contentReader := bytes.NewReader(content)
-//     if err := yamlpath.Read(contentReader, &data); err != nil {
-//         h.Printc("Failed to parse YAML with YAMLPath!", h.ERROR, false)
-//         return result, fmt.Errorf("Failed to parse YAML with YAMLPath: %w", err)
-//     }
-//
-//     strValue, ok := data.(string)
-//     if !ok {
-//         println("YAMLPath did not return a string value!")
-//         return result, fmt.Errorf("YAMLPath did not return a string value: %T, expected string", data)
-//     }
-//
-//     if regexRepl != "" {
-//         matches := regex.ReplaceAllString(strValue, regexRepl)
-//         if regex.MatchString(strValue) { result = matches }
-//     } else {
-//         matches := regex.ReplaceAllString(strValue, "$1")
-//         if regex.MatchString(strValue) { result = matches }
-//     }
-//
-//     return result, nil
-// }
-
 func init() {
     rootCmd.AddCommand(infoCmd)
 
@@ -222,19 +44,9 @@ func init() {
 // PkgInfo returns the package information for the given package.
 func pkgInfo(pkg string) (string, error) {
     result := ""
-    data := Pkg {}
-    filePath := filepath.Join(DataDir, pkg + ".yaml")
 
-    content, readErr := os.ReadFile(filePath)
-    if readErr != nil {
-        h.Printc("Failed to read file!", h.ERROR, false)
-        return result, fmt.Errorf("Failed to read file: %w", readErr)
-    }
-
-    if err := yaml.Unmarshal(content, &data); err != nil {
-        h.Printc("Failed to unmarshal file!", h.ERROR, false)
-        return result, fmt.Errorf("Failed to unmarshal file: %w", err)
-    }
+    data, err := loadPkg(pkg)
+    if err != nil { return result, err }
 
     result = fmt.Sprintf(`
         Supported OS: %s
@@ -246,15 +58,48 @@ func pkgInfo(pkg string) (string, error) {
         Notes: %s
         Pkg Arches: %s
         `,
-        strings.Join(data.supported_os, ", "),
-        data.version,
-        data.name,
-        data.license.licenseString,
-        data.homepage,
-        data.description,
-        data.notes,
-        strings.Join(data.pkg_arches, ", "),
+        strings.Join(data.SupportedOs, ", "),
+        data.Version,
+        data.Name,
+        data.License.Identifier(),
+        data.Homepage,
+        data.Description,
+        data.Notes,
+        strings.Join(data.PkgArches, ", "),
     )
 
     return result, nil
 }
+
+// loadPkg loads and validates the manifest of the given package from the package index.
+func loadPkg(pkg string) (*manifest.Pkg, error) {
+    if !manifest.ValidName(pkg) { return nil, fmt.Errorf("Invalid package name: %q", pkg) }
+    filePath := filepath.Join(indexDir(), pkg + ".yaml")
+
+    data, err := manifest.Load(filePath)
+    if err != nil {
+        h.Printc("Failed to load package manifest!", h.ERROR, false)
+        return nil, fmt.Errorf("Failed to load package manifest: %w", err)
+    }
+
+    return data, nil
+}
+
+// manifestNames returns the names of every package manifest in the package index.
+func manifestNames() ([]string, error) {
+    entries, err := os.ReadDir(indexDir())
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, errors.New("The package index is missing, run `rpm-get update` first")
+    } else if err != nil {
+        return nil, fmt.Errorf("Failed to read package manifests: %w", err)
+    }
+
+    names := []string {}
+    for _, entry := range entries {
+        if name, ok := strings.CutSuffix(entry.Name(), ".yaml"); ok && !entry.IsDir() {
+            names = append(names, name)
+        }
+    }
+
+    return names, nil
+}
@@ -1,40 +1,199 @@
 package cmd
 
 import (
-	"os"
-	"os/exec"
+    "fmt"
+    "net/url"
+    "os"
+    "path"
+    "path/filepath"
+    "slices"
+    "strings"
+    "time"
 
-	h "github.com/FlawlessCasual17/rpm-get/helpers"
-	"github.com/spf13/cobra"
+    "github.com/FlawlessCasual17/rpm-get/backend"
+    h "github.com/FlawlessCasual17/rpm-get/helpers"
+    "github.com/FlawlessCasual17/rpm-get/manifest"
+    "github.com/FlawlessCasual17/rpm-get/state"
+    "github.com/spf13/cobra"
 )
 
 // installCmd represents the install command
 var installCmd = &cobra.Command{
-    Use:   "install",
+    Use:   "install <pkg>...",
     Short: "Install packages",
-    Long: "Install packages",
-    Run: func(_ *cobra.Command, _ []string) {
-        /* getReleases() */
+    Long: `Install packages.
+Packages that are published in an RPM/Copr repository have their repository added first,
+while direct download packages are downloaded to the cache before being installed.
+All requested packages are installed in a single transaction.`,
+    Run: func(cmd *cobra.Command, args []string) {
+        if len(args) == 0 {
+            _ = cmd.Help(); os.Exit(h.USAGE_EXIT_CODE)
+        }
+
+        if err := installPkgs(args); err != nil {
+            h.Printc(err.Error(), h.ERROR, false)
+            os.Exit(h.ERROR_EXIT_CODE)
+        }
     },
 }
 
 func init() { rootCmd.AddCommand(installCmd) }
 
-// installPkg installs the requested RPM package.
-func installPkg(pkg string) {
+// installTarget is a package resolved from its manifest, ready to be installed.
+type installTarget struct {
+    pkg state.Package
+    // Path of the downloaded RPM, empty for repository packages
+    filePath string
+}
+
+// installPkgs resolves the given packages from their manifests,
+// and installs all of them in a single transaction.
+func installPkgs(pkgs []string) error {
     if !isAdmin() {
         h.Printc("rpm-get must be run as root!", h.ERROR, false)
         os.Exit(h.ERROR_EXIT_CODE)
     }
 
-    cmd := which("sudo") + " " + which("rpm")
-    args := []string { "-vi", pkg }
-    command := exec.Command(cmd, args...)
-    out, err := command.Output()
+    db := openState()
+    //nolint:errcheck
+    defer db.Close()
+
+    targets := []installTarget {}
+    for _, pkg := range pkgs {
+        target, err := resolveInstallTarget(db, pkg)
+        if err != nil { return fmt.Errorf("Unable to install %s: %w", pkg, err) }
+        targets = append(targets, target)
+    }
+
+    files, names := []string {}, []string {}
+    for _, target := range targets {
+        if target.filePath != "" {
+            files = append(files, target.filePath)
+        } else {
+            names = append(names, target.pkg.RpmName)
+        }
+    }
+
+    if err := installPkg(files, names); err != nil { return err }
+
+    for _, target := range targets {
+        pkg := target.pkg
+        pkg.InstalledAt = time.Now()
+        if version, err := pkgManager().InstalledVersion(pkg.RpmName); err == nil { pkg.Version = version }
+
+        db.Put(&pkg)
+        msg := fmt.Sprintf("Successfully installed %s %s", pkg.Name, pkg.Version)
+        h.Printc(msg, h.INFO, true)
+    }
+
+    if err := db.Save(); err != nil {
+        h.Printc(err.Error(), h.ERROR, false)
+        return fmt.Errorf("Failed to record installed packages: %w", err)
+    }
+
+    return nil
+}
+
+// resolveInstallTarget loads the manifest of the given package, and either adds
+// its repository or downloads the RPM for the host architecture and checks its signature.
+// The GPG keys declared by the manifest are imported first.
+func resolveInstallTarget(db *state.DB, pkg string) (installTarget, error) {
+    data, err := resolvePkg(pkg)
+    if err != nil { return installTarget {}, err }
+
+    return prepareInstallTarget(db, data)
+}
+
+// prepareInstallTarget either adds the repository of the given resolved manifest, or downloads
+// the RPM for the host architecture and checks its signature.
+func prepareInstallTarget(db *state.DB, data *manifest.Pkg) (installTarget, error) {
+    target := installTarget {}
+
+    // Whatever the install source, the host architecture must be listed in `pkg_arches`.
+    archKey := manifest.ArchKey(HOST_CPU)
+    if archKey == "" || !slices.Contains(data.PkgArches, archKey) {
+        return target, fmt.Errorf("%s is not available for %s", data.Name, HOST_CPU)
+    }
+
+    App = data.Name
+    target.pkg = state.Package {
+        Name: data.Name, RpmName: data.Name, Version: data.Version, Arch: archKey,
+    }
+
+    switch {
+    case data.Repo != nil && data.Repo.CoprRepo != nil:
+        copr := data.Repo.CoprRepo
+        if err := addCoprRepo(copr.Username, copr.Project); err != nil { return target, err }
+        target.pkg.Source = state.SOURCE_COPR
+        target.pkg.Repo = copr.Username + "/" + copr.Project
+        target.pkg.RepoFile = RepoName
+        if err := recordRepo(db, target.pkg.Repo); err != nil { return target, err }
+    case data.Repo != nil && data.Repo.UrlRepo != nil:
+        fingerprints, err := addRepo(db, data.Repo.UrlRepo.Url, data.Repo.UrlRepo.GpgKeyUrl)
+        if err != nil { return target, err }
+        target.pkg.GpgKeys = fingerprints
+        target.pkg.Source = state.SOURCE_REPO
+        target.pkg.Repo = data.Repo.UrlRepo.Url
+        target.pkg.RepoFile = RepoName
+        if err := recordRepo(db, target.pkg.Repo); err != nil { return target, err }
+    default:
+        arch := data.Arch.Get(archKey)
+        if arch == nil { return target, fmt.Errorf("%s is not available for %s", data.Name, HOST_CPU) }
+
+        createCacheDir()
+        fileName := filepath.Join(data.Name, rpmFileName(arch.Url, data.Name, data.Version))
+        if err := downloadPkg(arch.Url, fileName, arch.Sha256, arch.Sha512); err != nil { return target, err }
+
+        target.filePath = filepath.Join(CacheDir, fileName)
+        if data.GpgKeyUrl != "" {
+            fingerprints, err := importKeys(db, data.GpgKeyUrl)
+            if err != nil { return target, err }
+            target.pkg.GpgKeys = fingerprints
+        }
+        if err := checkPkgSignature(target.filePath, target.pkg.GpgKeys); err != nil { return target, err }
+
+        target.pkg.Source = state.SOURCE_DIRECT
+        target.pkg.Url = arch.Url
+        target.pkg.File = target.filePath
+        target.pkg.Sha256 = getSha256Hash(target.filePath)
+
+        // The RPM inside may be named differently than its manifest.
+        if rpmName, _, err := backend.QueryFile(target.filePath); err == nil { target.pkg.RpmName = rpmName }
+    }
+
+    return target, nil
+}
+
+// recordRepo remembers the repo file that was just added from `repoUrl`, so that `clean`
+// can find it once no installed package uses it.
+func recordRepo(db *state.DB, repoUrl string) error {
+    db.PutRepo(&state.Repo { File: RepoName, Url: repoUrl, AddedAt: time.Now() })
+    // The repo file is in place now, whether or not the rest of the transaction succeeds.
+    if err := db.Save(); err != nil { return fmt.Errorf("Failed to record the added repo: %w", err) }
+    return nil
+}
+
+// rpmFileName returns the file name of the RPM at the given URL, falling back to
+// `<name>-<version>.<arch>.rpm` when the URL doesn't end in one.
+func rpmFileName(rawUrl string, name string, version string) string {
+    if u, err := url.Parse(rawUrl); err == nil {
+        if base := path.Base(u.Path); strings.HasSuffix(base, ".rpm") { return base }
+    }
+
+    return fmt.Sprintf("%s-%s.%s.rpm", name, version, manifest.ArchKey(HOST_CPU))
+}
 
-    if err != nil {
+// installPkg installs the requested RPM files and repository packages in a single transaction.
+func installPkg(files []string, pkgs []string) error {
+    if !isAdmin() {
+        h.Printc("rpm-get must be run as root!", h.ERROR, false)
+        os.Exit(h.ERROR_EXIT_CODE)
+    }
+
+    if err := pkgManager().Install(files, pkgs); err != nil {
         h.Printc(err.Error(), h.ERROR, false)
-    } else {
-        println(out)
+        return fmt.Errorf("Failed to install packages: %w", err)
     }
+
+    return nil
 }
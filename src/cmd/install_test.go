@@ -0,0 +1,197 @@
+package cmd
+
+import (
+    "fmt"
+    "net/http"
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+    "testing"
+
+    "github.com/FlawlessCasual17/rpm-get/manifest"
+    "github.com/FlawlessCasual17/rpm-get/state"
+    "github.com/samber/lo"
+)
+
+// fakeRpmFiles puts an `rpm` command in the `PATH` for the duration of the test, which finds
+// unsigned RPM files named `<name>-<version>...` to contain the package `<name>-rpm`.
+func fakeRpmFiles(t *testing.T) {
+    t.Helper()
+
+    script := `#!/bin/sh
+case "$1" in
+--checksig) printf '%s:\n    Header SHA256 digest: OK\n    Payload SHA256 digest: OK\n' "$3" ;;
+-qp) file=$(basename "$4"); printf '%s-rpm 0:1.0.0-1' "${file%%-[0-9]*}" ;;
+*) exit 1 ;;
+esac
+`
+    dir := t.TempDir()
+    if err := os.WriteFile(filepath.Join(dir, "rpm"), []byte(script), 0755); err != nil { t.Fatal(err) }
+    t.Setenv("PATH", dir + string(os.PathListSeparator) + os.Getenv("PATH"))
+}
+
+// hostArches returns the architecture key of the host and another one, skipping the test
+// on hosts that no manifest can target.
+func hostArches(t *testing.T) (string, string) {
+    t.Helper()
+
+    host := manifest.ArchKey(HOST_CPU)
+    if host == "" { t.Skipf("%s is not a supported architecture", HOST_CPU) }
+    return host, lo.Ternary(host == manifest.ARCH_ARM64, manifest.ARCH_X86_64, manifest.ARCH_ARM64)
+}
+
+// directManifest returns the manifest of a direct download package served by the given server,
+// available for the given architectures, with an RPM for each of `rpmArches`.
+func directManifest(server *testServer, name string, pkgArches []string, rpmArches []string) string {
+    content := fmt.Sprintf("name: %s\nversion: \"1.0.0\"\ndescription: The %[1]s package\npkg_arches: [%s]\narch:\n",
+        name, strings.Join(pkgArches, ", "))
+    for _, arch := range rpmArches {
+        content += fmt.Sprintf("  %s: { url: \"%s/%s-1.0.0.%[1]s.rpm\" }\n", arch, server.URL, name)
+    }
+    return content
+}
+
+func TestPrepareInstallTargetArch(t *testing.T) {
+    host, other := hostArches(t)
+
+    tests := []struct {
+        name string
+        pkgArches []string
+        rpmArches []string
+        // Path of the downloaded RPM, empty when the package must be refused with `wantErr`
+        want string
+        wantErr string
+    }{
+        { "host RPM among others", []string { other, host }, []string { other, host }, "/app-1.0.0." + host + ".rpm", "" },
+        { "host missing from pkg_arches", []string { other }, []string { other }, "", "is not available for " + HOST_CPU },
+        // Manifests whose RPMs don't match pkg_arches are refused when loaded.
+        { "RPM missing from pkg_arches", []string { other }, []string { other, host }, "", "is not listed in pkg_arches" },
+        { "no RPM for the host", []string { other, host }, []string { other }, "", "is required" },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            useTestDirs(t)
+            fakeRpmFiles(t)
+            newFakeBackend(t)
+            server := newPkgServer(t, []byte("rpm"))
+            writeIndex(t, map[string]string { "app": directManifest(server, "app", test.pkgArches, test.rpmArches) })
+
+            target, err := resolveInstallTarget(openTestState(t), "app")
+            if test.want == "" {
+                if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+                    t.Errorf("resolveInstallTarget() = %v, want an error containing %q", err, test.wantErr)
+                }
+                if requested := server.requested(); len(requested) > 0 { t.Errorf("resolveInstallTarget() downloaded %v", requested) }
+                return
+            }
+
+            if err != nil { t.Fatalf("resolveInstallTarget() failed: %v", err) }
+            if requested := server.requested(); !slices.Equal(requested, []string { test.want }) {
+                t.Errorf("resolveInstallTarget() downloaded %v, want %s", requested, test.want)
+            }
+            if target.pkg.Arch != host || target.filePath != filepath.Join(CacheDir, "app", filepath.Base(test.want)) {
+                t.Errorf("resolveInstallTarget() = %+v, want the %s RPM", target, host)
+            }
+        })
+    }
+
+    t.Run("repo package missing from pkg_arches", func(t *testing.T) {
+        useTestDirs(t)
+        fake := newFakeBackend(t)
+        server := newTestServer(t, http.NotFoundHandler())
+        writeIndex(t, map[string]string {
+            "tool": fmt.Sprintf("name: tool\nversion: \"1.0\"\ndescription: Tool\npkg_arches: [%s]\nrepo:\n  url_repo: { url: \"%s/tool.repo\" }\n",
+                other, server.URL),
+        })
+
+        if _, err := resolveInstallTarget(openTestState(t), "tool"); err == nil { t.Error("resolveInstallTarget() succeeded") }
+        if len(server.requested()) > 0 || len(fake.repos) > 0 {
+            t.Errorf("resolveInstallTarget() downloaded %v and added %v", server.requested(), fake.repos)
+        }
+    })
+}
+
+func TestInstallPkgs(t *testing.T) {
+    host, _ := hostArches(t)
+    useTestDirs(t)
+    fakeRpmFiles(t)
+    fake := newFakeBackend(t)
+
+    mux := http.NewServeMux()
+    server := newTestServer(t, mux)
+    mux.HandleFunc("/tool.repo", func(w http.ResponseWriter, _ *http.Request) {
+        fmt.Fprint(w, "[tool]\nname=Tool\nbaseurl=https://example.com/rpm\n")
+    })
+    mux.HandleFunc("/app-1.0.0." + host + ".rpm", func(w http.ResponseWriter, _ *http.Request) { fmt.Fprint(w, "rpm") })
+
+    writeIndex(t, map[string]string {
+        "app": directManifest(server, "app", []string { host }, []string { host }),
+        "tool": fmt.Sprintf("name: tool\nversion: \"1.0\"\ndescription: Tool\npkg_arches: [%s]\nrepo:\n  url_repo: { url: \"%s/tool.repo\" }\n",
+            host, server.URL),
+        "cli": fmt.Sprintf("name: cli\nversion: \"1.0\"\ndescription: Cli\npkg_arches: [%s]\nrepo:\n  coprRepo: { username: user, project: cli }\n", host),
+    })
+    fake.installed = map[string]string { "app-rpm": "0:1.0.0-1", "tool": "0:1.0-2", "cli": "0:1.0-3" }
+
+    if err := installPkgs([]string { "app", "tool", "cli" }); err != nil { t.Fatalf("installPkgs() failed: %v", err) }
+
+    // Everything is installed in one transaction, RPM files first.
+    rpmPath := filepath.Join(CacheDir, "app", "app-1.0.0." + host + ".rpm")
+    if want := [][]string { { rpmPath, "tool", "cli" } }; !slices.EqualFunc(fake.installs, want, slices.Equal) {
+        t.Errorf("installPkgs() installed %v, want %v", fake.installs, want)
+    }
+    if want := []string { "user/cli" }; !slices.Equal(fake.coprRepos, want) {
+        t.Errorf("installPkgs() added the copr repos %v, want %v", fake.coprRepos, want)
+    }
+
+    db, err := state.Read(StateDir)
+    if err != nil { t.Fatal(err) }
+    //nolint:errcheck
+    defer db.Close()
+
+    coprFile := "_copr:copr.fedorainfracloud.org:user:cli.repo"
+    tests := []state.Package {
+        { Name: "app", RpmName: "app-rpm", Version: "0:1.0.0-1", Source: state.SOURCE_DIRECT, File: rpmPath },
+        { Name: "tool", RpmName: "tool", Version: "0:1.0-2", Source: state.SOURCE_REPO, Repo: server.URL + "/tool.repo", RepoFile: "tool.repo" },
+        { Name: "cli", RpmName: "cli", Version: "0:1.0-3", Source: state.SOURCE_COPR, Repo: "user/cli", RepoFile: coprFile },
+    }
+    for _, want := range tests {
+        got := db.Get(want.Name)
+        switch {
+        case got == nil:
+            t.Errorf("installPkgs() didn't record %s", want.Name)
+        case got.RpmName != want.RpmName || got.Version != want.Version || got.Source != want.Source ||
+            got.Repo != want.Repo || got.RepoFile != want.RepoFile || got.File != want.File || got.Arch != host:
+            t.Errorf("installPkgs() recorded %+v, want %+v", got, want)
+        }
+    }
+
+    repoFiles := []string {}
+    for _, repo := range db.ListRepos() { repoFiles = append(repoFiles, repo.File) }
+    slices.Sort(repoFiles)
+    if want := []string { coprFile, "tool.repo" }; !slices.Equal(repoFiles, want) {
+        t.Errorf("installPkgs() recorded the repos %v, want %v", repoFiles, want)
+    }
+}
+
+func TestInstallPkgsUnavailable(t *testing.T) {
+    host, _ := hostArches(t)
+    useTestDirs(t)
+    fakeRpmFiles(t)
+    fake := newFakeBackend(t)
+    server := newPkgServer(t, []byte("rpm"))
+    writeIndex(t, map[string]string { "app": directManifest(server, "app", []string { host }, []string { host }) })
+
+    // Nothing is installed unless every package can be.
+    if err := installPkgs([]string { "app", "missing" }); err == nil || !strings.Contains(err.Error(), "missing") {
+        t.Errorf("installPkgs() = %v, want missing to fail", err)
+    }
+    if len(fake.installs) > 0 { t.Errorf("installPkgs() installed %v", fake.installs) }
+
+    db, err := state.Read(StateDir)
+    if err != nil { t.Fatal(err) }
+    //nolint:errcheck
+    defer db.Close()
+    if pkgs := db.List(); len(pkgs) > 0 { t.Errorf("installPkgs() recorded %v", pkgs) }
+}
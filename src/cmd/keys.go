@@ -0,0 +1,228 @@
+package cmd
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "os"
+    "slices"
+    "strings"
+    "time"
+
+    "github.com/FlawlessCasual17/rpm-get/backend"
+    h "github.com/FlawlessCasual17/rpm-get/helpers"
+    "github.com/FlawlessCasual17/rpm-get/state"
+    "github.com/spf13/cobra"
+)
+
+// keysCmd represents the keys command
+var keysCmd = &cobra.Command{
+    Use:   "keys",
+    Short: "Manage the GPG keys imported by rpm-get",
+    Long: `Manage the GPG keys that rpm-get imported into the rpm keyring.
+Keys that were already in the keyring before rpm-get imported them are left alone.`,
+    Run: func(cmd *cobra.Command, _ []string) {
+        _ = cmd.Help(); os.Exit(h.USAGE_EXIT_CODE)
+    },
+}
+
+// keysListCmd represents the keys list command
+var keysListCmd = &cobra.Command{
+    Use:   "list",
+    Short: "List the GPG keys imported by rpm-get",
+    Long: "List the GPG keys imported by rpm-get, where they came from and the packages that use them.",
+    Run: func(_ *cobra.Command, _ []string) { listKeys() },
+}
+
+// keysImportCmd represents the keys import command
+var keysImportCmd = &cobra.Command{
+    Use:   "import <url|file>...",
+    Short: "Import GPG keys into the rpm keyring",
+    Long: "Import GPG keys from URLs or files into the rpm keyring, and remember them.",
+    Run: func(cmd *cobra.Command, args []string) {
+        if len(args) == 0 {
+            _ = cmd.Help(); os.Exit(h.USAGE_EXIT_CODE)
+        }
+
+        if err := importKeysCmd(args); err != nil {
+            h.Printc(err.Error(), h.ERROR, false)
+            os.Exit(h.ERROR_EXIT_CODE)
+        }
+    },
+}
+
+// keysRemoveCmd represents the keys remove command
+var keysRemoveCmd = &cobra.Command{
+    Use:   "remove <fingerprint>...",
+    Short: "Remove GPG keys imported by rpm-get",
+    Long: `Remove GPG keys imported by rpm-get from the rpm keyring.
+Keys that are still used by packages installed by rpm-get are not removed.`,
+    Run: func(cmd *cobra.Command, args []string) {
+        if len(args) == 0 {
+            _ = cmd.Help(); os.Exit(h.USAGE_EXIT_CODE)
+        }
+
+        if err := removeKeys(args); err != nil {
+            h.Printc(err.Error(), h.ERROR, false)
+            os.Exit(h.ERROR_EXIT_CODE)
+        }
+    },
+}
+
+func init() {
+    rootCmd.AddCommand(keysCmd)
+    keysCmd.AddCommand(keysListCmd, keysImportCmd, keysRemoveCmd)
+}
+
+// listKeys prints the keys imported by rpm-get.
+func listKeys() {
+    db := readState()
+    //nolint:errcheck
+    defer db.Close()
+
+    for _, key := range db.ListKeys() {
+        users := strings.Join(db.KeyUsers(key.Fingerprint), ", ")
+        if users == "" { users = "-" }
+        fmt.Printf("%s\t%s\t%s\t%s\n", key.Fingerprint, key.Url, key.ImportedAt.Format("2006-01-02 15:04"), users)
+    }
+}
+
+// importKeysCmd imports the keys at the given URLs or paths.
+func importKeysCmd(keyUrls []string) error {
+    if !isAdmin() {
+        h.Printc("rpm-get must be run as root!", h.ERROR, false)
+        os.Exit(h.ERROR_EXIT_CODE)
+    }
+
+    db := openState()
+    //nolint:errcheck
+    defer db.Close()
+
+    for _, keyUrl := range keyUrls {
+        fingerprints, err := importKeys(db, keyUrl)
+        if err != nil { return err }
+
+        msg := fmt.Sprintf("Successfully imported %s", strings.Join(fingerprints, ", "))
+        h.Printc(msg, h.INFO, true)
+    }
+
+    return nil
+}
+
+// removeKeys removes the given keys from the rpm keyring, and forgets about them.
+func removeKeys(fingerprints []string) error {
+    if !isAdmin() {
+        h.Printc("rpm-get must be run as root!", h.ERROR, false)
+        os.Exit(h.ERROR_EXIT_CODE)
+    }
+
+    db := openState()
+    //nolint:errcheck
+    defer db.Close()
+
+    for _, fingerprint := range fingerprints {
+        fingerprint = strings.ToUpper(strings.ReplaceAll(fingerprint, " ", ""))
+
+        if db.GetKey(fingerprint) == nil {
+            return fmt.Errorf("The key %s was not imported by rpm-get", fingerprint)
+        }
+        if users := db.KeyUsers(fingerprint); len(users) > 0 {
+            return fmt.Errorf("The key %s is still used by %s", fingerprint, strings.Join(users, ", "))
+        }
+
+        if backend.KeyInstalled(fingerprint) {
+            if err := backend.RemoveKey(fingerprint); err != nil { return err }
+        }
+        db.DeleteKey(fingerprint)
+
+        if err := db.Save(); err != nil {
+            h.Printc(err.Error(), h.ERROR, false)
+            return fmt.Errorf("Failed to record removed keys: %w", err)
+        }
+        h.Printc(fmt.Sprintf("Successfully removed %s", fingerprint), h.INFO, true)
+    }
+
+    return nil
+}
+
+// importKeys imports the keys at the given URL or path into the rpm keyring, and returns
+// their fingerprints. Keys that weren't already in the keyring are recorded in the state.
+func importKeys(db *state.DB, keyUrl string) ([]string, error) {
+    content, err := readKey(keyUrl)
+    if err != nil { return nil, err }
+
+    fingerprints, fpErr := backend.KeyFingerprints(content)
+    if fpErr != nil { return nil, fmt.Errorf("Invalid GPG key at %s: %w", keyUrl, fpErr) }
+
+    missing := []string {}
+    for _, fingerprint := range fingerprints {
+        if !backend.KeyInstalled(fingerprint) { missing = append(missing, fingerprint) }
+    }
+    if len(missing) == 0 { return fingerprints, nil }
+
+    // rpm imports the exact bytes that were fingerprinted, rather than downloading them again.
+    tmpFile, tmpErr := os.CreateTemp("", "rpm-get-key-*.asc")
+    if tmpErr != nil { return nil, fmt.Errorf("Failed to create a temporary file: %w", tmpErr) }
+    //nolint:errcheck
+    defer os.Remove(tmpFile.Name())
+
+    _, writeErr := tmpFile.Write(content)
+    if err := errors.Join(writeErr, tmpFile.Close()); err != nil {
+        return nil, fmt.Errorf("Failed to write %s: %w", tmpFile.Name(), err)
+    }
+
+    if err := backend.ImportKey(tmpFile.Name()); err != nil {
+        h.Printc(err.Error(), h.ERROR, false)
+        return nil, fmt.Errorf("Failed to import the GPG key at %s: %w", keyUrl, err)
+    }
+
+    for _, fingerprint := range missing {
+        db.PutKey(&state.Key { Fingerprint: fingerprint, Url: keyUrl, ImportedAt: time.Now() })
+    }
+    // The keys are in the keyring now, whether or not the rest of the transaction succeeds.
+    if err := db.Save(); err != nil { return nil, fmt.Errorf("Failed to record imported keys: %w", err) }
+
+    return fingerprints, nil
+}
+
+// readKey downloads the key at the given URL, or reads it from the given path.
+func readKey(keyUrl string) ([]byte, error) {
+    if !strings.HasPrefix(keyUrl, "https://") && !strings.HasPrefix(keyUrl, "http://") {
+        content, err := os.ReadFile(strings.TrimPrefix(keyUrl, "file://"))
+        if err != nil { return nil, fmt.Errorf("Failed to read the GPG key: %w", err) }
+        return content, nil
+    }
+
+    content := bytes.Buffer {}
+    if _, err := download(keyUrl, &content, "", nil); err != nil {
+        return nil, fmt.Errorf("Failed to download the GPG key: %w", err)
+    }
+
+    return content.Bytes(), nil
+}
+
+// checkPkgSignature checks the header signature of the given RPM before it's installed.
+// When the manifest declares keys, the RPM must be signed with one of them. Otherwise unsigned
+// RPMs, and RPMs signed with a key missing from the keyring, are installed with a warning.
+// The RPM is deleted when its signature is refused.
+func checkPkgSignature(filePath string, fingerprints []string) error {
+    keyId, err := backend.CheckSignature(filePath)
+    declared := len(fingerprints) > 0
+    matches := func(fingerprint string) bool { return backend.KeyIdMatches(keyId, fingerprint) }
+
+    switch {
+    case err == nil && declared && !slices.ContainsFunc(fingerprints, matches):
+        err = fmt.Errorf("the RPM is signed with the key %s instead of %s", keyId, strings.Join(fingerprints, ", "))
+    case err == nil:
+        return nil
+    case !declared && (errors.Is(err, backend.ErrUnsigned) || errors.Is(err, backend.ErrUnknownKey)):
+        msg := fmt.Sprintf("The signature of %s could not be verified: %s", App, err)
+        h.Printc(msg, h.WARNING, false)
+        return nil
+    }
+
+    _ = os.Remove(filePath)
+    msg := fmt.Sprintf("Signature check failed for %s!", App)
+    h.Printc(msg, h.ERROR, false)
+    return fmt.Errorf("Refusing to install %s: %w", filePath, err)
+}
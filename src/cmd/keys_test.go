@@ -0,0 +1,87 @@
+package cmd
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/FlawlessCasual17/rpm-get/backend"
+)
+
+// fakeRpm puts an `rpm` command in the `PATH` for the duration of the test,
+// which prints the given output and exits with the given code.
+func fakeRpm(t *testing.T, output string, exitCode int) {
+    t.Helper()
+
+    dir := t.TempDir()
+    script := fmt.Sprintf("#!/bin/sh\ncat <<'EOF'\n%s\nEOF\nexit %d\n", output, exitCode)
+    if err := os.WriteFile(filepath.Join(dir, "rpm"), []byte(script), 0755); err != nil { t.Fatal(err) }
+
+    t.Setenv("PATH", dir + string(os.PathListSeparator) + os.Getenv("PATH"))
+}
+
+func TestCheckPkgSignature(t *testing.T) {
+    const fingerprint = "690493179ABB1AD0E06B284A4787ED7347C2FD12"
+    const other = "951E987EC34A752D9E9D34EDBEE3A415DD098430"
+
+    signed := "    Header V4 RSA/SHA256 Signature, key ID 47c2fd12: OK\n    Payload SHA256 digest: OK"
+    signedV6 := "    Header OpenPGP V4 EdDSA/SHA512 signature, key fingerprint: " + fingerprint + ": OK"
+    noKey := "    Header V4 RSA/SHA256 Signature, key ID 47c2fd12: NOKEY\n    Payload SHA256 digest: OK"
+    unsigned := "    Header SHA256 digest: OK\n    Payload SHA256 digest: OK"
+    bad := "    Header V4 RSA/SHA256 Signature, key ID 47c2fd12: BAD"
+
+    tests := []struct {
+        name string
+        output string
+        exitCode int
+        fingerprints []string
+        // Whether the RPM is refused, and deleted
+        refused bool
+    }{
+        { "signed with the declared key", signed, 0, []string { fingerprint }, false },
+        { "signed with a declared rpm 6 key", signedV6, 0, []string { other, fingerprint }, false },
+        { "signed with another key", signed, 0, []string { other }, true },
+        { "missing declared key", noKey, 1, []string { fingerprint }, true },
+        { "unsigned with a declared key", unsigned, 0, []string { fingerprint }, true },
+        { "bad signature with a declared key", bad, 1, []string { fingerprint }, true },
+        // Without declared keys, what can't be verified is only a warning.
+        { "signed without declared keys", signed, 0, nil, false },
+        { "missing key without declared keys", noKey, 1, nil, false },
+        { "unsigned without declared keys", unsigned, 0, nil, false },
+        { "bad signature without declared keys", bad, 1, nil, true },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            fakeRpm(t, "app.rpm:\n" + test.output, test.exitCode)
+            filePath := filepath.Join(t.TempDir(), "app.rpm")
+            if err := os.WriteFile(filePath, []byte("rpm"), 0644); err != nil { t.Fatal(err) }
+
+            err := checkPkgSignature(filePath, test.fingerprints)
+            _, statErr := os.Stat(filePath)
+
+            switch {
+            case test.refused && err == nil:
+                t.Error("checkPkgSignature() accepted the RPM")
+            case !test.refused && err != nil:
+                t.Errorf("checkPkgSignature() failed: %v", err)
+            case test.refused && !errors.Is(statErr, os.ErrNotExist):
+                t.Error("checkPkgSignature() kept the refused RPM")
+            case !test.refused && statErr != nil:
+                t.Errorf("checkPkgSignature() removed the RPM: %v", statErr)
+            }
+        })
+    }
+
+    t.Run("bad signature error", func(t *testing.T) {
+        fakeRpm(t, "app.rpm:\n" + bad, 1)
+        filePath := filepath.Join(t.TempDir(), "app.rpm")
+        if err := os.WriteFile(filePath, []byte("rpm"), 0644); err != nil { t.Fatal(err) }
+
+        if err := checkPkgSignature(filePath, nil); !errors.Is(err, backend.ErrBadSignature) {
+            t.Errorf("checkPkgSignature() = %v, want %v", err, backend.ErrBadSignature)
+        }
+    })
+}
@@ -2,35 +2,72 @@
 
 import (
     "fmt"
+    "os"
 
+    h "github.com/FlawlessCasual17/rpm-get/helpers"
+    "github.com/samber/lo"
     "github.com/spf13/cobra"
 )
 
+var (
+    wantsInstalled bool
+    wantsNotInstalled bool
+    wantsRaw bool
+)
+
 // listCmd represents the list command
 var listCmd = &cobra.Command{
-    Use:   "list",
-    Short: "A brief description of your command",
-    Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-    Run: func(cmd *cobra.Command, args []string) {
-        fmt.Println("list called")
+    Use:   "list [--raw|--installed|--not-installed]",
+    Short: "List the packages available via rpm-get",
+    Long: `List the packages available via rpm-get, and tell which ones are installed.
+When --raw is provided, list all packages and do not tell which ones are installed.
+When --installed is provided, only list the packages installed by rpm-get.
+When --not-installed is provided, only list the packages not installed.`,
+    Run: func(_ *cobra.Command, _ []string) {
+        if err := listPkgs(); err != nil {
+            h.Printc(err.Error(), h.ERROR, false)
+            os.Exit(h.ERROR_EXIT_CODE)
+        }
     },
 }
 
 func init() {
     rootCmd.AddCommand(listCmd)
 
-    // Here you will define your flags and configuration settings.
+    listCmd.Flags().BoolVar(&wantsRaw, "raw", false, "Do not tell which packages are installed")
+    listCmd.Flags().BoolVar(&wantsInstalled, "installed", false, "Only list the packages installed by rpm-get")
+    listCmd.Flags().BoolVar(&wantsNotInstalled, "not-installed", false, "Only list the packages not installed")
+    listCmd.MarkFlagsMutuallyExclusive("raw", "installed", "not-installed")
+}
+
+// listPkgs prints the packages matching the list flags.
+func listPkgs() error {
+    db := readState()
+    //nolint:errcheck
+    defer db.Close()
+
+    if wantsInstalled {
+        for _, pkg := range db.List() {
+            fmt.Printf("%s\t%s\t%s\t%s\n", pkg.Name, pkg.Version, pkg.Source, pkg.InstalledAt.Format("2006-01-02 15:04"))
+        }
+        return nil
+    }
+
+    names, err := manifestNames()
+    if err != nil { return err }
+
+    for _, name := range names {
+        installed := db.Get(name) != nil
 
-    // Cobra supports Persistent Flags which will work for this command
-    // and all subcommands, e.g.:
-    // listCmd.PersistentFlags().String("foo", "", "A help for foo")
+        switch {
+        case wantsRaw:
+            fmt.Println(name)
+        case wantsNotInstalled && !installed:
+            fmt.Println(name)
+        case !wantsNotInstalled:
+            fmt.Printf("%s%s\n", name, lo.Ternary(installed, " [installed]", ""))
+        }
+    }
 
-    // Cobra supports local flags which will only run when this command
-    // is called directly, e.g.:
-    // listCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+    return nil
 }
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+    "fmt"
+    "io/fs"
+    "os"
+    "path/filepath"
+
+    h "github.com/FlawlessCasual17/rpm-get/helpers"
+    "github.com/FlawlessCasual17/rpm-get/manifest"
+    "github.com/spf13/cobra"
+)
+
+// manifestCmd represents the manifest command
+var manifestCmd = &cobra.Command{
+    Use:   "manifest",
+    Short: "Tools for package manifest authors",
+    Long: "Tools for package manifest authors",
+    Run: func(cmd *cobra.Command, _ []string) {
+        _ = cmd.Help(); os.Exit(h.USAGE_EXIT_CODE)
+    },
+}
+
+// manifestLintCmd represents the manifest lint command
+var manifestLintCmd = &cobra.Command{
+    Use:   "lint <file|dir>...",
+    Short: "Check package manifests for problems",
+    Long: `Check one or more package manifests for problems.
+When a directory is given, every manifest (.yaml, .yml or .json) below it is checked.
+Every problem is printed as "file:line:column: field: message".`,
+    Run: func(cmd *cobra.Command, args []string) {
+        if len(args) == 0 {
+            _ = cmd.Help(); os.Exit(h.USAGE_EXIT_CODE)
+        }
+
+        os.Exit(lintManifests(args))
+    },
+}
+
+func init() {
+    rootCmd.AddCommand(manifestCmd)
+    manifestCmd.AddCommand(manifestLintCmd)
+}
+
+// lintManifests lints the manifests at the given paths and returns the exit code.
+func lintManifests(paths []string) int {
+    files := []string {}
+
+    for _, path := range paths {
+        found, err := findManifests(path)
+        if err != nil {
+            h.Printc(err.Error(), h.ERROR, false)
+            return h.USAGE_EXIT_CODE
+        }
+        files = append(files, found...)
+    }
+
+    if len(files) == 0 {
+        h.Printc("No package manifests were found!", h.ERROR, false)
+        return h.USAGE_EXIT_CODE
+    }
+
+    linter := manifest.NewLinter()
+    problems := []manifest.Problem {}
+    for _, file := range files { problems = append(problems, linter.Lint(file)...) }
+
+    for _, problem := range problems { fmt.Println(problem.String()) }
+
+    if len(problems) > 0 {
+        msg := fmt.Sprintf("Found %d problem(s) in %d manifest(s)", len(problems), len(files))
+        h.Printc(msg, h.ERROR, false)
+        return h.ERROR_EXIT_CODE
+    }
+
+    msg := fmt.Sprintf("Checked %d manifest(s), no problems found", len(files))
+    h.Printc(msg, h.INFO, false)
+    return h.SUCCESS_EXIT_CODE
+}
+
+// findManifests returns the given file, or every manifest below the given directory.
+func findManifests(path string) ([]string, error) {
+    info, statErr := os.Stat(path)
+    if statErr != nil {
+        return nil, fmt.Errorf("Unable to read %s: %w", path, statErr)
+    }
+
+    if !info.IsDir() { return []string { path }, nil }
+
+    files := []string {}
+    err := filepath.WalkDir(path, func(filePath string, entry fs.DirEntry, err error) error {
+        if err != nil { return err }
+        if entry.IsDir() { return nil }
+
+        if _, formatErr := manifest.FormatOf(filePath); formatErr == nil {
+            files = append(files, filePath)
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("Unable to read %s: %w", path, err)
+    }
+
+    return files, nil
+}
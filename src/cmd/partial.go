@@ -0,0 +1,107 @@
+package cmd
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "os"
+    "strconv"
+    "strings"
+
+    "github.com/goccy/go-json"
+)
+
+const (
+    // PART_EXT is appended to the name of an RPM while it's being downloaded.
+    PART_EXT string = ".part"
+    // PART_META_EXT is appended to the name of a partial download to name the file describing it.
+    PART_META_EXT string = ".json"
+)
+
+// errRangeMismatch is returned when a server answers a range request with another range.
+var errRangeMismatch = errors.New("the server returned an unexpected range")
+
+// partMeta describes the response a partial download was started from,
+// so that it's only resumed when the file on the server didn't change.
+type partMeta struct {
+    Url string            `json:"url"`
+    ETag string           `json:"etag,omitempty"`
+    LastModified string   `json:"last_modified,omitempty"`
+    // Whether the server advertised `Accept-Ranges: bytes`
+    AcceptRanges bool     `json:"accept_ranges"`
+}
+
+// newPartMeta describes the given response of a download from `url`.
+func newPartMeta(url string, resp *http.Response) partMeta {
+    return partMeta {
+        Url: url,
+        ETag: resp.Header.Get("ETag"),
+        LastModified: resp.Header.Get("Last-Modified"),
+        AcceptRanges: strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes"),
+    }
+}
+
+// readPartMeta reads the description of the given partial download.
+// Nil is returned when it's missing or unreadable.
+func readPartMeta(partPath string) *partMeta {
+    content, err := os.ReadFile(partPath + PART_META_EXT)
+    if err != nil { return nil }
+
+    meta := &partMeta {}
+    if err := json.Unmarshal(content, meta); err != nil { return nil }
+    return meta
+}
+
+// writePartMeta writes the description of the given partial download.
+func writePartMeta(partPath string, meta partMeta) error {
+    content, err := json.Marshal(meta)
+    if err != nil { return fmt.Errorf("Failed to encode download state: %w", err) }
+
+    if err := os.WriteFile(partPath + PART_META_EXT, content, 0644); err != nil {
+        return fmt.Errorf("Failed to write download state: %w", err)
+    }
+
+    return nil
+}
+
+// removePart deletes a partial download and its description.
+func removePart(partPath string) {
+    _ = os.Remove(partPath)
+    _ = os.Remove(partPath + PART_META_EXT)
+}
+
+// resumeHeaders returns the offset to resume the given partial download of `url` from,
+// and the headers requesting the rest of it. Downloads are only resumed when the server
+// accepts range requests, and `If-Range` lets it send the whole file if it changed since.
+func resumeHeaders(partPath string, url string) (int64, map[string]string) {
+    info, statErr := os.Stat(partPath)
+    meta := readPartMeta(partPath)
+    if statErr != nil || info.Size() == 0 || meta == nil || meta.Url != url || !meta.AcceptRanges {
+        return 0, nil
+    }
+
+    // Weak ETags can't be used with `If-Range`.
+    ifRange := meta.ETag
+    if ifRange == "" || strings.HasPrefix(ifRange, "W/") { ifRange = meta.LastModified }
+    if ifRange == "" { return 0, nil }
+
+    headers := map[string]string {
+        "Range": fmt.Sprintf("bytes=%d-", info.Size()),
+        "If-Range": ifRange,
+    }
+
+    return info.Size(), headers
+}
+
+// checkContentRange checks that a `206 Partial Content` response starts at `offset`.
+func checkContentRange(resp *http.Response, offset int64) error {
+    // e.g. `bytes 1000-1999/2000`
+    unit, byteRange, _ := strings.Cut(resp.Header.Get("Content-Range"), " ")
+    start, _, _ := strings.Cut(byteRange, "-")
+
+    if first, err := strconv.ParseInt(start, 10, 64); unit != "bytes" || err != nil || first != offset {
+        return fmt.Errorf("%w: %q, expected bytes %d-", errRangeMismatch, resp.Header.Get("Content-Range"), offset)
+    }
+
+    return nil
+}
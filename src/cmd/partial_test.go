@@ -0,0 +1,220 @@
+package cmd
+
+import (
+    "bytes"
+    "errors"
+    "net/http"
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+// rangeServer serves a file with its validators, honouring `Range` and `If-Range`.
+type rangeServer struct {
+    *testServer
+    mutex sync.Mutex
+    content []byte
+    etag string
+    modTime time.Time
+    // Answered as the `Content-Range` of a 206 to range requests, instead of the requested range
+    contentRange string
+    // `Range` and `If-Range` headers of every request
+    ranges []string
+    ifRanges []string
+}
+
+// newRangeServer starts a `rangeServer` for the given file.
+func newRangeServer(t *testing.T, content []byte, etag string) *rangeServer {
+    t.Helper()
+
+    server := &rangeServer { content: content, etag: etag, modTime: time.Now().Add(-time.Hour).Truncate(time.Second) }
+    server.testServer = newTestServer(t, http.HandlerFunc(server.serve))
+    return server
+}
+
+// serve answers the requests for the file.
+func (s *rangeServer) serve(w http.ResponseWriter, r *http.Request) {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    s.ranges = append(s.ranges, r.Header.Get("Range"))
+    s.ifRanges = append(s.ifRanges, r.Header.Get("If-Range"))
+
+    if s.contentRange != "" && r.Header.Get("Range") != "" {
+        w.Header().Set("Content-Range", s.contentRange)
+        w.WriteHeader(http.StatusPartialContent)
+        _, _ = w.Write(s.content)
+        return
+    }
+
+    w.Header().Set("ETag", s.etag)
+    http.ServeContent(w, r, "app.rpm", s.modTime, bytes.NewReader(s.content))
+}
+
+// lastModified returns the `Last-Modified` header of the file.
+func (s *rangeServer) lastModified() string { return s.modTime.UTC().Format(http.TimeFormat) }
+
+// writePart writes a partial download of `url` with the given content and validators.
+func writePart(t *testing.T, partPath string, content []byte, meta partMeta) {
+    t.Helper()
+
+    if err := os.WriteFile(partPath, content, 0644); err != nil { t.Fatal(err) }
+    if err := writePartMeta(partPath, meta); err != nil { t.Fatal(err) }
+}
+
+func TestFetchPart(t *testing.T) {
+    content := []byte(strings.Repeat("0123456789", 100))
+    changed := []byte(strings.Repeat("abcdefghij", 120))
+    // Only a resumed download keeps what was downloaded before.
+    part := []byte(strings.Repeat("x", 400))
+    resumed := append(slices.Clone(part), content[400:]...)
+
+    tests := []struct {
+        name string
+        // Content and ETag served, `content` and `"v1"` when empty
+        served []byte
+        etag string
+        // Partial download to resume, none when nil, and its validators
+        part []byte
+        meta func(server *rangeServer) partMeta
+        // Expected `Range` and `If-Range` of the first request
+        wantRange string
+        wantIfRange string
+        want []byte
+        // Expected number of requests, 1 when 0
+        requests int
+    }{
+        { name: "new download", want: content },
+        {
+            name: "resumed download",
+            part: part,
+            meta: func(server *rangeServer) partMeta { return partMeta { ETag: `"v1"`, AcceptRanges: true } },
+            wantRange: "bytes=400-", wantIfRange: `"v1"`, want: resumed,
+        },
+        {
+            name: "file changed since the partial download",
+            served: changed, etag: `"v2"`,
+            part: content[:400],
+            meta: func(server *rangeServer) partMeta { return partMeta { ETag: `"v1"`, AcceptRanges: true } },
+            wantRange: "bytes=400-", wantIfRange: `"v1"`, want: changed,
+        },
+        {
+            name: "partial download longer than the file",
+            served: content[:300],
+            part: content[:400],
+            meta: func(server *rangeServer) partMeta { return partMeta { ETag: `"v1"`, AcceptRanges: true } },
+            wantRange: "bytes=400-", wantIfRange: `"v1"`, want: content[:300], requests: 2,
+        },
+        {
+            name: "weak ETag",
+            etag: `W/"v1"`,
+            part: part,
+            meta: func(server *rangeServer) partMeta {
+                return partMeta { ETag: `W/"v1"`, LastModified: server.lastModified(), AcceptRanges: true }
+            },
+            wantRange: "bytes=400-", wantIfRange: "<Last-Modified>", want: resumed,
+        },
+        {
+            name: "weak ETag without Last-Modified",
+            etag: `W/"v1"`,
+            part: content[:400],
+            meta: func(server *rangeServer) partMeta { return partMeta { ETag: `W/"v1"`, AcceptRanges: true } },
+            want: content,
+        },
+        {
+            name: "server without range requests",
+            part: content[:400],
+            meta: func(server *rangeServer) partMeta { return partMeta { ETag: `"v1"` } },
+            want: content,
+        },
+        {
+            name: "partial download of another URL",
+            part: content[:400],
+            meta: func(server *rangeServer) partMeta { return partMeta { Url: server.URL + "/other.rpm", ETag: `"v1"`, AcceptRanges: true } },
+            want: content,
+        },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            served, etag := test.served, test.etag
+            if served == nil { served = content }
+            if etag == "" { etag = `"v1"` }
+            server := newRangeServer(t, served, etag)
+            url := server.URL + "/app.rpm"
+            partPath := filepath.Join(t.TempDir(), "app.rpm" + PART_EXT)
+
+            if test.part != nil {
+                meta := test.meta(server)
+                if meta.Url == "" { meta.Url = url }
+                writePart(t, partPath, test.part, meta)
+            }
+
+            if err := fetchPart(url, partPath); err != nil { t.Fatalf("fetchPart() failed: %v", err) }
+            if got, _ := os.ReadFile(partPath); !bytes.Equal(got, test.want) {
+                t.Errorf("fetchPart() wrote %d bytes, want %d", len(got), len(test.want))
+            }
+
+            if requests := max(test.requests, 1); len(server.ranges) != requests {
+                t.Errorf("fetchPart() sent %d requests, want %d", len(server.ranges), requests)
+            }
+
+            wantIfRange := strings.ReplaceAll(test.wantIfRange, "<Last-Modified>", server.lastModified())
+            if server.ranges[0] != test.wantRange || server.ifRanges[0] != wantIfRange {
+                t.Errorf("fetchPart() requested Range %q with If-Range %q, want %q with %q",
+                    server.ranges[0], server.ifRanges[0], test.wantRange, wantIfRange)
+            }
+
+            // The validators are the ones of the response the download was started from.
+            if meta := readPartMeta(partPath); meta == nil || meta.Url != url || meta.ETag != etag || !meta.AcceptRanges {
+                t.Errorf("fetchPart() described the download as %+v, want ETag %s from %s", meta, etag, url)
+            }
+        })
+    }
+}
+
+func TestFetchPartRangeMismatch(t *testing.T) {
+    content := []byte(strings.Repeat("0123456789", 100))
+    server := newRangeServer(t, content, `"v1"`)
+    server.contentRange = "bytes 0-999/1000"
+    url := server.URL + "/app.rpm"
+    partPath := filepath.Join(t.TempDir(), "app.rpm" + PART_EXT)
+    writePart(t, partPath, content[:400], partMeta { Url: url, ETag: `"v1"`, AcceptRanges: true })
+
+    if err := fetchPart(url, partPath); !errors.Is(err, errRangeMismatch) {
+        t.Errorf("fetchPart() = %v, want %v", err, errRangeMismatch)
+    }
+    // Appending the response would corrupt the download, which starts over the next time.
+    for _, leftover := range []string { partPath, partPath + PART_META_EXT } {
+        if fileExists(leftover) { t.Errorf("fetchPart() kept %s", leftover) }
+    }
+}
+
+func TestCheckContentRange(t *testing.T) {
+    tests := []struct {
+        contentRange string
+        offset int64
+        valid bool
+    }{
+        { "bytes 400-999/1000", 400, true },
+        { "bytes 400-999/*", 400, true },
+        { "bytes 0-999/1000", 400, false },
+        { "bytes 500-999/1000", 400, false },
+        { "items 400-999/1000", 400, false },
+        { "", 400, false },
+    }
+
+    for _, test := range tests {
+        resp := &http.Response { Header: http.Header {} }
+        resp.Header.Set("Content-Range", test.contentRange)
+
+        err := checkContentRange(resp, test.offset)
+        if valid := err == nil; valid != test.valid {
+            t.Errorf("checkContentRange(%q, %d) = %v, want valid: %v", test.contentRange, test.offset, err, test.valid)
+        }
+    }
+}
@@ -6,56 +6,110 @@
 import (
 	"fmt"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
 
 	h "github.com/FlawlessCasual17/rpm-get/helpers"
+	"github.com/FlawlessCasual17/rpm-get/state"
 	"github.com/spf13/cobra"
 )
 
 // reinstallCmd represents the reinstall command
 var reinstallCmd = &cobra.Command{
-    Use:   "reinstall",
-    Short: "A brief description of your command",
-    Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+    Use:   "reinstall <pkg>...",
+    Short: "Reinstall packages installed by rpm-get",
+    Long: `Reinstall packages installed by rpm-get.
+Direct download packages are reinstalled from the cache, and downloaded again
+if the cached RPM is missing or was modified.`,
     Run: func(cmd *cobra.Command, args []string) {
-        fmt.Println("reinstall called")
+        if len(args) == 0 {
+            _ = cmd.Help(); os.Exit(h.USAGE_EXIT_CODE)
+        }
+
+        if err := reinstallPkgs(args); err != nil {
+            h.Printc(err.Error(), h.ERROR, false)
+            os.Exit(h.ERROR_EXIT_CODE)
+        }
     },
 }
 
-func init() {
-    rootCmd.AddCommand(reinstallCmd)
+func init() { rootCmd.AddCommand(reinstallCmd) }
+
+// reinstallPkgs reinstalls the given packages installed by rpm-get.
+func reinstallPkgs(names []string) error {
+    if !isAdmin() {
+        h.Printc("rpm-get must be run as root!", h.ERROR, false)
+        os.Exit(h.ERROR_EXIT_CODE)
+    }
+
+    db := openState()
+    //nolint:errcheck
+    defer db.Close()
+
+    pkgs := []*state.Package {}
+    targets := []string {}
+    for _, name := range names {
+        pkg := db.Get(name)
+        if pkg == nil {
+            return fmt.Errorf("%s was not installed by rpm-get", name)
+        }
+
+        if pkg.Source == state.SOURCE_DIRECT {
+            if err := ensureCachedPkg(pkg); err != nil { return err }
+            targets = append(targets, pkg.File)
+        } else {
+            targets = append(targets, pkg.RpmName)
+        }
+        pkgs = append(pkgs, pkg)
+    }
+
+    if err := reinstallPkg(targets...); err != nil { return err }
+
+    for _, pkg := range pkgs {
+        pkg.InstalledAt = time.Now()
+        if version, err := pkgManager().InstalledVersion(pkg.RpmName); err == nil { pkg.Version = version }
+        h.Printc(fmt.Sprintf("Successfully reinstalled %s %s", pkg.Name, pkg.Version), h.INFO, true)
+    }
+
+    if err := db.Save(); err != nil {
+        h.Printc(err.Error(), h.ERROR, false)
+        return fmt.Errorf("Failed to record reinstalled packages: %w", err)
+    }
+
+    return nil
+}
+
+// ensureCachedPkg downloads the RPM of a direct download package again,
+// if it's missing from the cache or doesn't match the recorded hash.
+func ensureCachedPkg(pkg *state.Package) error {
+    if pkg.File == "" {
+        pkg.File = filepath.Join(CacheDir, pkg.Name, rpmFileName(pkg.Url, pkg.Name, pkg.Version))
+    }
 
-    // Here you will define your flags and configuration settings.
+    if _, err := os.Stat(pkg.File); err == nil && getSha256Hash(pkg.File) == pkg.Sha256 { return nil }
 
-    // Cobra supports Persistent Flags which will work for this command
-    // and all subcommands, e.g.:
-    // reinstallCmd.PersistentFlags().String("foo", "", "A help for foo")
+    createCacheDir()
+    App = pkg.Name
+    fileName := strings.TrimPrefix(pkg.File, CacheDir + string(filepath.Separator))
+    // The RPM must be the same one that was installed.
+    if err := downloadPkg(pkg.Url, fileName, pkg.Sha256, ""); err != nil { return err }
+    pkg.Sha256 = getSha256Hash(pkg.File)
 
-    // Cobra supports local flags which will only run when this command
-    // is called directly, e.g.:
-    // reinstallCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+    return nil
 }
 
-// reinstallPkg reinstalls the requested RPM package that is already installed.
-func reinstallPkg(pkg string) {
+// reinstallPkg reinstalls the requested RPM packages or files that are already installed.
+func reinstallPkg(pkgs ...string) error {
     if !isAdmin() {
         h.Printc("rpm-get must be run as root!", h.ERROR, false)
         os.Exit(h.ERROR_EXIT_CODE)
     }
 
-    cmd := which("sudo") + " " + which("dnf")
-    args := []string { "reinstall", "-y", pkg }
-    command := exec.Command(cmd, args...)
-    out, err := command.Output()
-
-    if err != nil {
+    if err := pkgManager().Reinstall(pkgs...); err != nil {
         h.Printc(err.Error(), h.ERROR, false)
-    } else {
-        println(out)
+        return fmt.Errorf("Failed to reinstall packages: %w", err)
     }
+
+    return nil
 }
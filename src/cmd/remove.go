@@ -6,56 +6,98 @@
 import (
 	"fmt"
 	"os"
-	"os/exec"
 
 	h "github.com/FlawlessCasual17/rpm-get/helpers"
+	"github.com/FlawlessCasual17/rpm-get/state"
 	"github.com/spf13/cobra"
 )
 
+// wantsRemoveRepo is set by `remove --remove-repo`.
+var wantsRemoveRepo bool
+
 // removeCmd represents the remove command
 var removeCmd = &cobra.Command{
-    Use:   "remove",
-    Short: "A brief description of your command",
-    Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+    Use:   "remove [--remove-repo] <pkg>...",
+    Short: "Remove packages installed by rpm-get",
+    Long: `Remove packages installed by rpm-get.
+Packages that were not installed by rpm-get are left alone.
+When --remove-repo is provided, also remove the repository of repository packages,
+unless another package installed by rpm-get still uses it.`,
     Run: func(cmd *cobra.Command, args []string) {
-        fmt.Println("remove called")
+        if len(args) == 0 {
+            _ = cmd.Help(); os.Exit(h.USAGE_EXIT_CODE)
+        }
+
+        if err := removePkgs(args, wantsRemoveRepo); err != nil {
+            h.Printc(err.Error(), h.ERROR, false)
+            os.Exit(h.ERROR_EXIT_CODE)
+        }
     },
 }
 
 func init() {
     rootCmd.AddCommand(removeCmd)
 
-    // Here you will define your flags and configuration settings.
+    removeCmd.Flags().BoolVar(&wantsRemoveRepo, "remove-repo", false, "Also remove the repository of the packages")
+}
+
+// removePkgs removes the given packages installed by rpm-get, and forgets about them.
+func removePkgs(names []string, withRepo bool) error {
+    if !isAdmin() {
+        h.Printc("rpm-get must be run as root!", h.ERROR, false)
+        os.Exit(h.ERROR_EXIT_CODE)
+    }
+
+    db := openState()
+    //nolint:errcheck
+    defer db.Close()
+
+    pkgs := []*state.Package {}
+    rpmNames := []string {}
+    for _, name := range names {
+        pkg := db.Get(name)
+        if pkg == nil {
+            h.Printc(fmt.Sprintf("%s was not installed by rpm-get, skipping", name), h.WARNING, false)
+            continue
+        }
+        pkgs = append(pkgs, pkg)
+        rpmNames = append(rpmNames, pkg.RpmName)
+    }
 
-    // Cobra supports Persistent Flags which will work for this command
-    // and all subcommands, e.g.:
-    // removeCmd.PersistentFlags().String("foo", "", "A help for foo")
+    if len(pkgs) == 0 { return nil }
 
-    // Cobra supports local flags which will only run when this command
-    // is called directly, e.g.:
-    // removeCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+    if err := removePkg(rpmNames...); err != nil { return err }
+
+    for _, pkg := range pkgs {
+        db.Delete(pkg.Name)
+
+        if withRepo && pkg.RepoFile != "" && !db.UsesRepoFile(pkg.RepoFile, pkg.Name) {
+            App, RepoName = pkg.Name, pkg.RepoFile
+            if removed, _ := removeRepo(); removed { db.DeleteRepo(pkg.RepoFile) }
+        }
+
+        h.Printc(fmt.Sprintf("Successfully removed %s", pkg.Name), h.INFO, true)
+    }
+
+    if err := db.Save(); err != nil {
+        h.Printc(err.Error(), h.ERROR, false)
+        return fmt.Errorf("Failed to record removed packages: %w", err)
+    }
+
+    return nil
 }
 
-// removePkg removes the requested RPM package.
-func removePkg(pkg string) {
+// removePkg removes the requested RPM packages.
+func removePkg(pkgs ...string) error {
     if !isAdmin() {
         h.Printc("rpm-get must be run as root!", h.ERROR, false)
         os.Exit(h.ERROR_EXIT_CODE)
     }
 
-    cmd := which("sudo") + " " + which("dnf")
-    args := []string { "remove", "-y", pkg }
-    command := exec.Command(cmd, args...)
-    out, err := command.Output()
-
-    if err != nil {
+    if err := pkgManager().Remove(pkgs...); err != nil {
         h.Printc(err.Error(), h.ERROR, false)
-    } else {
-        println(out)
+        return fmt.Errorf("Failed to remove packages: %w", err)
     }
+
+    return nil
 }
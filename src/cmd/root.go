@@ -1,9 +1,13 @@
 package cmd
 
 import (
+    "bytes"
     "crypto/sha256"
+    "crypto/sha512"
     "encoding/hex"
+    "errors"
     "fmt"
+    "hash"
     "io"
     "net/http"
     "os"
@@ -11,9 +15,13 @@
     "path/filepath"
     "runtime"
     "strings"
+    "sync"
 
     // third-party imports
+    "github.com/FlawlessCasual17/rpm-get/backend"
     h "github.com/FlawlessCasual17/rpm-get/helpers"
+    "github.com/FlawlessCasual17/rpm-get/httpclient"
+    "github.com/FlawlessCasual17/rpm-get/state"
     "github.com/samber/lo"
     "github.com/schollz/progressbar/v3"
     "github.com/spf13/cobra"
@@ -36,34 +44,47 @@
 
 var (
     wantsVersion bool
-    isHTML = false
     App = ""
-    Project = ""
-    RelType = ""
-    Creator = ""
-    ProjectID = ""
     RepoName = ""
     ConfigDir = filepath.Join(os.Getenv("HOME"), ".config/rpm-get")
     ConfigFile = filepath.Join(ConfigDir, "config.json")
     DataDir = filepath.Join(os.Getenv("HOME"), ".local/share/rpm-get")
+    // StateDir is the directory holding the state of installed packages, `ETC_DIR` by default.
+    StateDir = ETC_DIR
+    // CacheDir is the directory caching downloaded packages and API responses, `CACHE_DIR` by default.
+    CacheDir = CACHE_DIR
+    // IndexUrl is the base URL of the package index, containing the packages list and manifests.
+    IndexUrl = PKGS_REPO + "/raw/refs/heads/master"
     // UserAgent is the user agent string used for HTTP requests.
     UserAgent = fmt.Sprintf(
         "Mozilla/5.0 (X11; Linux %s) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/125.0.0.0 Safari/537.36",
         HOST_CPU)
-    GhHeaderAuth = fmt.Sprint("Bearer " + getEnv("GITHUB_TOKEN"))
+    // GlHeaderAuth is the gitlab.com access token, sent as the `PRIVATE-TOKEN` header.
+    // Self-hosted instances use the tokens of `Config.GitlabTokens` instead.
     GlHeaderAuth = getEnv("GITLAB_TOKEN")
+    // PkgManager is the package manager backend used to install packages.
+    // It's detected from the host when left unset.
+    PkgManager backend.Backend = nil
+    // HttpClient is the HTTP client used for every request.
+    // It's built from the config on first use when left unset.
+    HttpClient *http.Client = nil
 )
 
+// httpClientOnce guards the creation of `HttpClient`, which is used by concurrent downloads.
+var httpClientOnce sync.Once
+
+// ErrIntegrity is returned when a downloaded file doesn't match its expected hash.
+var ErrIntegrity = errors.New("integrity check failed")
+
 const (
     // VERSION is the current version of rpm-get.
     VERSION string = "0.0.1"
 
-    // ETC_DIR is the directory where rpm-get will store repositories.
+    // ETC_DIR is the directory where rpm-get will store the state of installed packages.
     ETC_DIR string = "/etc/rpm-get"
 
-    // TODO: Add support for Zypper repos
-
     // YUM_REPOS_DIR is the directory where rpm-get will store RPM repositories.
+    // On openSUSE, repositories are stored in `backend.ZYPP_REPOS_DIR` instead.
     YUM_REPOS_DIR string = "/etc/yum.repos.d"
 
     // CACHE_DIR is the directory where rpm-get will
@@ -141,6 +162,74 @@ func which(cmd string) string {
     return result
 }
 
+// pkgManager returns the package manager backend of the host, detecting it on first use.
+func pkgManager() backend.Backend {
+    if PkgManager == nil {
+        result, err := backend.Detect()
+        if err != nil {
+            h.Printc(err.Error(), h.ERROR, false)
+            os.Exit(h.ERROR_EXIT_CODE)
+        }
+        PkgManager = result
+    }
+
+    return PkgManager
+}
+
+// httpClient returns the shared HTTP client, building it on first use. The CA bundles of
+// the `ca_bundle` config and of the `RPM_GET_CA_BUNDLE` environment variable are trusted.
+func httpClient() *http.Client {
+    httpClientOnce.Do(func() {
+        if HttpClient != nil { return }
+
+        config, configErr := readConfig()
+        if configErr != nil {
+            h.Printc(configErr.Error(), h.ERROR, false)
+            os.Exit(h.ERROR_EXIT_CODE)
+        }
+
+        caBundles := lo.Compact([]string { config.CaBundle, getEnv("RPM_GET_CA_BUNDLE") })
+        client, err := httpclient.New(httpclient.Options { UserAgent: UserAgent, CaBundles: caBundles })
+        if err != nil {
+            h.Printc(err.Error(), h.ERROR, false)
+            os.Exit(h.ERROR_EXIT_CODE)
+        }
+        HttpClient = client
+    })
+
+    return HttpClient
+}
+
+// openState opens the state of installed packages for writing, exiting if it's locked.
+// The returned `state.DB` must be closed.
+func openState() *state.DB {
+    db, err := state.Open(StateDir)
+    if errors.Is(err, state.ErrLocked) {
+        h.Printc("Another instance of rpm-get is running!", h.ERROR, false)
+        os.Exit(h.ERROR_EXIT_CODE)
+    } else if err != nil {
+        h.Printc(err.Error(), h.ERROR, false)
+        os.Exit(h.ERROR_EXIT_CODE)
+    }
+
+    return db
+}
+
+// readState opens the state of installed packages for reading.
+// The returned `state.DB` must be closed.
+func readState() *state.DB {
+    db, err := state.Read(StateDir)
+    if errors.Is(err, state.ErrLocked) {
+        h.Printc("Another instance of rpm-get is running!", h.ERROR, false)
+        os.Exit(h.ERROR_EXIT_CODE)
+    } else if err != nil {
+        h.Printc(err.Error(), h.ERROR, false)
+        os.Exit(h.ERROR_EXIT_CODE)
+    }
+
+    return db
+}
+
 // getSha256Hash returns the SHA256 hash of the given file.
 func getSha256Hash(filePath string) string {
     file, fileErr := os.Open(filePath)
@@ -160,126 +249,201 @@ func getSha256Hash(filePath string) string {
     return hex.EncodeToString(hash.Sum(nil))
 }
 
-// downloadPkg downloads the requested RPM package.
-func downloadPkg(url string, filePath string) error {
-    downloadError := error (nil)
-    cacheFilePath := filepath.Join(CACHE_DIR, filePath)
+// downloadPkg downloads the requested RPM package to a `.part` file next to its cache path,
+// resuming a previous partial download when the server allows it. The RPM is only renamed into
+// place once it matches the given SHA-256 and SHA-512 hashes, when they're set. The partial
+// download is deleted when it doesn't match them.
+func downloadPkg(url string, filePath string, sha256Hash string, sha512Hash string) error {
+    cacheFilePath := filepath.Join(CacheDir, filePath)
+    partPath := cacheFilePath + PART_EXT
+
+    if err := os.MkdirAll(filepath.Dir(cacheFilePath), 0755); err != nil {
+        h.Printc("Unable to create cache dir!", h.ERROR, false)
+        return fmt.Errorf("Unable to create cache dir: %w", err)
+    }
 
-    lo.TryCatch(func() error { // try
-        request, _ := http.NewRequest("GET", url, nil)
-        request.Header.Set("User-Agent", UserAgent)
-        resp, err := http.DefaultClient.Do(request)
-        if err != nil {
-            h.Printc("Request failed!", h.ERROR, false)
-            downloadError = fmt.Errorf("Request failed: %w", err)
-            return downloadError
-        }
-        //nolint:errcheck
-        defer resp.Body.Close()
+    if err := fetchPart(url, partPath); err != nil {
+        h.Printc("Failed to download the requested RPM package!", h.ERROR, false)
+        return fmt.Errorf("Failed to download the requested RPM package: %w", err)
+    }
+
+    if err := verifyChecksums(partPath, sha256Hash, sha512Hash); err != nil {
+        removePart(partPath)
+        msg := fmt.Sprintf("Integrity check failed for %s (%s)!", App, url)
+        h.Printc(msg, h.ERROR, false)
+        return fmt.Errorf("%s downloaded from %s: %w", App, url, err)
+    }
+
+    if err := os.Rename(partPath, cacheFilePath); err != nil {
+        return fmt.Errorf("Failed to move %s into place: %w", partPath, err)
+    }
+    _ = os.Remove(partPath + PART_META_EXT)
+
+    return nil
+}
 
-        file, _ := os.OpenFile(cacheFilePath, os.O_CREATE|os.O_WRONLY, 0644)
-        //nolint:errcheck
-        defer file.Close()
+// fetchPart downloads `url` to the given partial download, resuming it when possible.
+// The partial download is kept when the transfer is interrupted.
+func fetchPart(url string, partPath string) error {
+    offset, headers := resumeHeaders(partPath, url)
 
-        bar := progressbar.DefaultBytes(resp.ContentLength, "Downloading...")
+    request, reqErr := http.NewRequest("GET", url, nil)
+    if reqErr != nil { return fmt.Errorf("Invalid request: %w", reqErr) }
+    for key, value := range headers { request.Header.Set(key, value) }
 
-        if _, err := io.Copy(io.MultiWriter(file, bar), resp.Body); err != nil {
-            h.Printc("Failed to download the requested RPM package!", h.ERROR, false)
-            downloadError = fmt.Errorf("Failed to download the requested RPM package: %w", err)
-            return downloadError
+    resp, respErr := httpClient().Do(request)
+    if respErr != nil { return fmt.Errorf("Request failed: %w", respErr) }
+    //nolint:errcheck
+    defer resp.Body.Close()
+
+    flags := os.O_CREATE|os.O_WRONLY
+    switch {
+    case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+        // The partial download is not a prefix of the file anymore, start over.
+        removePart(partPath)
+        return fetchPart(url, partPath)
+    case resp.StatusCode == http.StatusPartialContent:
+        if err := checkContentRange(resp, offset); err != nil {
+            removePart(partPath)
+            return err
         }
+        flags |= os.O_APPEND
+        h.Printc(fmt.Sprintf("Resuming the download of %s", App), h.INFO, false)
+    case resp.StatusCode == http.StatusOK:
+        // The server sends the whole file when it changed since the partial download was started.
+        offset = 0
+        flags |= os.O_TRUNC
+        if err := writePartMeta(partPath, newPartMeta(url, resp)); err != nil { return err }
+    default:
+        return httpclient.CheckStatus(resp)
+    }
 
-        return nil
-    }, func() { // catch
-        h.Printc("Failed to download the requested RPM package!", h.ERROR, false)
-        os.Exit(h.ERROR_EXIT_CODE)
-    })
+    file, fileErr := os.OpenFile(partPath, flags, 0644)
+    if fileErr != nil { return fmt.Errorf("Failed to open %s: %w", partPath, fileErr) }
+    //nolint:errcheck
+    defer file.Close()
+
+    size := lo.Ternary(resp.ContentLength < 0, -1, offset + resp.ContentLength)
+    bar := progressbar.DefaultBytes(size, "Downloading...")
+    _ = bar.Set64(offset)
+
+    if _, err := io.Copy(io.MultiWriter(file, bar), resp.Body); err != nil {
+        return fmt.Errorf("The download was interrupted, run the command again to resume it: %w", err)
+    }
 
-    return downloadError
+    return file.Close()
 }
 
-// addRepo adds the given RPM repo to the YUM repos directory.
-func addRepo(repoUrl string) {
+// verifyChecksums checks the given file against its expected SHA-256 and SHA-512 hashes.
+// Hashes that are empty are not checked.
+func verifyChecksums(filePath string, sha256Hash string, sha512Hash string) error {
+    expected := map[string]string { "SHA-256": sha256Hash, "SHA-512": sha512Hash }
+    hashes := map[string]hash.Hash { "SHA-256": sha256.New(), "SHA-512": sha512.New() }
+
+    file, fileErr := os.Open(filePath)
+    if fileErr != nil { return fmt.Errorf("Failed to open file: %w", fileErr) }
+    //nolint:errcheck
+    defer file.Close()
+
+    if _, err := io.Copy(io.MultiWriter(hashes["SHA-256"], hashes["SHA-512"]), file); err != nil {
+        return fmt.Errorf("Failed to read file: %w", err)
+    }
+
+    for _, name := range []string { "SHA-256", "SHA-512" } {
+        if expected[name] == "" { continue }
+
+        actual := hex.EncodeToString(hashes[name].Sum(nil))
+        if !strings.EqualFold(actual, expected[name]) {
+            return fmt.Errorf("%w: %s is %s, expected %s", ErrIntegrity, name, actual, expected[name])
+        }
+    }
+
+    return nil
+}
+
+// addRepo adds the given RPM repo to the repos directory of the package manager, and returns
+// the fingerprints of its GPG keys. The key at `gpgKeyUrl` is imported when it's set, otherwise
+// the keys named by the enabled sections of the repo file are, see `backend.RepoKeys`. Keys are
+// imported with `importKeys`, so that they're checked and recorded, before the package manager
+// would import them on its own.
+func addRepo(db *state.DB, repoUrl string, gpgKeyUrl string) ([]string, error) {
     if !isAdmin() {
         h.Printc("rpm-get must be run as root!", h.ERROR, false)
         os.Exit(h.ERROR_EXIT_CODE)
     }
 
-    baseName := strings.Split(repoUrl, "/")[-0]
-    tmpFilePath := filepath.Join(YUM_REPOS_DIR, baseName + ".tmp")
-    filePath := strings.ReplaceAll(tmpFilePath, ".tmp", "")
-
-    // Download packages-list.json
-    lo.TryCatch(func() error { // try
-        request, _ := http.NewRequest("GET", repoUrl, nil)
-        request.Header.Set("User-Agent", UserAgent)
-        resp, respErr := http.DefaultClient.Do(request)
-        if respErr != nil {
-            h.Printc("Request failed!", h.ERROR, false)
-            return respErr
-        }
-        //nolint:errcheck
-        defer resp.Body.Close()
-
-        file, _ := os.OpenFile(tmpFilePath, os.O_CREATE|os.O_WRONLY, 0644)
-        //nolint:errcheck
-        defer file.Close()
+    baseName := repoUrl[strings.LastIndex(repoUrl, "/") + 1:]
+    content := bytes.Buffer {}
 
-        bar := progressbar.DefaultBytes(resp.ContentLength, "Downloading RPM repo...")
-        //nolint:errcheck
-        io.Copy(io.MultiWriter(file, bar), resp.Body)
+    // Download the .repo file
+    if _, err := download(repoUrl, &content, "Downloading RPM repo...", nil); err != nil {
+        h.Printc("Unable to download the RPM repo!", h.ERROR, false)
+        return nil, fmt.Errorf("Failed to download the RPM repo: %w", err)
+    }
 
-        return nil
-    }, func() { // catch
-        h.Printc("Unable to update packages list!", h.ERROR, false)
-        os.Exit(h.ERROR_EXIT_CODE)
-    })
+    fingerprints := []string {}
+    if gpgKeyUrl != "" {
+        keyFingerprints, err := importKeys(db, gpgKeyUrl)
+        if err != nil { return nil, err }
+        fingerprints = keyFingerprints
+    } else {
+        keyUrls, keysErr := backend.RepoKeys(content.Bytes())
+        if keysErr != nil { return nil, fmt.Errorf("Failed to add the repo for %s: %w", App, keysErr) }
+
+        // The package manager imports the keys of the repo on its own, and still checks the
+        // packages against them, so a key of the repo file that can't be imported isn't fatal.
+        for _, keyUrl := range keyUrls {
+            keyFingerprints, err := importKeys(db, keyUrl)
+            if err != nil {
+                msg := fmt.Sprintf("Skipping the key at %s, %s will import it: %s", keyUrl, pkgManager().Name(), err)
+                h.Printc(msg, h.WARNING, false)
+                continue
+            }
+            fingerprints = append(fingerprints, keyFingerprints...)
+        }
+    }
 
-    if err := os.Rename(tmpFilePath, filePath); err != nil {
+    repoName, err := pkgManager().AddRepo(baseName, content.Bytes(), gpgKeyUrl)
+    if err != nil {
         h.Printc(err.Error(), h.ERROR, false)
-    } else {
-        RepoName = baseName
-        msg := fmt.Sprint("Successfully added the repo for " + App)
-        h.Printc(msg, h.INFO, true)
+        return nil, fmt.Errorf("Failed to add the repo for %s: %w", App, err)
     }
+
+    RepoName = repoName
+    msg := fmt.Sprint("Successfully added the repo for " + App)
+    h.Printc(msg, h.INFO, true)
+
+    return fingerprints, nil
 }
 
-// addCoprRepo adds the given Fedora COPR repo to the YUM repos directory.
+// addCoprRepo enables the given Fedora COPR repo.
 func addCoprRepo(username string, project string) error {
     if !isAdmin() {
         h.Printc("rpm-get must be run as root!", h.ERROR, false)
         os.Exit(h.ERROR_EXIT_CODE)
     }
 
-    cmd := which("sudo") + " " + which("dnf")
-    coprRepo := username + "/" + project
-    args := []string { "copr", "enable", "-y", coprRepo }
-    command := exec.Command(cmd, args...)
-    out, err := command.Output()
-
+    repoName, err := pkgManager().AddCoprRepo(username, project)
     if err != nil {
         h.Printc(err.Error(), h.ERROR, false)
-        return fmt.Errorf("Command failed: %w", err)
+        return fmt.Errorf("Failed to add the repo for %s: %w", App, err)
     }
 
-    RepoName = fmt.Sprintf("_copr:copr.fedorainfracloud.org:%s:%s", username, project)
-    println(out)
+    RepoName = repoName
     msg := fmt.Sprint("Successfully added the repo for " + App)
     h.Printc(msg, h.INFO, true)
 
     return nil
 }
 
-// removeRepo removes the repo of an application from the YUM repos directory.
+// removeRepo removes the repo of an application from the repos directory of the package manager.
 func removeRepo() (bool, error) {
     if !isAdmin() {
         h.Printc("rpm-get must be run as root!", h.ERROR, false)
         os.Exit(h.ERROR_EXIT_CODE)
     }
 
-    filePath := filepath.Join(YUM_REPOS_DIR, RepoName)
-
-    if err := os.Remove(filePath); err != nil {
+    if err := pkgManager().RemoveRepo(RepoName); err != nil {
         msg := fmt.Sprint("Failed to remove the repo for " + App)
         h.Printc(msg, h.ERROR, false)
         return false, fmt.Errorf("%s: %w", msg, err)
@@ -0,0 +1,294 @@
+package cmd
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "crypto/sha512"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/FlawlessCasual17/rpm-get/backend"
+    "github.com/FlawlessCasual17/rpm-get/state"
+)
+
+// fakeBackend is a `backend.Backend` that records what it's asked to do instead of running
+// a package manager.
+type fakeBackend struct {
+    reposDir string
+    // Installed `epoch:version-release` by package name
+    installed map[string]string
+    // Error returned by `InstalledVersion` for packages missing from `installed`,
+    // `backend.ErrNotInstalled` when nil
+    queryErr error
+    // Files and packages of every `Install` transaction
+    installs [][]string
+    upgraded []string
+    removed []string
+    // Content of the added repo files, by name
+    repos map[string][]byte
+    coprRepos []string
+}
+
+// newFakeBackend creates a `fakeBackend` and installs it as `PkgManager` for the duration of the test.
+func newFakeBackend(t *testing.T) *fakeBackend {
+    t.Helper()
+
+    fake := &fakeBackend { installed: map[string]string {}, repos: map[string][]byte {} }
+    previous := PkgManager
+    PkgManager = fake
+    t.Cleanup(func() { PkgManager = previous })
+
+    return fake
+}
+
+func (f *fakeBackend) Name() string { return "fake" }
+
+func (f *fakeBackend) ReposDir() string { return f.reposDir }
+
+func (f *fakeBackend) Install(files []string, pkgs []string) error {
+    f.installs = append(f.installs, append(slices.Clone(files), pkgs...))
+    return nil
+}
+
+func (f *fakeBackend) Upgrade(pkgs ...string) error {
+    f.upgraded = append(f.upgraded, pkgs...)
+    return nil
+}
+
+func (f *fakeBackend) Reinstall(_ ...string) error { return nil }
+
+func (f *fakeBackend) Remove(pkgs ...string) error {
+    f.removed = append(f.removed, pkgs...)
+    return nil
+}
+
+func (f *fakeBackend) InstalledVersion(pkg string) (string, error) {
+    if version, ok := f.installed[pkg]; ok { return version, nil }
+    if f.queryErr != nil { return "", f.queryErr }
+    return "", backend.ErrNotInstalled
+}
+
+func (f *fakeBackend) AddRepo(name string, content []byte, _ string) (string, error) {
+    f.repos[name] = content
+    return name, nil
+}
+
+func (f *fakeBackend) AddCoprRepo(username string, project string) (string, error) {
+    f.coprRepos = append(f.coprRepos, username + "/" + project)
+    return fmt.Sprintf("_copr:copr.fedorainfracloud.org:%s:%s.repo", username, project), nil
+}
+
+func (f *fakeBackend) RemoveRepo(name string) error {
+    delete(f.repos, name)
+    return nil
+}
+
+// testServer serves the given handler for the duration of the test, and makes it the
+// `HttpClient` of rpm-get. The paths it was asked for are recorded, in order.
+type testServer struct {
+    *httptest.Server
+    mutex sync.Mutex
+    paths []string
+}
+
+// newTestServer starts a `testServer`.
+func newTestServer(t *testing.T, handler http.Handler) *testServer {
+    t.Helper()
+
+    server := &testServer {}
+    server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        server.mutex.Lock()
+        server.paths = append(server.paths, r.URL.Path)
+        server.mutex.Unlock()
+        handler.ServeHTTP(w, r)
+    }))
+    t.Cleanup(server.Close)
+
+    previous := HttpClient
+    HttpClient = server.Client()
+    t.Cleanup(func() { HttpClient = previous })
+
+    return server
+}
+
+// requested returns the paths the server was asked for, in order.
+func (s *testServer) requested() []string {
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+    return slices.Clone(s.paths)
+}
+
+// newPkgServer starts a `testServer` serving the given content for every path.
+func newPkgServer(t *testing.T, content []byte) *testServer {
+    t.Helper()
+
+    return newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        http.ServeContent(w, r, "app.rpm", time.Time {}, bytes.NewReader(content))
+    }))
+}
+
+// useTestDirs points `DataDir`, `StateDir` and `CacheDir` to new directories for the duration of the test.
+func useTestDirs(t *testing.T) {
+    t.Helper()
+
+    dataDir, stateDir, cacheDir := DataDir, StateDir, CacheDir
+    DataDir, StateDir, CacheDir = t.TempDir(), t.TempDir(), t.TempDir()
+    t.Cleanup(func() { DataDir, StateDir, CacheDir = dataDir, stateDir, cacheDir })
+}
+
+// writeIndex writes the given manifests, by package name, as the current package index.
+func writeIndex(t *testing.T, manifests map[string]string) {
+    t.Helper()
+
+    if err := os.MkdirAll(indexDir(), 0755); err != nil { t.Fatal(err) }
+    for name, content := range manifests {
+        if err := os.WriteFile(filepath.Join(indexDir(), name + ".yaml"), []byte(content), 0644); err != nil { t.Fatal(err) }
+    }
+}
+
+// testManifest returns the manifest of a direct download package available for every architecture,
+// with the given extra YAML appended.
+func testManifest(name string, version string, extra string) string {
+    return fmt.Sprintf(`name: %[1]s
+version: "%[2]s"
+description: The %[1]s package
+pkg_arches: [x86_64, x86, arm64]
+arch:
+  x86_64: { url: "https://example.com/%[1]s-%[2]s.x86_64.rpm" }
+  x86: { url: "https://example.com/%[1]s-%[2]s.i686.rpm" }
+  arm64: { url: "https://example.com/%[1]s-%[2]s.aarch64.rpm" }
+`, name, version) + extra
+}
+
+// openTestState opens a new, empty state for the duration of the test.
+func openTestState(t *testing.T) *state.DB {
+    t.Helper()
+
+    db, err := state.Open(t.TempDir())
+    if err != nil { t.Fatal(err) }
+    //nolint:errcheck
+    t.Cleanup(func() { db.Close() })
+
+    return db
+}
+
+func TestAddRepoKeys(t *testing.T) {
+    repoFile := `[app]
+name=App
+baseurl=https://example.com/rpm/$basearch
+gpgkey=%[1]s/key1.asc,%[1]s/key2.asc
+    file:///etc/pki/rpm-gpg/RPM-GPG-KEY-app-$releasever
+
+[app-testing]
+name=App testing
+enabled=0
+gpgkey=%[1]s/testing.asc
+`
+    mux := http.NewServeMux()
+    server := newTestServer(t, mux)
+    mux.HandleFunc("/app.repo", func(w http.ResponseWriter, _ *http.Request) {
+        fmt.Fprintf(w, repoFile, server.URL)
+    })
+    // The keys are missing or invalid, which only skips them.
+    mux.HandleFunc("/key2.asc", func(w http.ResponseWriter, _ *http.Request) { fmt.Fprint(w, "<html></html>") })
+
+    fake := newFakeBackend(t)
+    db := openTestState(t)
+
+    fingerprints, err := addRepo(db, server.URL + "/app.repo", "")
+    if err != nil { t.Fatalf("addRepo() failed: %v", err) }
+    if len(fingerprints) != 0 { t.Errorf("addRepo() = %v, want no fingerprints", fingerprints) }
+
+    // Only the keys of enabled sections, without repo variables, are fetched.
+    if want := []string { "/app.repo", "/key1.asc", "/key2.asc" }; !slices.Equal(server.requested(), want) {
+        t.Errorf("addRepo() requested %v, want %v", server.requested(), want)
+    }
+    if _, ok := fake.repos["app.repo"]; !ok || RepoName != "app.repo" {
+        t.Errorf("addRepo() added %v as %q, want app.repo", fake.repos, RepoName)
+    }
+}
+
+func TestAddRepoGpgKeyUrl(t *testing.T) {
+    mux := http.NewServeMux()
+    server := newTestServer(t, mux)
+    mux.HandleFunc("/app.repo", func(w http.ResponseWriter, _ *http.Request) {
+        fmt.Fprintf(w, "[app]\nbaseurl=https://example.com/rpm\ngpgkey=%s/repo.asc\n", server.URL)
+    })
+
+    fake := newFakeBackend(t)
+    db := openTestState(t)
+
+    // The key of the manifest replaces the ones of the repo file, and must be imported.
+    if _, err := addRepo(db, server.URL + "/app.repo", server.URL + "/manifest.asc"); err == nil {
+        t.Error("addRepo() succeeded without its key")
+    }
+    if want := []string { "/app.repo", "/manifest.asc" }; !slices.Equal(server.requested(), want) {
+        t.Errorf("addRepo() requested %v, want %v", server.requested(), want)
+    }
+    if len(fake.repos) != 0 { t.Errorf("addRepo() added %v without its key", fake.repos) }
+}
+
+func TestDownloadPkg(t *testing.T) {
+    content := []byte("the content of app.rpm")
+    sum256, sum512 := sha256.Sum256(content), sha512.Sum512(content)
+    sha256Hash, sha512Hash := hex.EncodeToString(sum256[:]), hex.EncodeToString(sum512[:])
+    wrong256, wrong512 := strings.Repeat("0", 64), strings.Repeat("0", 128)
+
+    tests := []struct {
+        name string
+        sha256Hash string
+        sha512Hash string
+        // Hash named by the error, empty when the download must succeed
+        failing string
+    }{
+        { "matching hashes", sha256Hash, sha512Hash, "" },
+        { "matching uppercase hash", strings.ToUpper(sha256Hash), "", "" },
+        { "no hashes", "", "", "" },
+        { "SHA-256 mismatch", wrong256, sha512Hash, "SHA-256" },
+        { "SHA-512 mismatch", sha256Hash, wrong512, "SHA-512" },
+        { "SHA-512 mismatch only", "", wrong512, "SHA-512" },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            useTestDirs(t)
+            server := newPkgServer(t, content)
+            previous := App
+            App = "app"
+            t.Cleanup(func() { App = previous })
+
+            url := server.URL + "/app-1.0.0.x86_64.rpm"
+            filePath := filepath.Join("app", "app-1.0.0.x86_64.rpm")
+            cacheFilePath := filepath.Join(CacheDir, filePath)
+
+            err := downloadPkg(url, filePath, test.sha256Hash, test.sha512Hash)
+
+            if test.failing == "" {
+                if err != nil { t.Fatalf("downloadPkg() failed: %v", err) }
+                if got, _ := os.ReadFile(cacheFilePath); !bytes.Equal(got, content) {
+                    t.Errorf("downloadPkg() wrote %q, want %q", got, content)
+                }
+            } else {
+                if !errors.Is(err, ErrIntegrity) { t.Fatalf("downloadPkg() = %v, want %v", err, ErrIntegrity) }
+                for _, want := range []string { App, url, test.failing } {
+                    if !strings.Contains(err.Error(), want) { t.Errorf("downloadPkg() = %q, want it to name %s", err, want) }
+                }
+                if fileExists(cacheFilePath) { t.Error("downloadPkg() moved the corrupted RPM into place") }
+            }
+
+            for _, leftover := range []string { cacheFilePath + PART_EXT, cacheFilePath + PART_EXT + PART_META_EXT } {
+                if fileExists(leftover) { t.Errorf("downloadPkg() left %s behind", leftover) }
+            }
+        })
+    }
+}
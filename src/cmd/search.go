@@ -2,35 +2,140 @@
 
 import (
     "fmt"
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
 
+    h "github.com/FlawlessCasual17/rpm-get/helpers"
+    "github.com/FlawlessCasual17/rpm-get/manifest"
+    "github.com/FlawlessCasual17/rpm-get/state"
+    "github.com/samber/lo"
     "github.com/spf13/cobra"
 )
 
+var (
+    // searchOs is set by `search --os`.
+    searchOs string
+    // searchArch is set by `search --arch`.
+    searchArch string
+    // searchLicense is set by `search --license`.
+    searchLicense string
+    // searchSource is set by `search --source`.
+    searchSource string
+)
+
+// SEARCH_SOURCES lists the values accepted by `search --source`.
+var SEARCH_SOURCES = []string { state.SOURCE_COPR, state.SOURCE_REPO, state.SOURCE_DIRECT }
+
 // searchCmd represents the search command
 var searchCmd = &cobra.Command{
-    Use:   "search",
-    Short: "A brief description of your command",
-    Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+    Use:   "search [--os ID] [--arch ARCH] [--license ID] [--source copr|repo|direct] [<term>...]",
+    Short: "Search the packages available via rpm-get",
+    Long: `Search the name, homepage and description of the packages available via rpm-get,
+allowing typos, and list the matches from the most to the least relevant.
+Every term must match. The packages installed by rpm-get are marked as such.
+When only filters are provided, list every package matching them.`,
     Run: func(cmd *cobra.Command, args []string) {
-        fmt.Println("search called")
+        filtered := searchOs != "" || searchArch != "" || searchLicense != "" || searchSource != ""
+        if len(args) == 0 && !filtered {
+            _ = cmd.Help(); os.Exit(h.USAGE_EXIT_CODE)
+        }
+        if searchSource != "" && !slices.Contains(SEARCH_SOURCES, searchSource) {
+            h.Printc("--source must be one of " + strings.Join(SEARCH_SOURCES, ", "), h.ERROR, false)
+            _ = cmd.Usage(); os.Exit(h.USAGE_EXIT_CODE)
+        }
+
+        if err := searchPkgs(args); err != nil {
+            h.Printc(err.Error(), h.ERROR, false)
+            os.Exit(h.ERROR_EXIT_CODE)
+        }
     },
 }
 
 func init() {
     rootCmd.AddCommand(searchCmd)
 
-    // Here you will define your flags and configuration settings.
+    searchCmd.Flags().StringVar(&searchOs, "os", "", "Only show the packages supporting this distribution, e.g. fedora")
+    searchCmd.Flags().StringVar(&searchArch, "arch", "", "Only show the packages available for this architecture, e.g. arm64")
+    searchCmd.Flags().StringVar(&searchLicense, "license", "", "Only show the packages under this SPDX license, e.g. MIT")
+    searchCmd.Flags().StringVar(&searchSource, "source", "", "Only show the packages installed from copr, repo or direct")
+}
+
+// searchResult is a package matching a search.
+type searchResult struct {
+    pkg *manifest.Pkg
+    score int
+}
+
+// searchPkgs prints the packages of the package index matching the given terms and the search flags,
+// the most relevant first.
+func searchPkgs(terms []string) error {
+    names, err := manifestNames()
+    if err != nil { return err }
 
-    // Cobra supports Persistent Flags which will work for this command
-    // and all subcommands, e.g.:
-    // searchCmd.PersistentFlags().String("foo", "", "A help for foo")
+    db := readState()
+    //nolint:errcheck
+    defer db.Close()
+
+    results := []searchResult {}
+    for _, name := range names {
+        data, loadErr := manifest.Load(filepath.Join(indexDir(), name + ".yaml"))
+        if loadErr != nil {
+            h.Printc(fmt.Sprintf("Skipping %s: %s", name, loadErr), h.WARNING, false)
+            continue
+        }
+        if !matchesSearchFilters(data) { continue }
+
+        // Without terms, every package matching the filters is listed by name.
+        score := 1
+        if len(terms) > 0 { score = data.Score(terms) }
+        if score > 0 { results = append(results, searchResult { pkg: data, score: score }) }
+    }
+
+    slices.SortStableFunc(results, func(a searchResult, b searchResult) int {
+        if a.score != b.score { return b.score - a.score }
+        return strings.Compare(a.pkg.Name, b.pkg.Name)
+    })
+
+    if len(results) == 0 {
+        h.Printc("No packages were found", h.INFO, false)
+        return nil
+    }
+    for _, result := range results {
+        installed := db.Get(result.pkg.Name) != nil
+        fmt.Printf("%s\t%s%s\t%s\n",
+            result.pkg.Name, result.pkg.Version, lo.Ternary(installed, " [installed]", ""), result.pkg.Description)
+    }
+
+    return nil
+}
+
+// matchesSearchFilters reports whether the package matches the search flags.
+func matchesSearchFilters(data *manifest.Pkg) bool {
+    switch {
+    case searchOs != "" && !data.SupportsOs(searchOs):
+        return false
+    case searchArch != "" && !data.SupportsArch(searchArch):
+        return false
+    case searchLicense != "" && !data.HasLicense(searchLicense):
+        return false
+    case searchSource != "" && manifestSource(data) != searchSource:
+        return false
+    default:
+        return true
+    }
+}
 
-    // Cobra supports local flags which will only run when this command
-    // is called directly, e.g.:
-    // searchCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+// manifestSource returns where the package is installed from, one of `state.SOURCE_COPR`,
+// `state.SOURCE_REPO` or `state.SOURCE_DIRECT`.
+func manifestSource(data *manifest.Pkg) string {
+    switch {
+    case data.Repo != nil && data.Repo.CoprRepo != nil:
+        return state.SOURCE_COPR
+    case data.Repo != nil && data.Repo.UrlRepo != nil:
+        return state.SOURCE_REPO
+    default:
+        return state.SOURCE_DIRECT
+    }
 }
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+    "errors"
+    "fmt"
+    "net/url"
+    "path/filepath"
+    "slices"
+    "strings"
+    "time"
+
+    h "github.com/FlawlessCasual17/rpm-get/helpers"
+    "github.com/FlawlessCasual17/rpm-get/manifest"
+    "github.com/FlawlessCasual17/rpm-get/release"
+)
+
+// API_CACHE_NAME is the name of the directory, inside `CacheDir`, caching release API responses.
+const API_CACHE_NAME string = "api"
+
+// resolvePkg loads the manifest of the given package and, when it has a release source,
+// resolves its newest version and the download URL of the RPM for the host architecture.
+// The `{version}` placeholder of `arch` URLs is replaced by the version.
+func resolvePkg(pkg string) (*manifest.Pkg, error) {
+    data, err := loadPkg(pkg)
+    if err != nil { return nil, err }
+
+    if data.VersionSource != nil {
+        source := data.VersionSource
+        resolver := release.NewDocument()
+        resolver.UserAgent, resolver.Client = UserAgent, httpClient()
+
+        version, versionErr := resolver.Version(source.Url, string(source.Format), source.Path, source.Regex, source.Replace)
+        if versionErr != nil { return nil, fmt.Errorf("Unable to resolve the version of %s: %w", data.Name, versionErr) }
+        data.Version = version
+    }
+
+    for _, key := range data.Arch.Keys() {
+        arch := data.Arch.Get(key)
+        arch.Url = strings.ReplaceAll(arch.Url, "{version}", data.Version)
+    }
+
+    if data.Source == nil { return data, nil }
+
+    archKey := manifest.ArchKey(HOST_CPU)
+    if archKey == "" || !slices.Contains(data.PkgArches, archKey) {
+        return nil, fmt.Errorf("%s is not available for %s", data.Name, HOST_CPU)
+    }
+
+    rel, relErr := latestRelease(data, archKey)
+    if relErr != nil { return nil, fmt.Errorf("Unable to resolve the latest release of %s: %w", data.Name, relErr) }
+
+    assetRegex := ""
+    if arch := data.Arch.Get(archKey); arch != nil { assetRegex = arch.AssetRegex }
+
+    asset, assetErr := release.PickAsset(rel.Assets, assetRegex, archKey)
+    if assetErr != nil { return nil, fmt.Errorf("%s %s: %w", data.Name, rel.Version, assetErr) }
+
+    data.Version = rel.Version
+    data.Arch.Set(archKey, &manifest.PkgArch { Url: asset.Url, AssetRegex: assetRegex })
+
+    return data, nil
+}
+
+// latestRelease fetches the newest release of the given package for the given architecture.
+func latestRelease(data *manifest.Pkg, archKey string) (*release.Release, error) {
+    source := data.Source
+
+    switch {
+    case source.Github != nil:
+        github := source.Github
+
+        resolver := release.NewGithub(getEnv("GITHUB_TOKEN"))
+        resolver.UserAgent, resolver.Client, resolver.Cache = UserAgent, httpClient(), apiCache()
+
+        rel, err := resolver.Latest(github.Creator, github.Project, github.RelType == manifest.REL_TYPE_PRERELEASE, github.VersionRegex)
+        rateLimitErr := &release.RateLimitError {}
+        if errors.As(err, &rateLimitErr) && resolver.Token == "" {
+            return nil, fmt.Errorf("%w, set GITHUB_TOKEN to raise the limit", err)
+        }
+        return rel, err
+    case source.Gitlab != nil:
+        gitlab := source.Gitlab
+        token, tokenErr := gitlabToken(gitlab.InstanceUrl)
+        if tokenErr != nil { return nil, tokenErr }
+
+        resolver := release.NewGitlab(gitlab.InstanceUrl, token)
+        resolver.UserAgent, resolver.Client, resolver.Cache = UserAgent, httpClient(), apiCache()
+        return resolver.Latest(gitlab.ProjectId, gitlab.VersionRegex)
+    case source.Html != nil:
+        pageUrl := source.Html.Url
+        if arch := data.Arch.Get(archKey); arch != nil && arch.Url != "" { pageUrl = arch.Url }
+
+        resolver := release.NewHtml()
+        resolver.UserAgent, resolver.Client = UserAgent, httpClient()
+        return resolver.Latest(pageUrl, source.Html.Xpath, source.Html.VersionRegex)
+    default:
+        return nil, errors.New("Unknown release source")
+    }
+}
+
+// gitlabToken returns the access token to send to the given GitLab instance. Manifests come
+// from a remote index and may name any instance, so `GITLAB_TOKEN` is only sent to gitlab.com,
+// and other instances only get the token the user set for their host in the config.
+func gitlabToken(instanceUrl string) (string, error) {
+    if instanceUrl == "" { instanceUrl = release.GITLAB_URL }
+    u, err := url.Parse(instanceUrl)
+    if err != nil { return "", fmt.Errorf("Invalid GitLab instance URL %q: %w", instanceUrl, err) }
+
+    gitlabCom, _ := url.Parse(release.GITLAB_URL)
+    if u.Scheme == "https" && strings.EqualFold(u.Host, gitlabCom.Host) { return GlHeaderAuth, nil }
+
+    // Tokens must not be sent in the clear.
+    if u.Scheme != "https" { return "", nil }
+
+    config, configErr := readConfig()
+    if configErr != nil { return "", configErr }
+    for host, token := range config.GitlabTokens {
+        if strings.EqualFold(host, u.Host) { return token, nil }
+    }
+    return "", nil
+}
+
+// apiCache returns the cache of release API responses, which warns when an expired
+// response is used because the API is rate limited or unreachable.
+func apiCache() *release.Cache {
+    cache := release.NewCache(filepath.Join(CacheDir, API_CACHE_NAME))
+    cache.OnStale = func(rawUrl string, fetchedAt time.Time, err error) {
+        age := time.Since(fetchedAt).Round(time.Minute)
+        msg := fmt.Sprintf("%s, using the response of %s cached %s ago", err, rawUrl, age)
+        h.Printc(msg, h.WARNING, false)
+    }
+
+    return cache
+}
@@ -1,153 +1,506 @@
 package cmd
 
 import (
+    "bytes"
+    "errors"
     "fmt"
     "io"
     "net/http"
     "os"
     "path/filepath"
+    "slices"
+    "strconv"
     "strings"
+    "sync"
+    "time"
 
     h "github.com/FlawlessCasual17/rpm-get/helpers"
+    "github.com/FlawlessCasual17/rpm-get/httpclient"
+    "github.com/FlawlessCasual17/rpm-get/manifest"
     "github.com/goccy/go-json"
     "github.com/samber/lo"
     "github.com/schollz/progressbar/v3"
     "github.com/spf13/cobra"
 )
 
+const (
+    // INDEX_NAME is the name of the symlink, inside `DataDir`, to the current package index.
+    INDEX_NAME string = "index"
+
+    // PKGS_LIST_NAME is the name of the packages list inside the package index.
+    PKGS_LIST_NAME string = "packages-list.json"
+
+    // VALIDATORS_NAME is the name of the file, inside `DataDir`, storing the `ETag` and
+    // `Last-Modified` headers of every file of the current package index.
+    VALIDATORS_NAME string = "validators.json"
+
+    // DEFAULT_JOBS is the default number of manifests downloaded concurrently.
+    DEFAULT_JOBS int = 8
+
+    // MAX_ATTEMPTS is the number of times a file of the package index is downloaded when its
+    // transfer is interrupted.
+    MAX_ATTEMPTS int = 3
+)
+
+var (
+    // updateJobs is set by `update --jobs`.
+    updateJobs int
+    // wantsInsecure is set by `update --insecure`.
+    wantsInsecure bool
+)
+
+// retryDelay is the delay before the second attempt of a download, doubled for each later attempt.
+var retryDelay = time.Second
+
+// maxDownloadSize is the size above which `download` gives up, so that a broken or malicious
+// server can't exhaust the memory or the disk. The index bundle is the largest file it downloads.
+var maxDownloadSize int64 = 64 << 20
+
+// errInterrupted is returned when the transfer of a response body fails.
+var errInterrupted = errors.New("the transfer was interrupted")
+
+// validator holds the headers used to make a conditional request for a URL.
+type validator struct {
+    ETag string           `json:"etag,omitempty"`
+    LastModified string   `json:"last_modified,omitempty"`
+}
+
+// indexSync is the state of an update of the package index.
+// It's shared by the workers downloading manifests.
+type indexSync struct {
+    mutex sync.Mutex
+    // Staging directory of the new index
+    dir string
+    // Validators of the current index, by URL
+    previous map[string]validator
+    // Validators of the new index, by URL
+    current map[string]validator
+    // Number of files that were not modified since the previous update
+    unmodified int
+    verifier *indexVerifier
+    // Signed digests of the files of the index, by path relative to `IndexUrl`, for the per-file mode
+    sums map[string]string
+}
+
+// manifestFailure is a manifest that could not be updated.
+type manifestFailure struct {
+    pkg string
+    err error
+    // Whether the manifest of the current index was kept instead
+    kept bool
+}
+
 // updateCmd represents the update command
 var updateCmd = &cobra.Command{
-    Use:   "update",
-    Short: "A brief description of your command",
-    Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-    Run: func(cmd *cobra.Command, args []string) {
-        fmt.Println("update called")
+    Use:   "update [--jobs N] [--insecure]",
+    Short: "Update the package index",
+    Long: `Update the package index from the rpm-get.Packages repository.
+The index bundle, or when it's not published the packages list and every package manifest
+using up to --jobs concurrent downloads, is downloaded to a staging directory
+and validated before replacing the current index.
+Manifests that fail to download or validate keep their current version, and are reported at the end.
+The index must be signed with the trusted minisign key, unless --insecure is provided.`,
+    Run: func(cmd *cobra.Command, _ []string) {
+        if updateJobs < 1 {
+            h.Printc("--jobs must be at least 1", h.ERROR, false)
+            _ = cmd.Usage(); os.Exit(h.USAGE_EXIT_CODE)
+        }
+
+        if err := getUpdates(updateJobs, wantsInsecure); err != nil {
+            h.Printc(err.Error(), h.ERROR, false)
+            os.Exit(h.ERROR_EXIT_CODE)
+        }
     },
 }
 
 func init() {
     rootCmd.AddCommand(updateCmd)
 
-    // Here you will define your flags and configuration settings.
-
-    // Cobra supports Persistent Flags which will work for this command
-    // and all subcommands, e.g.:
-    // updateCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-    // Cobra supports local flags which will only run when this command
-    // is called directly, e.g.:
-    // updateCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
-}
-
-// getUpdates checks for updates to the packages list.
-func getUpdates() {
-    success := false
-    url := PKGS_REPO + "/raw/refs/heads/master/packages-list.json"
-    tmpFilePath := filepath.Join(ConfigDir, "packages-list.json.tmp")
-    filePath := filepath.Join(ConfigDir, "packages-list.json")
-    // Download packages-list.json
-    lo.TryCatch(func() error { // try
-        request, _ := http.NewRequest("GET", url, nil)
-        request.Header.Set("User-Agent", UserAgent)
-        resp, respErr := http.DefaultClient.Do(request)
-        if respErr != nil {
-            h.Printc("Request failed!", h.ERROR, false)
-            return fmt.Errorf("Request failed: %w", respErr)
-        }
-        //nolint:errcheck
-        defer resp.Body.Close()
+    updateCmd.Flags().IntVarP(&updateJobs, "jobs", "j", DEFAULT_JOBS, "Number of manifests to download concurrently")
+    updateCmd.Flags().BoolVar(&wantsInsecure, "insecure", false, "Use the package index without verifying its signature")
+}
+
+// indexDir returns the directory of the current package index.
+func indexDir() string { return filepath.Join(DataDir, INDEX_NAME) }
 
-        file, _ := os.OpenFile(tmpFilePath, os.O_CREATE|os.O_WRONLY, 0644)
-        //nolint:errcheck
-        defer file.Close()
+// getUpdates downloads the packages list and every package manifest into a staging directory,
+// validates them, and atomically swaps the staging directory in as the current package index.
+// Unless `insecure` is set, the index must be signed with the trusted public key.
+func getUpdates(jobs int, insecure bool) error {
+    verifier, verifierErr := newIndexVerifier(insecure)
+    if verifierErr != nil { return verifierErr }
 
-        bar := progressbar.DefaultBytes(resp.ContentLength, "Updating packages list...")
-        //nolint:errcheck
-        io.Copy(io.MultiWriter(file, bar), resp.Body)
+    if err := os.MkdirAll(DataDir, 0755); err != nil {
+        return fmt.Errorf("Unable to create data dir: %w", err)
+    }
+
+    removeStaleIndexes()
+
+    stagingDir, stagingErr := os.MkdirTemp(DataDir, "." + INDEX_NAME + "-")
+    if stagingErr != nil { return fmt.Errorf("Unable to create staging dir: %w", stagingErr) }
+    if err := os.Chmod(stagingDir, 0755); err != nil { return fmt.Errorf("Unable to create staging dir: %w", err) }
+
+    swapped := false
+    defer func() {
+        if !swapped { _ = os.RemoveAll(stagingDir) }
+    }()
 
+    index := &indexSync {
+        dir: stagingDir, previous: readValidators(), current: map[string]validator {}, verifier: verifier,
+    }
+
+    pkgs, failures, syncErr := index.syncBundle()
+    if errors.Is(syncErr, errNoBundle) {
+        h.Printc(syncErr.Error() + ", downloading every manifest instead", h.INFO, false)
+        pkgs, failures, syncErr = index.syncFiles(jobs)
+    }
+    if errors.Is(syncErr, errUpToDate) {
+        h.Printc("Package index is already up to date!", h.INFO, true)
         return nil
-    }, func() { // catch
-        h.Printc("Unable to update packages list!", h.ERROR, false)
-        os.Exit(h.ERROR_EXIT_CODE)
-    })
-
-    // Compare the hashes of the downloaded file and the existing file
-    tmpListHash := getSha256Hash(tmpFilePath)
-    listHash := getSha256Hash(filePath)
-
-    if tmpListHash != listHash {
-        // Attempt to move the downloaded file to the existing file
-        if err := os.Rename(tmpFilePath, filePath); err != nil {
-            h.Printc(err.Error(), h.ERROR, true)
-        } else {
-            h.Printc("Packages list was sucessfully updated!", h.INFO, true)
-            success = true
-        }
-    } else { h.Printc("Packages list is already up to date!", h.INFO, true) }
+    } else if syncErr != nil {
+        return syncErr
+    }
 
-    if success {
-        data, _ := os.ReadFile(filePath)
+    if err := swapIndex(stagingDir); err != nil { return err }
+    swapped = true
 
-        pkgs := []string {}
-        if err := json.Unmarshal(data, &pkgs); err != nil {
-            h.Printc("Failed to unmarshal packages list!", h.ERROR, false)
-            os.Exit(h.ERROR_EXIT_CODE)
+    // The validators only describe the new index once it's in place.
+    if err := writeValidators(index.current); err != nil { h.Printc(err.Error(), h.WARNING, false) }
+
+    // The packages whose manifest was kept from the previous index are still available.
+    missing := lo.CountBy(failures, func(failure manifestFailure) bool { return !failure.kept })
+    msg := fmt.Sprintf("Package index was successfully updated! (%d packages, %d unchanged files)",
+        len(pkgs) - missing, index.unmodified)
+    h.Printc(msg, h.INFO, true)
+
+    reportFailures(failures)
+
+    return nil
+}
+
+// syncFiles downloads the packages list, then the manifest of every package, one file at a time.
+// When signatures are checked, every file must match its digest in the signed `SUMS_NAME`.
+func (s *indexSync) syncFiles(jobs int) ([]string, []manifestFailure, error) {
+    if s.verifier.enabled() {
+        sumsUrl := IndexUrl + "/" + SUMS_NAME
+        sums := bytes.Buffer {}
+        if _, err := download(sumsUrl, &sums, "", nil); err != nil {
+            return nil, nil, fmt.Errorf("%w: unable to download %s: %w", errUntrusted, SUMS_NAME, err)
         }
+        if err := s.verifier.verify(sumsUrl, sums.Bytes()); err != nil { return nil, nil, err }
+        s.sums = parseSums(sums.Bytes())
+    }
 
-        if err := getPkgManifests(pkgs); err != nil {
-            h.Printc("Failed to download package manifests!", h.ERROR, false)
-            os.Exit(h.ERROR_EXIT_CODE)
+    listUrl := IndexUrl + "/" + PKGS_LIST_NAME
+    if err := s.fetch(listUrl, PKGS_LIST_NAME, "Updating packages list..."); err != nil {
+        return nil, nil, fmt.Errorf("Unable to update packages list: %w", err)
+    }
+    if s.sums != nil {
+        if err := checkSum(s.sums, PKGS_LIST_NAME, filepath.Join(s.dir, PKGS_LIST_NAME)); err != nil {
+            return nil, nil, err
         }
     }
+
+    pkgs, err := readPkgsList(s.dir)
+    if err != nil { return nil, nil, err }
+
+    return pkgs, getPkgManifests(s, pkgs, jobs), nil
 }
 
-// getPkgManifests retrieves the manifests for the given packages.
-func getPkgManifests(pkgs []string) error {
-    downloadError := error (nil)
+// readPkgsList reads the packages list of the package index in the given directory.
+func readPkgsList(dir string) ([]string, error) {
+    content, readErr := os.ReadFile(filepath.Join(dir, PKGS_LIST_NAME))
+    if readErr != nil { return nil, fmt.Errorf("Failed to read packages list: %w", readErr) }
 
-    h.Printc("Downloading package manifests...", h.INFO, false)
+    pkgs := []string {}
+    if err := json.Unmarshal(content, &pkgs); err != nil {
+        return nil, fmt.Errorf("Failed to parse packages list: %w", err)
+    }
     for _, pkg := range pkgs {
-        if downloadError != nil { break }
-
-        url := PKGS_REPO + fmt.Sprintf("/raw/refs/heads/master/manifests/%s.json", pkg)
-        baseName := strings.Split(url, "/")[-0]
-        filePath := filepath.Join(DataDir, baseName)
-
-        lo.TryCatch(func() error { // try
-            request, _ := http.NewRequest("GET", url, nil)
-            resp, respErr := http.DefaultClient.Do(request)
-            if respErr != nil {
-                h.Printc("Request failed!", h.ERROR, false)
-                downloadError = fmt.Errorf("Request failed: %w", respErr)
-                return downloadError
-            }
-            //nolint:errcheck
-            defer resp.Body.Close()
+        if !manifest.ValidName(pkg) { return nil, fmt.Errorf("Invalid package name in packages list: %q", pkg) }
+    }
+
+    return pkgs, nil
+}
+
+// getPkgManifests downloads and validates the manifests of the given packages into the staging
+// directory, using `jobs` concurrent workers. The manifests that fail keep their version from
+// the current index, when there's one, and are returned sorted by package name.
+func getPkgManifests(index *indexSync, pkgs []string, jobs int) []manifestFailure {
+    failures := []manifestFailure {}
+    failuresMutex := sync.Mutex {}
 
-            file, _ := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY, 0644)
-            //nolint:errcheck
-            defer file.Close()
+    queue := make(chan string)
+    wg := sync.WaitGroup {}
+    bar := progressbar.Default(int64(len(pkgs)), "Downloading package manifests...")
 
-            bar := progressbar.DefaultBytes(resp.ContentLength, pkg)
+    for range min(jobs, max(len(pkgs), 1)) {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
 
-            if _, err := io.Copy(io.MultiWriter(file, bar), resp.Body); err != nil {
-                msg := fmt.Sprint("Failed to download package manifest for " + pkg)
-                h.Printc(msg, h.ERROR, false)
-                downloadError = fmt.Errorf("%s: %w", msg, err)
-                return downloadError
+            for pkg := range queue {
+                if err := index.fetchManifest(pkg); err != nil {
+                    failure := manifestFailure { pkg: pkg, err: err, kept: index.keepManifest(pkg) }
+                    failuresMutex.Lock()
+                    failures = append(failures, failure)
+                    failuresMutex.Unlock()
+                }
+                _ = bar.Add(1)
             }
+        }()
+    }
+
+    for _, pkg := range pkgs { queue <- pkg }
+    close(queue)
+    wg.Wait()
+    _ = bar.Finish()
+
+    slices.SortFunc(failures, func(a manifestFailure, b manifestFailure) int { return strings.Compare(a.pkg, b.pkg) })
+    return failures
+}
+
+// fetchManifest downloads and validates the manifest of the given package.
+// Network and server errors are retried by the HTTP client, and interrupted transfers by `downloadFile`.
+func (s *indexSync) fetchManifest(pkg string) error {
+    url, fileName := manifestUrl(pkg), pkg + ".yaml"
+
+    fetchErr := s.fetch(url, fileName, "")
+    if fetchErr == nil && s.sums != nil {
+        fetchErr = checkSum(s.sums, "manifests/" + fileName, filepath.Join(s.dir, fileName))
+    }
+    if fetchErr != nil {
+        _ = os.Remove(filepath.Join(s.dir, fileName))
+        s.mutex.Lock()
+        delete(s.current, url)
+        s.mutex.Unlock()
+        return fetchErr
+    }
 
-            return nil
-        }, func() { // catch
-            h.Printc("Unknown error occurred!", h.ERROR, false)
-            downloadError = fmt.Errorf("Unknown error occurred")
-        })
+    if err := validateManifest(pkg, filepath.Join(s.dir, fileName)); err != nil {
+        _ = os.Remove(filepath.Join(s.dir, fileName))
+        s.mutex.Lock()
+        delete(s.current, url)
+        s.mutex.Unlock()
+        return err
     }
 
-    return downloadError
+    return nil
+}
+
+// keepManifest copies the manifest of the given package from the current index to the staging
+// directory, and reports whether there was one.
+func (s *indexSync) keepManifest(pkg string) bool {
+    url, fileName := manifestUrl(pkg), pkg + ".yaml"
+
+    previousPath := filepath.Join(indexDir(), fileName)
+    if !fileExists(previousPath) { return false }
+    if err := copyFile(previousPath, filepath.Join(s.dir, fileName)); err != nil { return false }
+
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+    if prev, ok := s.previous[url]; ok { s.current[url] = prev }
+
+    return true
+}
+
+// manifestUrl returns the URL of the manifest of the given package.
+func manifestUrl(pkg string) string { return IndexUrl + "/manifests/" + pkg + ".yaml" }
+
+// reportFailures prints every manifest that could not be updated.
+func reportFailures(failures []manifestFailure) {
+    if len(failures) == 0 { return }
+
+    msg := fmt.Sprintf("%d package manifest(s) could not be updated:", len(failures))
+    h.Printc(msg, h.WARNING, false)
+
+    for _, failure := range failures {
+        outcome := lo.Ternary(failure.kept, "keeping the previous manifest", "not available")
+        fmt.Printf("    %s (%s): %s\n", failure.pkg, outcome, failure.err)
+    }
+}
+
+// validateManifest checks that the given manifest is valid, and declares the package it's named after.
+func validateManifest(pkg string, filePath string) error {
+    data, err := manifest.Load(filePath)
+    switch {
+    case err != nil:
+        return err
+    case data.Name != pkg:
+        return fmt.Errorf("manifest declares the package %q", data.Name)
+    default:
+        return nil
+    }
+}
+
+// fetch downloads the given URL to the given file of the staging directory. When the file is
+// part of the current index, a conditional request is made and the current file is reused
+// if it was not modified.
+func (s *indexSync) fetch(url string, fileName string, desc string) error {
+    previousPath := filepath.Join(indexDir(), fileName)
+    headers := map[string]string {}
+    // `previous` is never modified, so it's safe to read without the mutex.
+    if prev, ok := s.previous[url]; ok && fileExists(previousPath) {
+        headers["If-None-Match"] = prev.ETag
+        headers["If-Modified-Since"] = prev.LastModified
+    }
+
+    filePath := filepath.Join(s.dir, fileName)
+    resp, downloadErr := downloadFile(url, filePath, desc, headers)
+    if downloadErr != nil { return downloadErr }
+
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    if resp.StatusCode == http.StatusNotModified {
+        s.unmodified++
+        s.current[url] = s.previous[url]
+        return copyFile(previousPath, filePath)
+    }
+
+    s.current[url] = validator { ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified") }
+    return nil
+}
+
+// readValidators reads the validators of the current index. An empty map is returned when
+// they're missing or unreadable, so that every file is downloaded again.
+func readValidators() map[string]validator {
+    result := map[string]validator {}
+
+    content, err := os.ReadFile(filepath.Join(DataDir, VALIDATORS_NAME))
+    if err != nil { return result }
+    if err := json.Unmarshal(content, &result); err != nil { return map[string]validator {} }
+
+    return result
+}
+
+// writeValidators atomically replaces the validators of the current index.
+func writeValidators(validators map[string]validator) error {
+    content, err := json.MarshalIndent(validators, "", "    ")
+    if err != nil { return fmt.Errorf("Failed to encode validators: %w", err) }
+
+    filePath := filepath.Join(DataDir, VALIDATORS_NAME)
+    if err := os.WriteFile(filePath + ".tmp", content, 0644); err != nil {
+        return fmt.Errorf("Failed to write validators: %w", err)
+    }
+    if err := os.Rename(filePath + ".tmp", filePath); err != nil {
+        _ = os.Remove(filePath + ".tmp")
+        return fmt.Errorf("Failed to write validators: %w", err)
+    }
+
+    return nil
+}
+
+// fileExists reports whether the given regular file exists.
+func fileExists(filePath string) bool {
+    info, err := os.Stat(filePath)
+    return err == nil && info.Mode().IsRegular()
+}
+
+// copyFile copies the content of `src` to `dst`.
+func copyFile(src string, dst string) error {
+    content, err := os.ReadFile(src)
+    if err != nil { return fmt.Errorf("Failed to copy %s: %w", src, err) }
+    if err := os.WriteFile(dst, content, 0644); err != nil { return fmt.Errorf("Failed to copy %s: %w", src, err) }
+    return nil
+}
+
+// removeStaleIndexes removes the staging directories left over by interrupted updates, except
+// the current index. Like in `leftoverTmpFiles`, the ones modified less than `TMP_MIN_AGE` ago
+// are left alone, as they may belong to an update that is still running.
+func removeStaleIndexes() {
+    current, _ := os.Readlink(indexDir())
+
+    matches, _ := filepath.Glob(filepath.Join(DataDir, "." + INDEX_NAME + "-*"))
+    for _, match := range matches {
+        info, err := os.Lstat(match)
+        if err != nil || !info.IsDir() || filepath.Base(match) == filepath.Base(current) { continue }
+        if time.Since(info.ModTime()) < TMP_MIN_AGE { continue }
+
+        _ = os.RemoveAll(match)
+    }
+}
+
+// swapIndex atomically points the index symlink to the given directory,
+// and removes the directory of the previous index.
+func swapIndex(dir string) error {
+    previous, _ := os.Readlink(indexDir())
+
+    // Renaming a symlink over another one is atomic, unlike replacing a directory.
+    tmpLink := indexDir() + "." + strconv.FormatInt(time.Now().UnixNano(), 10) + ".tmp"
+    if err := os.Symlink(filepath.Base(dir), tmpLink); err != nil {
+        return fmt.Errorf("Failed to swap package index: %w", err)
+    }
+    if err := os.Rename(tmpLink, indexDir()); err != nil {
+        _ = os.Remove(tmpLink)
+        return fmt.Errorf("Failed to swap package index: %w", err)
+    }
+
+    if previous != "" && previous != filepath.Base(dir) {
+        _ = os.RemoveAll(filepath.Join(DataDir, filepath.Base(previous)))
+    }
+
+    return nil
+}
+
+// download writes the content at the given URL to `w`, showing a progress bar with the given
+// description unless it's empty. Empty headers are not sent, and bodies larger than
+// `maxDownloadSize` are refused. Nothing is written when the
+// response is `304 Not Modified`, which callers can check on the returned response.
+// Other unexpected statuses are returned as an `httpclient.StatusError`.
+func download(url string, w io.Writer, desc string, headers map[string]string) (*http.Response, error) {
+    request, reqErr := http.NewRequest("GET", url, nil)
+    if reqErr != nil { return nil, fmt.Errorf("Invalid request: %w", reqErr) }
+    for key, value := range headers {
+        if value != "" { request.Header.Set(key, value) }
+    }
+
+    resp, respErr := httpClient().Do(request)
+    if respErr != nil { return nil, fmt.Errorf("Request failed: %w", respErr) }
+    //nolint:errcheck
+    defer resp.Body.Close()
+
+    if err := httpclient.CheckStatus(resp, http.StatusNotModified); err != nil { return resp, err }
+    if resp.StatusCode == http.StatusNotModified { return resp, nil }
+
+    if desc != "" { w = io.MultiWriter(w, progressbar.DefaultBytes(resp.ContentLength, desc)) }
+    size, copyErr := io.Copy(w, io.LimitReader(bodyReader { resp.Body }, maxDownloadSize + 1))
+    if copyErr != nil { return resp, fmt.Errorf("Failed to download %s: %w", url, copyErr) }
+    if size > maxDownloadSize {
+        return resp, fmt.Errorf("Failed to download %s: it's larger than %s", url, formatSize(maxDownloadSize))
+    }
+
+    return resp, nil
+}
+
+// downloadFile writes the content at the given URL to a file, like `download`. The HTTP client
+// only retries requests that failed before the response headers, so transfers interrupted
+// afterwards are attempted again here, up to `MAX_ATTEMPTS` times.
+func downloadFile(url string, filePath string, desc string, headers map[string]string) (*http.Response, error) {
+    resp, err := (*http.Response) (nil), error (nil)
+
+    for attempt := range MAX_ATTEMPTS {
+        if attempt > 0 { time.Sleep(retryDelay << (attempt - 1)) }
+
+        file, fileErr := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+        if fileErr != nil { return nil, fmt.Errorf("Failed to write %s: %w", filepath.Base(filePath), fileErr) }
+
+        resp, err = download(url, file, desc, headers)
+        err = errors.Join(err, file.Close())
+        if !errors.Is(err, errInterrupted) { break }
+    }
+
+    return resp, err
+}
+
+// bodyReader wraps the errors of a response body with `errInterrupted`, to tell them apart
+// from the errors of the writer it's copied to.
+type bodyReader struct {
+    io.Reader
+}
+
+func (r bodyReader) Read(p []byte) (int, error) {
+    n, err := r.Reader.Read(p)
+    if err != nil && err != io.EOF { err = fmt.Errorf("%w: %w", errInterrupted, err) }
+    return n, err
 }
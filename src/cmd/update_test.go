@@ -0,0 +1,715 @@
+package cmd
+
+import (
+    "archive/tar"
+    "bytes"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "path/filepath"
+    "slices"
+    "strconv"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    "aead.dev/minisign"
+    "github.com/goccy/go-json"
+    "github.com/klauspost/compress/zstd"
+)
+
+// bundleEntry is a file of a test index bundle.
+type bundleEntry struct {
+    name string
+    content []byte
+    // Target of the entry when it's a symlink
+    link string
+}
+
+// testIndex is a package index served over HTTP, signed with a key generated for the test.
+type testIndex struct {
+    server *testServer
+    key minisign.PrivateKey
+    // Modification time of every file, so that conditional requests can be answered
+    modTime time.Time
+    mutex sync.Mutex
+    // Content of the served files, by path relative to `IndexUrl`
+    files map[string][]byte
+    // Statuses answered in place of the content of a file, by path relative to `IndexUrl`
+    statuses map[string]int
+    // Number of transfers of a file to interrupt halfway, by path relative to `IndexUrl`
+    interruptions map[string]int
+    // Headers of the last request for a file, by path relative to `IndexUrl`
+    headers map[string]http.Header
+}
+
+// newTestIndex serves a signed package index with the given manifests, by package name, and points
+// `IndexUrl`, `IndexPublicKey` and the data directories to it for the duration of the test.
+func newTestIndex(t *testing.T, manifests map[string]string) *testIndex {
+    t.Helper()
+    useTestDirs(t)
+
+    publicKey, privateKey, err := minisign.GenerateKey(rand.Reader)
+    if err != nil { t.Fatal(err) }
+
+    index := &testIndex {
+        key: privateKey, modTime: time.Now().Add(-time.Hour).Truncate(time.Second),
+        files: map[string][]byte {}, statuses: map[string]int {}, interruptions: map[string]int {},
+        headers: map[string]http.Header {},
+    }
+    index.server = newTestServer(t, http.HandlerFunc(index.serve))
+
+    indexUrl, publicKeyText, configFile := IndexUrl, IndexPublicKey, ConfigFile
+    IndexUrl, IndexPublicKey = index.server.URL, publicKey.String()
+    ConfigFile = filepath.Join(t.TempDir(), "config.json")
+    t.Cleanup(func() { IndexUrl, IndexPublicKey, ConfigFile = indexUrl, publicKeyText, configFile })
+
+    index.publish(t, manifests)
+    return index
+}
+
+// serve answers the requests for the files of the index.
+func (i *testIndex) serve(w http.ResponseWriter, r *http.Request) {
+    name := strings.TrimPrefix(r.URL.Path, "/")
+
+    i.mutex.Lock()
+    i.headers[name] = r.Header.Clone()
+    status, hasStatus := i.statuses[name]
+    content, ok := i.files[name]
+    interrupted := i.interruptions[name] > 0
+    if interrupted { i.interruptions[name]-- }
+    i.mutex.Unlock()
+
+    switch {
+    case interrupted:
+        // The connection is closed once half of the announced content was sent.
+        w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+        _, _ = w.Write(content[:len(content) / 2])
+    case hasStatus:
+        w.WriteHeader(status)
+    case !ok:
+        http.NotFound(w, r)
+    default:
+        sum := sha256.Sum256(content)
+        w.Header().Set("ETag", `"` + hex.EncodeToString(sum[:8]) + `"`)
+        http.ServeContent(w, r, name, i.modTime, bytes.NewReader(content))
+    }
+}
+
+// publish replaces the served files with an index of the given manifests, published both
+// as a bundle and as separate files, with signed digests.
+func (i *testIndex) publish(t *testing.T, manifests map[string]string) {
+    t.Helper()
+
+    names := []string {}
+    for name := range manifests { names = append(names, name) }
+    slices.Sort(names)
+    list, err := json.Marshal(names)
+    if err != nil { t.Fatal(err) }
+
+    entries := []bundleEntry { { name: PKGS_LIST_NAME, content: list } }
+    for _, name := range names {
+        entries = append(entries, bundleEntry { name: "manifests/" + name + ".yaml", content: []byte(manifests[name]) })
+    }
+
+    i.mutex.Lock()
+    defer i.mutex.Unlock()
+
+    i.files = map[string][]byte {}
+    sums := strings.Builder {}
+    for _, entry := range entries {
+        i.files[entry.name] = entry.content
+        fmt.Fprintf(&sums, "%s  %s\n", sha256Hex(entry.content), entry.name)
+    }
+    i.files[SUMS_NAME] = []byte(sums.String())
+    i.files[SUMS_NAME + SIGNATURE_EXT] = minisign.Sign(i.key, i.files[SUMS_NAME])
+
+    i.setBundle(t, entries)
+}
+
+// setBundle replaces the served bundle with one of the given entries, with a signed digest.
+// The mutex must be held.
+func (i *testIndex) setBundle(t *testing.T, entries []bundleEntry) {
+    t.Helper()
+
+    bundle := makeBundle(t, entries)
+    digest := []byte(sha256Hex(bundle) + "  " + BUNDLE_NAME + "\n")
+    i.files[BUNDLE_NAME] = bundle
+    i.files[BUNDLE_NAME + DIGEST_EXT] = digest
+    i.files[BUNDLE_NAME + DIGEST_EXT + SIGNATURE_EXT] = minisign.Sign(i.key, digest)
+}
+
+// set replaces the content of a served file, without updating the digests that list it.
+func (i *testIndex) set(name string, content []byte) {
+    i.mutex.Lock()
+    defer i.mutex.Unlock()
+    i.files[name] = content
+}
+
+// answer makes the server answer the given status for a file, or serve it again when it's 0.
+func (i *testIndex) answer(name string, status int) {
+    i.mutex.Lock()
+    defer i.mutex.Unlock()
+    if status == 0 { delete(i.statuses, name) } else { i.statuses[name] = status }
+}
+
+// conditions returns the `If-None-Match` and `If-Modified-Since` headers of the last request for a file.
+func (i *testIndex) conditions(name string) (string, string) {
+    i.mutex.Lock()
+    defer i.mutex.Unlock()
+    return i.headers[name].Get("If-None-Match"), i.headers[name].Get("If-Modified-Since")
+}
+
+// etag returns the `ETag` served for a file.
+func (i *testIndex) etag(name string) string {
+    i.mutex.Lock()
+    defer i.mutex.Unlock()
+    sum := sha256.Sum256(i.files[name])
+    return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// interrupt makes the server interrupt the next transfers of a file.
+func (i *testIndex) interrupt(name string, times int) {
+    i.mutex.Lock()
+    defer i.mutex.Unlock()
+    i.interruptions[name] = times
+}
+
+// makeBundle returns a zstd-compressed tarball of the given entries.
+func makeBundle(t *testing.T, entries []bundleEntry) []byte {
+    t.Helper()
+
+    tarball := bytes.Buffer {}
+    archive := tar.NewWriter(&tarball)
+    for _, entry := range entries {
+        header := &tar.Header { Name: entry.name, Mode: 0644, Size: int64(len(entry.content)), Typeflag: tar.TypeReg }
+        if entry.link != "" { header = &tar.Header { Name: entry.name, Mode: 0777, Linkname: entry.link, Typeflag: tar.TypeSymlink } }
+        if err := archive.WriteHeader(header); err != nil { t.Fatal(err) }
+        if _, err := archive.Write(entry.content); err != nil { t.Fatal(err) }
+    }
+    if err := archive.Close(); err != nil { t.Fatal(err) }
+
+    result := bytes.Buffer {}
+    encoder, err := zstd.NewWriter(&result)
+    if err != nil { t.Fatal(err) }
+    if _, err := encoder.Write(tarball.Bytes()); err != nil { t.Fatal(err) }
+    if err := encoder.Close(); err != nil { t.Fatal(err) }
+
+    return result.Bytes()
+}
+
+// sha256Hex returns the hex-encoded SHA-256 digest of the content.
+func sha256Hex(content []byte) string {
+    sum := sha256.Sum256(content)
+    return hex.EncodeToString(sum[:])
+}
+
+// indexFiles returns the names of the files of the current package index, sorted.
+func indexFiles(t *testing.T) []string {
+    t.Helper()
+
+    entries, err := os.ReadDir(indexDir())
+    if errors.Is(err, os.ErrNotExist) { return nil }
+    if err != nil { t.Fatal(err) }
+
+    result := []string {}
+    for _, entry := range entries { result = append(result, entry.Name()) }
+    return result
+}
+
+// testManifests returns valid manifests for the given packages.
+func testManifests(version string, pkgs ...string) map[string]string {
+    result := map[string]string {}
+    for _, pkg := range pkgs { result[pkg] = testManifest(pkg, version, "") }
+    return result
+}
+
+func TestUpdateVerifiesBundle(t *testing.T) {
+    index := newTestIndex(t, testManifests("1.0.0", "app", "tool"))
+
+    if err := getUpdates(1, false); err != nil { t.Fatalf("getUpdates() failed: %v", err) }
+    if want := []string { "app.yaml", PKGS_LIST_NAME, "tool.yaml" }; !slices.Equal(indexFiles(t), want) {
+        t.Errorf("getUpdates() installed %v, want %v", indexFiles(t), want)
+    }
+
+    want := []string { "/" + BUNDLE_NAME, "/" + BUNDLE_NAME + DIGEST_EXT, "/" + BUNDLE_NAME + DIGEST_EXT + SIGNATURE_EXT }
+    if !slices.Equal(index.server.requested(), want) {
+        t.Errorf("getUpdates() requested %v, want %v", index.server.requested(), want)
+    }
+}
+
+func TestUpdateRefusesUntrustedBundle(t *testing.T) {
+    signatureName := BUNDLE_NAME + DIGEST_EXT + SIGNATURE_EXT
+
+    tests := []struct {
+        name string
+        edit func(index *testIndex)
+        untrusted bool
+    }{
+        { "missing signature", func(index *testIndex) { index.answer(signatureName, http.StatusNotFound) }, true },
+        {
+            "signature of another key",
+            func(index *testIndex) {
+                _, other, _ := minisign.GenerateKey(rand.Reader)
+                index.set(signatureName, minisign.Sign(other, index.files[BUNDLE_NAME + DIGEST_EXT]))
+            },
+            true,
+        },
+        { "garbage signature", func(index *testIndex) { index.set(signatureName, []byte("not a signature")) }, true },
+        {
+            "bundle not matching its digest",
+            func(index *testIndex) { index.set(BUNDLE_NAME, makeBundle(t, []bundleEntry { { name: PKGS_LIST_NAME, content: []byte("[]") } })) },
+            false,
+        },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            index := newTestIndex(t, testManifests("1.0.0", "app"))
+            test.edit(index)
+
+            err := getUpdates(1, false)
+            switch {
+            case err == nil:
+                t.Fatal("getUpdates() accepted the package index")
+            case test.untrusted && !errors.Is(err, errUntrusted):
+                t.Errorf("getUpdates() = %v, want %v", err, errUntrusted)
+            }
+
+            // The per-file mode is only a fallback for a missing bundle, not for an untrusted one.
+            if requested := index.server.requested(); slices.Contains(requested, "/" + PKGS_LIST_NAME) ||
+                slices.Contains(requested, "/" + SUMS_NAME) {
+                t.Errorf("getUpdates() fell back to downloading every file: %v", requested)
+            }
+            if files := indexFiles(t); len(files) != 0 { t.Errorf("getUpdates() installed %v", files) }
+        })
+    }
+}
+
+func TestUpdateVerifiesFiles(t *testing.T) {
+    index := newTestIndex(t, testManifests("1.0.0", "app", "tool"))
+    index.answer(BUNDLE_NAME, http.StatusNotFound)
+
+    if err := getUpdates(2, false); err != nil { t.Fatalf("getUpdates() failed: %v", err) }
+    if want := []string { "app.yaml", PKGS_LIST_NAME, "tool.yaml" }; !slices.Equal(indexFiles(t), want) {
+        t.Errorf("getUpdates() installed %v, want %v", indexFiles(t), want)
+    }
+    if requested := index.server.requested(); !slices.Contains(requested, "/" + SUMS_NAME + SIGNATURE_EXT) {
+        t.Errorf("getUpdates() requested %v, want the signature of %s", requested, SUMS_NAME)
+    }
+}
+
+func TestUpdateRefusesUntrustedFiles(t *testing.T) {
+    tests := []struct {
+        name string
+        edit func(index *testIndex)
+        // Expected files of the index, nil when the update must fail
+        want []string
+    }{
+        { "missing signature", func(index *testIndex) { index.answer(SUMS_NAME + SIGNATURE_EXT, http.StatusNotFound) }, nil },
+        { "missing digests", func(index *testIndex) { index.answer(SUMS_NAME, http.StatusNotFound) }, nil },
+        {
+            "unsigned digests",
+            func(index *testIndex) { index.set(SUMS_NAME, append(index.files[SUMS_NAME], "0000  manifests/other.yaml\n"...)) },
+            nil,
+        },
+        { "packages list not matching its digest", func(index *testIndex) { index.set(PKGS_LIST_NAME, []byte(`["app"]`)) }, nil },
+        {
+            "manifest not matching its digest",
+            func(index *testIndex) { index.set("manifests/tool.yaml", []byte(testManifest("tool", "6.6.6", ""))) },
+            []string { "app.yaml", PKGS_LIST_NAME },
+        },
+        {
+            "manifest missing from the digests",
+            func(index *testIndex) {
+                sums := strings.ReplaceAll(string(index.files[SUMS_NAME]), "manifests/tool.yaml", "manifests/other.yaml")
+                index.set(SUMS_NAME, []byte(sums))
+                index.set(SUMS_NAME + SIGNATURE_EXT, minisign.Sign(index.key, []byte(sums)))
+            },
+            []string { "app.yaml", PKGS_LIST_NAME },
+        },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            index := newTestIndex(t, testManifests("1.0.0", "app", "tool"))
+            index.answer(BUNDLE_NAME, http.StatusNotFound)
+            test.edit(index)
+
+            err := getUpdates(1, false)
+            switch {
+            case test.want == nil && !errors.Is(err, errUntrusted):
+                t.Errorf("getUpdates() = %v, want %v", err, errUntrusted)
+            case test.want != nil && err != nil:
+                t.Errorf("getUpdates() failed: %v", err)
+            case !slices.Equal(indexFiles(t), test.want):
+                t.Errorf("getUpdates() installed %v, want %v", indexFiles(t), test.want)
+            }
+        })
+    }
+}
+
+func TestUpdateInsecure(t *testing.T) {
+    for _, bundle := range []bool { true, false } {
+        t.Run(fmt.Sprintf("bundle %v", bundle), func(t *testing.T) {
+            index := newTestIndex(t, testManifests("1.0.0", "app"))
+            IndexPublicKey = ""
+            if !bundle { index.answer(BUNDLE_NAME, http.StatusNotFound) }
+            // Nothing is signed, and the manifest no longer matches the digests of the separate files.
+            for _, name := range []string { SUMS_NAME, SUMS_NAME + SIGNATURE_EXT, BUNDLE_NAME + DIGEST_EXT + SIGNATURE_EXT } {
+                index.answer(name, http.StatusNotFound)
+            }
+            index.set("manifests/app.yaml", []byte(testManifest("app", "2.0.0", "")))
+
+            if err := getUpdates(1, false); !errors.Is(err, errUntrusted) {
+                t.Errorf("getUpdates() without a key = %v, want %v", err, errUntrusted)
+            }
+            if err := getUpdates(1, true); err != nil { t.Fatalf("getUpdates(insecure) failed: %v", err) }
+            if want := []string { "app.yaml", PKGS_LIST_NAME }; !slices.Equal(indexFiles(t), want) {
+                t.Errorf("getUpdates(insecure) installed %v, want %v", indexFiles(t), want)
+            }
+
+            for _, name := range []string { SUMS_NAME, SUMS_NAME + SIGNATURE_EXT, BUNDLE_NAME + DIGEST_EXT + SIGNATURE_EXT } {
+                if slices.Contains(index.server.requested(), "/" + name) { t.Errorf("getUpdates(insecure) requested %s", name) }
+            }
+        })
+    }
+}
+
+func TestUpdateRetriesInterruptedTransfers(t *testing.T) {
+    previous := retryDelay
+    retryDelay = 0
+    t.Cleanup(func() { retryDelay = previous })
+
+    index := newTestIndex(t, testManifests("1.0.0", "app", "tool", "other"))
+    index.answer(BUNDLE_NAME, http.StatusNotFound)
+    index.answer("manifests/other.yaml", http.StatusNotFound)
+    index.interrupt(PKGS_LIST_NAME, 1)
+    index.interrupt("manifests/app.yaml", MAX_ATTEMPTS - 1)
+    index.interrupt("manifests/tool.yaml", MAX_ATTEMPTS)
+
+    if err := getUpdates(2, false); err != nil { t.Fatalf("getUpdates() failed: %v", err) }
+    if want := []string { "app.yaml", PKGS_LIST_NAME }; !slices.Equal(indexFiles(t), want) {
+        t.Errorf("getUpdates() installed %v, want %v", indexFiles(t), want)
+    }
+
+    // Failed requests are left to the HTTP client, only interrupted transfers are attempted again.
+    want := map[string]int {
+        PKGS_LIST_NAME: 2, "manifests/app.yaml": MAX_ATTEMPTS, "manifests/tool.yaml": MAX_ATTEMPTS, "manifests/other.yaml": 1,
+    }
+    for name, count := range want {
+        got := 0
+        for _, requested := range index.server.requested() {
+            if requested == "/" + name { got++ }
+        }
+        if got != count { t.Errorf("getUpdates() requested %s %d times, want %d", name, got, count) }
+    }
+}
+
+// indexDirs returns the names of the index directories inside `DataDir`, sorted.
+func indexDirs(t *testing.T) []string {
+    t.Helper()
+
+    matches, err := filepath.Glob(filepath.Join(DataDir, "." + INDEX_NAME + "-*"))
+    if err != nil { t.Fatal(err) }
+
+    result := []string {}
+    for _, match := range matches { result = append(result, filepath.Base(match)) }
+    return result
+}
+
+func TestUpdateKeepsIndexOnFailure(t *testing.T) {
+    tests := []struct {
+        name string
+        // Makes the update of the index fail
+        edit func(index *testIndex)
+    }{
+        { "untrusted bundle", func(index *testIndex) { index.answer(BUNDLE_NAME + DIGEST_EXT + SIGNATURE_EXT, http.StatusNotFound) } },
+        {
+            "packages list unavailable",
+            func(index *testIndex) {
+                index.answer(BUNDLE_NAME, http.StatusNotFound)
+                index.answer(PKGS_LIST_NAME, http.StatusInternalServerError)
+            },
+        },
+        {
+            "interrupted bundle and packages list",
+            func(index *testIndex) {
+                index.interrupt(BUNDLE_NAME, 1)
+                index.interrupt(PKGS_LIST_NAME, MAX_ATTEMPTS)
+            },
+        },
+        {
+            "packages list not matching its digest",
+            func(index *testIndex) {
+                index.answer(BUNDLE_NAME, http.StatusNotFound)
+                index.set(PKGS_LIST_NAME, []byte(`["app","tool","new"]`))
+            },
+        },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            previous := retryDelay
+            retryDelay = 0
+            t.Cleanup(func() { retryDelay = previous })
+
+            index := newTestIndex(t, testManifests("1.0.0", "app", "tool"))
+            if err := getUpdates(1, false); err != nil { t.Fatalf("getUpdates() failed: %v", err) }
+            current, _ := os.Readlink(indexDir())
+            validators, _ := os.ReadFile(filepath.Join(DataDir, VALIDATORS_NAME))
+
+            index.publish(t, testManifests("2.0.0", "app", "tool", "new"))
+            test.edit(index)
+
+            if err := getUpdates(2, false); err == nil { t.Fatal("getUpdates() succeeded") }
+
+            if link, _ := os.Readlink(indexDir()); link != current {
+                t.Errorf("getUpdates() pointed the index to %s, want %s", link, current)
+            }
+            if want := []string { "app.yaml", PKGS_LIST_NAME, "tool.yaml" }; !slices.Equal(indexFiles(t), want) {
+                t.Errorf("getUpdates() left %v in the index, want %v", indexFiles(t), want)
+            }
+            if content, _ := os.ReadFile(filepath.Join(indexDir(), "app.yaml")); !strings.Contains(string(content), "1.0.0") {
+                t.Errorf("getUpdates() replaced the manifest of app with %q", content)
+            }
+            if content, _ := os.ReadFile(filepath.Join(DataDir, VALIDATORS_NAME)); !bytes.Equal(content, validators) {
+                t.Errorf("getUpdates() replaced the validators with %s", content)
+            }
+            // The staging directory of the failed update is removed.
+            if dirs := indexDirs(t); !slices.Equal(dirs, []string { filepath.Base(current) }) {
+                t.Errorf("getUpdates() left %v, want only %s", dirs, current)
+            }
+        })
+    }
+}
+
+func TestSwapIndex(t *testing.T) {
+    useTestDirs(t)
+
+    first, second := filepath.Join(DataDir, ".index-first"), filepath.Join(DataDir, ".index-second")
+    for _, dir := range []string { first, second } {
+        if err := os.MkdirAll(dir, 0755); err != nil { t.Fatal(err) }
+        if err := os.WriteFile(filepath.Join(dir, PKGS_LIST_NAME), []byte(filepath.Base(dir)), 0644); err != nil { t.Fatal(err) }
+    }
+
+    steps := []struct {
+        dir string
+        // Index directories expected once swapped in
+        want []string
+    }{
+        { first, []string { ".index-first", ".index-second" } },
+        { second, []string { ".index-second" } },
+        // Swapping in the current index keeps it.
+        { second, []string { ".index-second" } },
+    }
+
+    for _, step := range steps {
+        if err := swapIndex(step.dir); err != nil { t.Fatalf("swapIndex(%s) failed: %v", step.dir, err) }
+
+        if content, _ := os.ReadFile(filepath.Join(indexDir(), PKGS_LIST_NAME)); string(content) != filepath.Base(step.dir) {
+            t.Errorf("swapIndex(%s) points the index to %s", step.dir, content)
+        }
+        // The symlink is relative, so that `DataDir` can be moved.
+        if link, _ := os.Readlink(indexDir()); link != filepath.Base(step.dir) {
+            t.Errorf("swapIndex(%s) linked the index to %s", step.dir, link)
+        }
+        if dirs := indexDirs(t); !slices.Equal(dirs, step.want) {
+            t.Errorf("swapIndex(%s) left %v, want %v", step.dir, dirs, step.want)
+        }
+        if matches, _ := filepath.Glob(filepath.Join(DataDir, INDEX_NAME + ".*.tmp")); len(matches) > 0 {
+            t.Errorf("swapIndex(%s) left %v", step.dir, matches)
+        }
+    }
+}
+
+func TestRemoveStaleIndexes(t *testing.T) {
+    useTestDirs(t)
+    old := time.Now().Add(-2 * TMP_MIN_AGE)
+
+    for _, name := range []string { ".index-current", ".index-stale", ".index-running" } {
+        if err := os.MkdirAll(filepath.Join(DataDir, name), 0755); err != nil { t.Fatal(err) }
+    }
+    if err := os.Symlink(".index-current", indexDir()); err != nil { t.Fatal(err) }
+    for _, name := range []string { ".index-current", ".index-stale" } {
+        if err := os.Chtimes(filepath.Join(DataDir, name), old, old); err != nil { t.Fatal(err) }
+    }
+
+    removeStaleIndexes()
+
+    if want := []string { ".index-current", ".index-running" }; !slices.Equal(indexDirs(t), want) {
+        t.Errorf("removeStaleIndexes() left %v, want %v", indexDirs(t), want)
+    }
+}
+
+func TestDownloadSizeLimit(t *testing.T) {
+    previous := maxDownloadSize
+    maxDownloadSize = 1024
+    t.Cleanup(func() { maxDownloadSize = previous })
+
+    for _, size := range []int { 1024, 1025, 4096 } {
+        server := newPkgServer(t, make([]byte, size))
+        content := bytes.Buffer {}
+
+        _, err := download(server.URL + "/index.tar.zst", &content, "", nil)
+        switch {
+        case size <= 1024 && (err != nil || content.Len() != size):
+            t.Errorf("download(%d bytes) = %v after %d bytes", size, err, content.Len())
+        case size > 1024 && (err == nil || content.Len() > 1025):
+            t.Errorf("download(%d bytes) = %v after %d bytes, want it refused", size, err, content.Len())
+        }
+    }
+}
+
+func TestUpdateConditionalRequests(t *testing.T) {
+    index := newTestIndex(t, testManifests("1.0.0", "app", "tool"))
+    index.answer(BUNDLE_NAME, http.StatusNotFound)
+    lastModified := index.modTime.UTC().Format(http.TimeFormat)
+
+    if err := getUpdates(2, false); err != nil { t.Fatalf("getUpdates() failed: %v", err) }
+    for _, name := range []string { PKGS_LIST_NAME, "manifests/app.yaml" } {
+        if etag, since := index.conditions(name); etag != "" || since != "" {
+            t.Errorf("getUpdates() requested %s with If-None-Match %q and If-Modified-Since %q without an index", name, etag, since)
+        }
+    }
+
+    validators := readValidators()
+    if got := validators[manifestUrl("app")]; got.ETag != index.etag("manifests/app.yaml") || got.LastModified != lastModified {
+        t.Errorf("getUpdates() recorded %+v for app", got)
+    }
+
+    // Only tool changes, the other files are answered with `304 Not Modified`.
+    index.publish(t, map[string]string { "app": testManifest("app", "1.0.0", ""), "tool": testManifest("tool", "2.0.0", "") })
+    index.answer(BUNDLE_NAME, http.StatusNotFound)
+    previousEtag := validators[manifestUrl("tool")].ETag
+
+    if err := getUpdates(2, false); err != nil { t.Fatalf("getUpdates() failed: %v", err) }
+
+    tests := []struct {
+        name string
+        etag string
+    }{
+        { PKGS_LIST_NAME, index.etag(PKGS_LIST_NAME) },
+        { "manifests/app.yaml", index.etag("manifests/app.yaml") },
+        { "manifests/tool.yaml", previousEtag },
+    }
+    for _, test := range tests {
+        if etag, since := index.conditions(test.name); etag != test.etag || since != lastModified {
+            t.Errorf("getUpdates() requested %s with If-None-Match %q and If-Modified-Since %q, want %q and %q",
+                test.name, etag, since, test.etag, lastModified)
+        }
+    }
+
+    // The unmodified files are reused from the previous index.
+    if want := []string { "app.yaml", PKGS_LIST_NAME, "tool.yaml" }; !slices.Equal(indexFiles(t), want) {
+        t.Fatalf("getUpdates() installed %v, want %v", indexFiles(t), want)
+    }
+    for pkg, version := range map[string]string { "app": "1.0.0", "tool": "2.0.0" } {
+        if content, _ := os.ReadFile(filepath.Join(indexDir(), pkg + ".yaml")); !strings.Contains(string(content), version) {
+            t.Errorf("getUpdates() installed %q as the manifest of %s, want version %s", content, pkg, version)
+        }
+    }
+    if got := readValidators()[manifestUrl("tool")].ETag; got != index.etag("manifests/tool.yaml") {
+        t.Errorf("getUpdates() recorded ETag %s for tool, want %s", got, index.etag("manifests/tool.yaml"))
+    }
+
+    // Without the validators, everything is downloaded again.
+    if err := os.Remove(filepath.Join(DataDir, VALIDATORS_NAME)); err != nil { t.Fatal(err) }
+    if err := getUpdates(2, false); err != nil { t.Fatalf("getUpdates() failed: %v", err) }
+    if etag, since := index.conditions(PKGS_LIST_NAME); etag != "" || since != "" {
+        t.Errorf("getUpdates() requested %s with If-None-Match %q and If-Modified-Since %q without validators", PKGS_LIST_NAME, etag, since)
+    }
+}
+
+func TestUpdateBundleUpToDate(t *testing.T) {
+    index := newTestIndex(t, testManifests("1.0.0", "app"))
+
+    if err := getUpdates(1, false); err != nil { t.Fatalf("getUpdates() failed: %v", err) }
+    current, _ := os.Readlink(indexDir())
+
+    if err := getUpdates(1, false); err != nil { t.Fatalf("getUpdates() failed: %v", err) }
+    if etag, _ := index.conditions(BUNDLE_NAME); etag != index.etag(BUNDLE_NAME) {
+        t.Errorf("getUpdates() requested the bundle with If-None-Match %q, want %q", etag, index.etag(BUNDLE_NAME))
+    }
+    // Nothing else is downloaded, and the index is kept as it is.
+    if requested := index.server.requested(); len(requested) != 4 {
+        t.Errorf("getUpdates() requested %v, want only the bundle once it's up to date", requested)
+    }
+    if link, _ := os.Readlink(indexDir()); link != current {
+        t.Errorf("getUpdates() pointed the index to %s, want %s", link, current)
+    }
+    if dirs := indexDirs(t); !slices.Equal(dirs, []string { current }) { t.Errorf("getUpdates() left %v", dirs) }
+}
+
+// captureStdout returns what the given function printed to the standard output.
+func captureStdout(t *testing.T, fn func()) string {
+    t.Helper()
+
+    reader, writer, err := os.Pipe()
+    if err != nil { t.Fatal(err) }
+    stdout := os.Stdout
+    os.Stdout = writer
+    defer func() { os.Stdout = stdout }()
+
+    output := make(chan string)
+    go func() {
+        content, _ := io.ReadAll(reader)
+        output <- string(content)
+    }()
+
+    fn()
+    _ = writer.Close()
+    return <-output
+}
+
+func TestUpdateManifestFailures(t *testing.T) {
+    index := newTestIndex(t, testManifests("1.0.0", "app", "tool", "extra", "lib"))
+    index.answer(BUNDLE_NAME, http.StatusNotFound)
+    if err := getUpdates(4, false); err != nil { t.Fatalf("getUpdates() failed: %v", err) }
+    toolValidator := readValidators()[manifestUrl("tool")]
+
+    index.publish(t, testManifests("2.0.0", "app", "tool", "extra", "lib", "new"))
+    index.answer(BUNDLE_NAME, http.StatusNotFound)
+    index.answer("manifests/tool.yaml", http.StatusInternalServerError)
+    index.answer("manifests/new.yaml", http.StatusNotFound)
+    index.set("manifests/extra.yaml", []byte(testManifest("extra", "6.6.6", "")))
+
+    err := error (nil)
+    output := captureStdout(t, func() { err = getUpdates(4, false) })
+    if err != nil { t.Fatalf("getUpdates() failed: %v", err) }
+
+    // The failing manifests keep their previous version, when there's one.
+    want := map[string]string { "app": "2.0.0", "lib": "2.0.0", "tool": "1.0.0", "extra": "1.0.0" }
+    if files := indexFiles(t); !slices.Equal(files, []string { "app.yaml", "extra.yaml", "lib.yaml", PKGS_LIST_NAME, "tool.yaml" }) {
+        t.Fatalf("getUpdates() installed %v", files)
+    }
+    for pkg, version := range want {
+        if content, _ := os.ReadFile(filepath.Join(indexDir(), pkg + ".yaml")); !strings.Contains(string(content), version) {
+            t.Errorf("getUpdates() installed %q as the manifest of %s, want version %s", content, pkg, version)
+        }
+    }
+    // So do their validators, so that they're requested again like the file they describe.
+    if got := readValidators()[manifestUrl("tool")]; got != toolValidator {
+        t.Errorf("getUpdates() recorded %+v for tool, want %+v", got, toolValidator)
+    }
+
+    for _, line := range []string {
+        "3 package manifest(s) could not be updated",
+        "    extra (keeping the previous manifest): ",
+        "    new (not available): ",
+        "    tool (keeping the previous manifest): ",
+        "(4 packages, ",
+    } {
+        if !strings.Contains(output, line) { t.Errorf("getUpdates() printed %q, want it to contain %q", output, line) }
+    }
+    // The failures are sorted by package name.
+    if strings.Index(output, "extra (") > strings.Index(output, "new (") || strings.Index(output, "new (") > strings.Index(output, "tool (") {
+        t.Errorf("getUpdates() printed the failures out of order: %q", output)
+    }
+}
@@ -1,59 +1,198 @@
 package cmd
 
 import (
+    "errors"
     "fmt"
     "os"
-    "os/exec"
-    "strings"
+    "time"
 
+    "github.com/FlawlessCasual17/rpm-get/backend"
     h "github.com/FlawlessCasual17/rpm-get/helpers"
+    "github.com/FlawlessCasual17/rpm-get/manifest"
+    "github.com/FlawlessCasual17/rpm-get/state"
+    "github.com/samber/lo"
     "github.com/spf13/cobra"
 )
 
+// wantsDryRun is set by `upgrade --dry-run`.
+var wantsDryRun bool
+
 // upgradeCmd represents the upgrade command
 var upgradeCmd = &cobra.Command{
-    Use:   "upgrade",
-    Short: "A brief description of your command",
-    Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-    Run: func(cmd *cobra.Command, args []string) {
-        fmt.Println("upgrade called")
+    Use:   "upgrade [--dry-run] [pkg...]",
+    Short: "Upgrade packages installed by rpm-get",
+    Long: `Upgrade the given packages installed by rpm-get, or all of them when none are given.
+Direct download packages are downloaded and upgraded when their manifest has a newer version
+than the installed one, while repository packages are upgraded from their repository.
+When --dry-run is provided, only print the direct download packages that would be upgraded,
+and the repository packages whose upgrade is left to the package manager.`,
+    Run: func(_ *cobra.Command, args []string) {
+        if err := upgradePkgs(args, wantsDryRun); err != nil {
+            h.Printc(err.Error(), h.ERROR, false)
+            os.Exit(h.ERROR_EXIT_CODE)
+        }
     },
 }
 
 func init() {
     rootCmd.AddCommand(upgradeCmd)
 
-    // Here you will define your flags and configuration settings.
+    upgradeCmd.Flags().BoolVar(&wantsDryRun, "dry-run", false, "Only print what would be upgraded")
+}
+
+// upgradeStep is a package that will be upgraded.
+type upgradeStep struct {
+    pkg *state.Package
+    data *manifest.Pkg
+    // Installed `epoch:version-release`
+    installed string
+}
+
+// upgradePkgs upgrades the given packages installed by rpm-get, or all of them if none are given.
+func upgradePkgs(names []string, dryRun bool) error {
+    if !dryRun && !isAdmin() {
+        h.Printc("rpm-get must be run as root!", h.ERROR, false)
+        os.Exit(h.ERROR_EXIT_CODE)
+    }
+
+    db := lo.Ternary(dryRun, readState, openState)()
+    //nolint:errcheck
+    defer db.Close()
+
+    pkgs := db.List()
+    if len(names) > 0 {
+        pkgs = []*state.Package {}
+        for _, name := range names {
+            pkg := db.Get(name)
+            if pkg == nil { return fmt.Errorf("%s was not installed by rpm-get", name) }
+            pkgs = append(pkgs, pkg)
+        }
+    }
+
+    direct, repo, err := planUpgrade(pkgs)
+    if err != nil { return err }
+
+    if len(direct) == 0 && len(repo) == 0 {
+        h.Printc("All packages are up to date!", h.INFO, false)
+        return nil
+    }
+
+    for _, step := range direct {
+        fmt.Printf("%s\t%s -> %s\t(direct download)\n", step.pkg.Name, step.installed, step.data.Version)
+    }
+    // Whether repository packages have a newer version is only known to the package manager.
+    for _, step := range repo {
+        fmt.Printf("%s\t%s\t(%s repository, checked by %s)\n",
+            step.pkg.Name, step.installed, step.pkg.Source, pkgManager().Name())
+    }
+    if dryRun { return nil }
+
+    if err := upgradeDirectPkgs(db, direct); err != nil { return err }
+
+    repoPkgs := lo.Map(repo, func(step upgradeStep, _ int) *state.Package { return step.pkg })
+    if len(repoPkgs) > 0 {
+        if err := upgradePkg(db, repoPkgs); err != nil { return err }
+    }
+
+    if err := db.Save(); err != nil {
+        h.Printc(err.Error(), h.ERROR, false)
+        return fmt.Errorf("Failed to record upgraded packages: %w", err)
+    }
+
+    h.Printc("Packages were successfully upgraded!", h.INFO, true)
+    return nil
+}
+
+// planUpgrade splits the given packages into direct download packages that have a newer
+// version in their manifest, and repository packages. Direct download packages whose manifest
+// now points to a repository are skipped.
+func planUpgrade(pkgs []*state.Package) ([]upgradeStep, []upgradeStep, error) {
+    direct, repo := []upgradeStep {}, []upgradeStep {}
+
+    for _, pkg := range pkgs {
+        installed, err := pkgManager().InstalledVersion(pkg.RpmName)
+        if errors.Is(err, backend.ErrNotInstalled) {
+            msg := fmt.Sprintf("%s is no longer installed, skipping", pkg.Name)
+            h.Printc(msg, h.WARNING, false)
+            continue
+        } else if err != nil {
+            return nil, nil, err
+        }
+
+        data, loadErr := resolvePkg(pkg.Name)
+        if loadErr != nil {
+            msg := fmt.Sprintf("The latest version of %s could not be resolved, skipping: %s", pkg.Name, loadErr)
+            h.Printc(msg, h.WARNING, false)
+            continue
+        }
+
+        // Switching to a repository means adding it, which is left to `remove` and `install`.
+        if pkg.Source == state.SOURCE_DIRECT && manifestSource(data) != state.SOURCE_DIRECT {
+            msg := fmt.Sprintf("%s is now installed from a %s repository, skipping: remove and install it again to switch",
+                pkg.Name, manifestSource(data))
+            h.Printc(msg, h.WARNING, false)
+            continue
+        }
+
+        step := upgradeStep { pkg: pkg, data: data, installed: installed }
+        if pkg.Source != state.SOURCE_DIRECT {
+            repo = append(repo, step)
+            continue
+        }
+
+        if manifest.CompareEvr(data.Version, installed) > 0 { direct = append(direct, step) }
+    }
+
+    return direct, repo, nil
+}
+
+// upgradeDirectPkgs downloads the new RPMs of the given direct download packages,
+// and upgrades them in a single transaction. The manifests resolved by `planUpgrade` are
+// used, so that the version that was compared is the one installed.
+func upgradeDirectPkgs(db *state.DB, steps []upgradeStep) error {
+    if len(steps) == 0 { return nil }
+
+    targets := []installTarget {}
+    for _, step := range steps {
+        target, err := prepareInstallTarget(db, step.data)
+        if err != nil { return fmt.Errorf("Unable to upgrade %s: %w", step.data.Name, err) }
+        targets = append(targets, target)
+    }
+
+    files := lo.Map(targets, func(target installTarget, _ int) string { return target.filePath })
+    if err := installPkg(files, nil); err != nil { return err }
 
-    // Cobra supports Persistent Flags which will work for this command
-    // and all subcommands, e.g.:
-    // upgradeCmd.PersistentFlags().String("foo", "", "A help for foo")
+    for _, target := range targets {
+        pkg := target.pkg
+        pkg.InstalledAt = time.Now()
+        if version, err := pkgManager().InstalledVersion(pkg.RpmName); err == nil { pkg.Version = version }
+        db.Put(&pkg)
+    }
 
-    // Cobra supports local flags which will only run when this command
-    // is called directly, e.g.:
-    // upgradeCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+    return nil
 }
 
-// upgradePkg upgrades the given RPM packages.
-func upgradePkg(pkgs []string) {
+// upgradePkg upgrades the given repository packages and records their new versions.
+func upgradePkg(db *state.DB, pkgs []*state.Package) error {
     if !isAdmin() {
         h.Printc("rpm-get must be run as root!", h.ERROR, false)
         os.Exit(h.ERROR_EXIT_CODE)
     }
 
-    cmd := which("sudo") + " " + which("dnf")
-    args := []string { "install", "-y", strings.Join(pkgs, " ") }
-    command := exec.Command(cmd, args...)
-    out, err := command.Output()
-
-    if err != nil {
+    rpmNames := lo.Map(pkgs, func(pkg *state.Package, _ int) string { return pkg.RpmName })
+    if err := pkgManager().Upgrade(rpmNames...); err != nil {
         h.Printc(err.Error(), h.ERROR, false)
-    } else {
-        println(out)
+        return fmt.Errorf("Failed to upgrade packages: %w", err)
     }
+
+    for _, pkg := range pkgs {
+        version, err := pkgManager().InstalledVersion(pkg.RpmName)
+        if err != nil || version == pkg.Version { continue }
+
+        pkg.Version = version
+        pkg.InstalledAt = time.Now()
+        db.Put(pkg)
+    }
+
+    return nil
 }
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+    "slices"
+    "testing"
+
+    "github.com/FlawlessCasual17/rpm-get/state"
+)
+
+func TestPlanUpgrade(t *testing.T) {
+    useTestDirs(t)
+    fake := newFakeBackend(t)
+
+    // A manifest with a repo ignores its `arch` entries.
+    writeIndex(t, map[string]string {
+        "app": testManifest("app", "1.1.0", ""),
+        "current": testManifest("current", "2.0.0", ""),
+        "moved": testManifest("moved", "3.0.0", "repo:\n  copr_repo: { username: user, project: moved }\n"),
+        "repo-app": testManifest("repo-app", "1.0.0", "repo:\n  url_repo: { url: https://example.com/repo-app.repo }\n"),
+        "gone": testManifest("gone", "1.0.0", ""),
+    })
+    fake.installed["app"] = "0:1.0.0-1"
+    fake.installed["current"] = "0:2.0.0-1"
+    fake.installed["moved"] = "0:2.0.0-1"
+    fake.installed["repo-app"] = "0:1.0.0-1"
+    fake.installed["unlisted"] = "0:1.0.0-1"
+
+    pkgs := []*state.Package {
+        { Name: "app", RpmName: "app", Source: state.SOURCE_DIRECT },
+        { Name: "current", RpmName: "current", Source: state.SOURCE_DIRECT },
+        // Recorded as a direct download, but its manifest now points to a copr repo.
+        { Name: "moved", RpmName: "moved", Source: state.SOURCE_DIRECT },
+        { Name: "repo-app", RpmName: "repo-app", Source: state.SOURCE_REPO },
+        // Removed outside of rpm-get
+        { Name: "gone", RpmName: "gone", Source: state.SOURCE_DIRECT },
+        // Missing from the package index
+        { Name: "unlisted", RpmName: "unlisted", Source: state.SOURCE_DIRECT },
+    }
+
+    direct, repo, err := planUpgrade(pkgs)
+    if err != nil { t.Fatal(err) }
+
+    names := func(steps []upgradeStep) []string {
+        result := []string {}
+        for _, step := range steps { result = append(result, step.pkg.Name) }
+        return result
+    }
+    if want := []string { "app" }; !slices.Equal(names(direct), want) {
+        t.Errorf("planUpgrade() direct = %v, want %v", names(direct), want)
+    }
+    if want := []string { "repo-app" }; !slices.Equal(names(repo), want) {
+        t.Errorf("planUpgrade() repo = %v, want %v", names(repo), want)
+    }
+
+    if len(direct) == 1 && (direct[0].installed != "0:1.0.0-1" || direct[0].data.Version != "1.1.0") {
+        t.Errorf("planUpgrade() plans %s -> %s, want 0:1.0.0-1 -> 1.1.0", direct[0].installed, direct[0].data.Version)
+    }
+}
+
+func TestUpgradeDirectPkgsSkipsRepoManifests(t *testing.T) {
+    useTestDirs(t)
+    fake := newFakeBackend(t)
+    db := openTestState(t)
+
+    writeIndex(t, map[string]string {
+        "moved": testManifest("moved", "3.0.0", "repo:\n  copr_repo: { username: user, project: moved }\n"),
+    })
+    fake.installed["moved"] = "0:2.0.0-1"
+    db.Put(&state.Package { Name: "moved", RpmName: "moved", Source: state.SOURCE_DIRECT })
+
+    direct, _, err := planUpgrade(db.List())
+    if err != nil { t.Fatal(err) }
+    if err := upgradeDirectPkgs(db, direct); err != nil { t.Fatal(err) }
+
+    // Nothing is handed to the package manager, least of all an empty file name.
+    if len(fake.installs) != 0 || len(fake.coprRepos) != 0 {
+        t.Errorf("upgradeDirectPkgs() installed %v and enabled %v, want nothing", fake.installs, fake.coprRepos)
+    }
+}
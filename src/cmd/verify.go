@@ -0,0 +1,115 @@
+package cmd
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "os"
+    "strings"
+
+    h "github.com/FlawlessCasual17/rpm-get/helpers"
+    "aead.dev/minisign"
+    "github.com/samber/lo"
+)
+
+const (
+    // SIGNATURE_EXT is the extension of the detached minisign signatures of the package index.
+    SIGNATURE_EXT string = ".minisig"
+
+    // SUMS_NAME is the name of the file listing the SHA-256 digest of every file of the package
+    // index, in the format of `sha256sum`. It's signed for the per-file mode.
+    SUMS_NAME string = "SHA256SUMS"
+)
+
+// IndexPublicKey is the minisign public key trusted to sign the package index.
+// It's set at build time with `-ldflags "-X ...cmd.IndexPublicKey=<key>"`,
+// and may be replaced by `index_public_key` in `ConfigFile`.
+var IndexPublicKey = ""
+
+// errUntrusted is returned when the package index can't be authenticated.
+var errUntrusted = errors.New("The package index could not be verified, pass --insecure to use it anyway")
+
+// indexVerifier authenticates the package index with its detached minisign signatures.
+// Its zero value (with a nil key) is only used with `--insecure`, and accepts everything.
+type indexVerifier struct {
+    key *minisign.PublicKey
+}
+
+// newIndexVerifier returns a verifier for the trusted public key.
+// Without `insecure`, it's an error for no key to be configured.
+func newIndexVerifier(insecure bool) (*indexVerifier, error) {
+    if insecure {
+        h.Printc("Signature verification of the package index is disabled!", h.WARNING, false)
+        return &indexVerifier {}, nil
+    }
+
+    config, configErr := readConfig()
+    if configErr != nil { return nil, configErr }
+
+    encoded := lo.Ternary(config.IndexPublicKey != "", config.IndexPublicKey, IndexPublicKey)
+    if encoded == "" {
+        return nil, fmt.Errorf("%w: no trusted public key was built in or set as index_public_key in %s",
+            errUntrusted, ConfigFile)
+    }
+
+    key := minisign.PublicKey {}
+    if err := key.UnmarshalText([]byte(parseMinisignKey(encoded))); err != nil {
+        return nil, fmt.Errorf("Invalid index_public_key: %w", err)
+    }
+
+    return &indexVerifier { key: &key }, nil
+}
+
+// enabled reports whether signatures are checked.
+func (v *indexVerifier) enabled() bool { return v.key != nil }
+
+// verify downloads the detached signature at `url` + `SIGNATURE_EXT` and checks it against `content`.
+func (v *indexVerifier) verify(url string, content []byte) error {
+    if !v.enabled() { return nil }
+
+    signature := bytes.Buffer {}
+    if _, err := download(url + SIGNATURE_EXT, &signature, "", nil); err != nil {
+        return fmt.Errorf("%w: unable to download the signature of %s: %w", errUntrusted, url, err)
+    }
+
+    if !minisign.Verify(*v.key, content, signature.Bytes()) {
+        return fmt.Errorf("%w: the signature of %s doesn't match the trusted public key", errUntrusted, url)
+    }
+
+    return nil
+}
+
+// parseMinisignKey returns the key of a minisign `.pub` file, which may also be passed as is.
+func parseMinisignKey(content string) string {
+    lines := strings.Split(strings.TrimSpace(content), "\n")
+    return strings.TrimSpace(lines[len(lines) - 1])
+}
+
+// parseSums parses a file in the format of `sha256sum`, returning the digests by file name.
+func parseSums(content []byte) map[string]string {
+    sums := map[string]string {}
+    for _, line := range strings.Split(string(content), "\n") {
+        fields := strings.Fields(line)
+        if len(fields) != 2 { continue }
+        sums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+    }
+    return sums
+}
+
+// checkSum checks that the file at `filePath` has the digest listed for `name`.
+func checkSum(sums map[string]string, name string, filePath string) error {
+    want, ok := sums[name]
+    if !ok { return fmt.Errorf("%w: %s is not listed in %s", errUntrusted, name, SUMS_NAME) }
+
+    content, readErr := os.ReadFile(filePath)
+    if readErr != nil { return fmt.Errorf("Failed to read %s: %w", name, readErr) }
+
+    sum := sha256.Sum256(content)
+    if hex.EncodeToString(sum[:]) != want {
+        return fmt.Errorf("%w: %s doesn't match its signed digest", errUntrusted, name)
+    }
+
+    return nil
+}
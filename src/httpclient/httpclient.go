@@ -0,0 +1,245 @@
+// Package httpclient builds the HTTP client shared by every rpm-get command, with timeouts,
+// retries of transient failures, proxies from the environment and additional trusted CAs.
+package httpclient
+
+import (
+    "context"
+    "crypto/tls"
+    "crypto/x509"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "os"
+    "strconv"
+    "time"
+)
+
+const (
+    // DEFAULT_CONNECT_TIMEOUT is the default timeout of TCP connections and TLS handshakes.
+    DEFAULT_CONNECT_TIMEOUT time.Duration = 15 * time.Second
+
+    // DEFAULT_READ_TIMEOUT is the default time to wait for response headers,
+    // and for each read of a response body.
+    DEFAULT_READ_TIMEOUT time.Duration = 60 * time.Second
+
+    // DEFAULT_MAX_ATTEMPTS is the default number of times a request is attempted.
+    DEFAULT_MAX_ATTEMPTS int = 4
+
+    // DEFAULT_RETRY_DELAY is the default delay before the second attempt of a request,
+    // doubled for each later attempt.
+    DEFAULT_RETRY_DELAY time.Duration = time.Second
+
+    // MAX_RETRY_DELAY is the longest a request is delayed before it's attempted again.
+    // Responses whose `Retry-After` asks for a longer delay are returned as they are.
+    MAX_RETRY_DELAY time.Duration = time.Minute
+)
+
+// StatusError is returned for responses with an unexpected status code.
+type StatusError struct {
+    Method string
+    Url string
+    // Status line, e.g. `404 Not Found`
+    Status string
+    StatusCode int
+    // Headers of the response, e.g. to read rate limits from
+    Header http.Header
+}
+
+func (e *StatusError) Error() string { return fmt.Sprintf("%s %s returned %s", e.Method, e.Url, e.Status) }
+
+// IsStatus reports whether `err` is a `StatusError` with one of the given status codes.
+func IsStatus(err error, codes ...int) bool {
+    statusErr := &StatusError {}
+    if !errors.As(err, &statusErr) { return false }
+
+    for _, code := range codes {
+        if statusErr.StatusCode == code { return true }
+    }
+    return false
+}
+
+// CheckStatus returns a `StatusError` unless the response has a 2xx status code, or one of
+// the given status codes. The body is closed when an error is returned.
+func CheckStatus(resp *http.Response, allowed ...int) error {
+    if resp.StatusCode >= 200 && resp.StatusCode < 300 { return nil }
+    for _, code := range allowed {
+        if resp.StatusCode == code { return nil }
+    }
+
+    _ = resp.Body.Close()
+    return &StatusError {
+        Method: resp.Request.Method, Url: resp.Request.URL.Redacted(),
+        Status: resp.Status, StatusCode: resp.StatusCode, Header: resp.Header,
+    }
+}
+
+// Options configures the client built by `New`. Zero values use the defaults.
+type Options struct {
+    // User agent sent with requests that don't set one
+    UserAgent string
+    ConnectTimeout time.Duration
+    ReadTimeout time.Duration
+    MaxAttempts int
+    RetryDelay time.Duration
+    // PEM files of CAs to trust in addition to the system ones, e.g. for a TLS intercepting proxy
+    CaBundles []string
+}
+
+// New builds an HTTP client. Proxies are read from `HTTPS_PROXY`, `HTTP_PROXY` and `NO_PROXY`.
+// Requests are retried with an exponential backoff on network errors, `408`, `429` and `5xx`
+// responses, honouring `Retry-After`. Only the requests are retried: a response whose body
+// fails to be read, e.g. when the connection is reset mid-transfer, must be retried by the caller.
+func New(options Options) (*http.Client, error) {
+    if options.ConnectTimeout <= 0 { options.ConnectTimeout = DEFAULT_CONNECT_TIMEOUT }
+    if options.ReadTimeout <= 0 { options.ReadTimeout = DEFAULT_READ_TIMEOUT }
+    if options.MaxAttempts <= 0 { options.MaxAttempts = DEFAULT_MAX_ATTEMPTS }
+    if options.RetryDelay <= 0 { options.RetryDelay = DEFAULT_RETRY_DELAY }
+
+    tlsConfig := &tls.Config { MinVersion: tls.VersionTLS12 }
+    if len(options.CaBundles) > 0 {
+        pool, err := certPool(options.CaBundles)
+        if err != nil { return nil, err }
+        tlsConfig.RootCAs = pool
+    }
+
+    dialer := &net.Dialer { Timeout: options.ConnectTimeout, KeepAlive: 30 * time.Second }
+    transport := &http.Transport {
+        Proxy: http.ProxyFromEnvironment,
+        DialContext: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+            conn, err := dialer.DialContext(ctx, network, addr)
+            if err != nil { return nil, err }
+            return &timeoutConn { Conn: conn, readTimeout: options.ReadTimeout }, nil
+        },
+        TLSClientConfig: tlsConfig,
+        TLSHandshakeTimeout: options.ConnectTimeout,
+        ResponseHeaderTimeout: options.ReadTimeout,
+        IdleConnTimeout: 90 * time.Second,
+        MaxIdleConnsPerHost: 8,
+        ForceAttemptHTTP2: true,
+    }
+
+    client := &http.Client {
+        Transport: &retryTransport {
+            base: transport,
+            userAgent: options.UserAgent,
+            maxAttempts: options.MaxAttempts,
+            retryDelay: options.RetryDelay,
+        },
+    }
+
+    return client, nil
+}
+
+// certPool returns the system CAs, with the CAs of the given PEM files added.
+func certPool(caBundles []string) (*x509.CertPool, error) {
+    pool, err := x509.SystemCertPool()
+    if err != nil { pool = x509.NewCertPool() }
+
+    for _, caBundle := range caBundles {
+        content, readErr := os.ReadFile(caBundle)
+        if readErr != nil { return nil, fmt.Errorf("Failed to read CA bundle: %w", readErr) }
+        if !pool.AppendCertsFromPEM(content) {
+            return nil, fmt.Errorf("No PEM certificate was found in the CA bundle %s", caBundle)
+        }
+    }
+
+    return pool, nil
+}
+
+// timeoutConn is a connection whose reads fail when no data was received for `readTimeout`,
+// so that stalled downloads fail instead of hanging forever.
+type timeoutConn struct {
+    net.Conn
+    readTimeout time.Duration
+}
+
+func (c *timeoutConn) Read(b []byte) (int, error) {
+    if err := c.Conn.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil { return 0, err }
+    return c.Conn.Read(b)
+}
+
+// retryTransport sets the user agent of requests, and retries transient failures.
+type retryTransport struct {
+    base http.RoundTripper
+    userAgent string
+    maxAttempts int
+    retryDelay time.Duration
+}
+
+func (t *retryTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+    if t.userAgent != "" && request.Header.Get("User-Agent") == "" {
+        // RoundTrippers must not modify the request they were given.
+        request = request.Clone(request.Context())
+        request.Header.Set("User-Agent", t.userAgent)
+    }
+
+    // Requests with a body can only be sent again when it can be rewound.
+    canRetry := request.Body == nil || request.Body == http.NoBody || request.GetBody != nil
+
+    for attempt := 1; ; attempt++ {
+        resp, err := t.base.RoundTrip(request)
+
+        delay, retry := t.retryAfter(resp, err, attempt)
+        if !retry || !canRetry || attempt >= t.maxAttempts { return resp, err }
+
+        if resp != nil {
+            // Drain the body so that the connection can be reused.
+            _, _ = io.CopyN(io.Discard, resp.Body, 64 << 10)
+            _ = resp.Body.Close()
+        }
+
+        select {
+        case <-request.Context().Done():
+            return nil, request.Context().Err()
+        case <-time.After(delay):
+        }
+
+        if request.GetBody != nil {
+            body, bodyErr := request.GetBody()
+            if bodyErr != nil { return nil, bodyErr }
+            request = request.Clone(request.Context())
+            request.Body = body
+        }
+    }
+}
+
+// retryAfter reports whether the given outcome of an attempt is transient, and how long to
+// wait before the next attempt.
+func (t *retryTransport) retryAfter(resp *http.Response, err error, attempt int) (time.Duration, bool) {
+    backoff := min(t.retryDelay << (attempt - 1), MAX_RETRY_DELAY)
+
+    switch {
+    case err != nil:
+        // Untrusted certificates won't become trusted by trying again.
+        certErr := &tls.CertificateVerificationError {}
+        if errors.As(err, &certErr) { return 0, false }
+        return backoff, !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+    case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+        delay, ok := ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+        if ok { return delay, delay <= MAX_RETRY_DELAY }
+        // An exhausted API rate limit only resets after a while, e.g. an hour for GitHub.
+        exhausted := resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.Header.Get("RateLimit-Remaining") == "0"
+        return backoff, !exhausted
+    case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode >= 500:
+        return backoff, true
+    default:
+        return 0, false
+    }
+}
+
+// ParseRetryAfter parses a `Retry-After` header, either a number of seconds or an HTTP date,
+// into the delay to wait from `now`.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+    if value == "" { return 0, false }
+
+    if seconds, err := strconv.Atoi(value); err == nil {
+        return time.Duration(max(seconds, 0)) * time.Second, true
+    }
+    if date, err := http.ParseTime(value); err == nil {
+        return max(date.Sub(now), 0), true
+    }
+
+    return 0, false
+}
@@ -0,0 +1,149 @@
+package httpclient
+
+import (
+    "encoding/pem"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+// newFlakyServer returns a server that answers the first `failures` requests with `status`.
+func newFlakyServer(t *testing.T, failures int32, status int, retryAfter string) (*httptest.Server, *atomic.Int32) {
+    requests := &atomic.Int32 {}
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if requests.Add(1) <= failures {
+            if retryAfter != "" { w.Header().Set("Retry-After", retryAfter) }
+            w.WriteHeader(status)
+            return
+        }
+        _, _ = w.Write([]byte(r.Header.Get("User-Agent")))
+    }))
+
+    t.Cleanup(server.Close)
+    return server, requests
+}
+
+func TestRetries(t *testing.T) {
+    tests := []struct {
+        status int
+        retryAfter string
+        wantRequests int32
+        wantStatus int
+    }{
+        { http.StatusServiceUnavailable, "", 3, http.StatusOK },
+        { http.StatusTooManyRequests, "0", 3, http.StatusOK },
+        { http.StatusTooManyRequests, "3600", 1, http.StatusTooManyRequests },
+        { http.StatusNotFound, "", 1, http.StatusNotFound },
+    }
+
+    for _, test := range tests {
+        server, requests := newFlakyServer(t, 2, test.status, test.retryAfter)
+        client := mustNew(t, Options { UserAgent: "rpm-get-test", RetryDelay: time.Millisecond })
+
+        resp, err := client.Get(server.URL)
+        if err != nil { t.Fatal(err) }
+        _ = resp.Body.Close()
+
+        if requests.Load() != test.wantRequests || resp.StatusCode != test.wantStatus {
+            t.Errorf("%d (Retry-After %q): got %d requests and %s, want %d requests and %d",
+                test.status, test.retryAfter, requests.Load(), resp.Status, test.wantRequests, test.wantStatus)
+        }
+    }
+}
+
+func TestInterruptedBodyNotRetried(t *testing.T) {
+    requests := &atomic.Int32 {}
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+        requests.Add(1)
+        // The connection is closed after half of the announced body.
+        w.Header().Set("Content-Length", "1000")
+        _, _ = w.Write(make([]byte, 500))
+    }))
+    t.Cleanup(server.Close)
+    client := mustNew(t, Options { RetryDelay: time.Millisecond })
+
+    resp, err := client.Get(server.URL)
+    if err != nil { t.Fatal(err) }
+    //nolint:errcheck
+    defer resp.Body.Close()
+
+    if _, err := io.ReadAll(resp.Body); err == nil { t.Error("ReadAll() read the whole truncated body") }
+    if requests.Load() != 1 { t.Errorf("got %d requests, want 1: bodies are retried by the caller", requests.Load()) }
+}
+
+func TestCheckStatus(t *testing.T) {
+    server, _ := newFlakyServer(t, 1, http.StatusNotFound, "")
+    client := mustNew(t, Options { UserAgent: "rpm-get-test" })
+
+    resp, err := client.Get(server.URL)
+    if err != nil { t.Fatal(err) }
+    if err := CheckStatus(resp); !IsStatus(err, http.StatusNotFound) {
+        t.Errorf("CheckStatus() = %v, want a 404 StatusError", err)
+    }
+
+    resp, err = client.Get(server.URL)
+    if err != nil { t.Fatal(err) }
+    //nolint:errcheck
+    defer resp.Body.Close()
+    if err := CheckStatus(resp); err != nil { t.Errorf("CheckStatus() = %v, want nil", err) }
+
+    body := make([]byte, 64)
+    n, _ := resp.Body.Read(body)
+    if string(body[:n]) != "rpm-get-test" { t.Errorf("User-Agent = %q, want rpm-get-test", body[:n]) }
+}
+
+func TestParseRetryAfter(t *testing.T) {
+    now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+    tests := map[string]time.Duration {
+        "120": 2 * time.Minute,
+        "Wed, 01 Jan 2025 00:00:30 GMT": 30 * time.Second,
+        "Tue, 31 Dec 2024 23:00:00 GMT": 0,
+    }
+    for value, want := range tests {
+        if got, ok := ParseRetryAfter(value, now); !ok || got != want {
+            t.Errorf("ParseRetryAfter(%q) = %v, %v, want %v", value, got, ok, want)
+        }
+    }
+
+    if _, ok := ParseRetryAfter("soon", now); ok { t.Error("ParseRetryAfter(\"soon\") succeeded") }
+}
+
+func TestCaBundles(t *testing.T) {
+    server := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+    t.Cleanup(server.Close)
+
+    caBundle := filepath.Join(t.TempDir(), "server.pem")
+    block := &pem.Block { Type: "CERTIFICATE", Bytes: server.Certificate().Raw }
+    if err := os.WriteFile(caBundle, pem.EncodeToMemory(block), 0644); err != nil { t.Fatal(err) }
+
+    if resp, err := mustNew(t, Options { MaxAttempts: 1 }).Get(server.URL); err == nil {
+        _ = resp.Body.Close()
+        t.Error("the test server was trusted without its CA bundle")
+    }
+    resp, err := mustNew(t, Options { CaBundles: []string { caBundle } }).Get(server.URL)
+    if err != nil { t.Fatalf("the test server was not trusted with its CA bundle: %v", err) }
+    _ = resp.Body.Close()
+
+    invalid := filepath.Join(t.TempDir(), "ca.pem")
+    if err := os.WriteFile(invalid, []byte("not a certificate"), 0644); err != nil { t.Fatal(err) }
+
+    if _, err := New(Options { CaBundles: []string { invalid } }); err == nil {
+        t.Error("New() accepted a CA bundle without certificates")
+    }
+    if _, err := New(Options { CaBundles: []string { invalid + ".missing" } }); err == nil {
+        t.Error("New() accepted a missing CA bundle")
+    }
+}
+
+// mustNew builds a client, failing the test on errors.
+func mustNew(t *testing.T, options Options) *http.Client {
+    client, err := New(options)
+    if err != nil { t.Fatal(err) }
+    return client
+}
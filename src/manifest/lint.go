@@ -0,0 +1,226 @@
+package manifest
+
+import (
+    "errors"
+    "fmt"
+    "net/url"
+    "os"
+    "regexp"
+    "slices"
+    "strings"
+
+    // third-party imports
+    "github.com/goccy/go-json"
+    "github.com/goccy/go-yaml"
+    "github.com/goccy/go-yaml/ast"
+    "github.com/goccy/go-yaml/parser"
+    "github.com/goccy/go-yaml/token"
+    "github.com/samber/lo"
+)
+
+// parentRegex matches the last segment of a field path.
+var parentRegex = regexp.MustCompile(`(\.[^.\[]+|\[\d+\])$`)
+
+// Problem is a lint finding located in a manifest file.
+type Problem struct {
+    File string
+    Line int
+    Column int
+    // Path to the field, empty if the problem concerns the whole file
+    Field string
+    Msg string
+}
+
+func (p Problem) String() string {
+    if p.Field == "" { return fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line, p.Column, p.Msg) }
+    return fmt.Sprintf("%s:%d:%d: %s: %s", p.File, p.Line, p.Column, p.Field, p.Msg)
+}
+
+// Linter runs the schema checks, as well as stricter style checks, over manifest files.
+// It remembers the package names it has seen to report duplicates across files.
+type Linter struct {
+    names map[string]string
+}
+
+// NewLinter creates a new `Linter`.
+func NewLinter() *Linter { return &Linter { names: map[string]string {} } }
+
+// lintFile holds the state for linting a single file.
+type lintFile struct {
+    path string
+    ast *ast.File
+    problems []Problem
+}
+
+// add records a problem with the given field, located by its path in the file.
+func (f *lintFile) add(field string, format string, args ...any) {
+    line, column := f.position(field)
+    f.problems = append(f.problems, Problem {
+        File: f.path, Line: line, Column: column, Field: field, Msg: fmt.Sprintf(format, args...),
+    })
+}
+
+// addErr records a decoding error, located by its token if it has one.
+func (f *lintFile) addErr(err error) {
+    problem := Problem { File: f.path, Line: 1, Column: 1, Msg: err.Error() }
+
+    yamlErr := yaml.Error (nil)
+    if errors.As(err, &yamlErr) && yamlErr.GetToken() != nil {
+        problem.Line = yamlErr.GetToken().Position.Line
+        problem.Column = yamlErr.GetToken().Position.Column
+        problem.Msg = yamlErr.GetMessage()
+    }
+
+    f.problems = append(f.problems, problem)
+}
+
+// node returns the AST node of the given field, or nil if it's not present.
+func (f *lintFile) node(field string) ast.Node {
+    if f.ast == nil { return nil }
+
+    path, pathErr := yaml.PathString("$." + field)
+    if pathErr != nil { return nil }
+
+    node, err := path.FilterFile(f.ast)
+    if err != nil { return nil }
+    return node
+}
+
+// position returns the line and column of the given field.
+// Missing fields are located at their closest present parent.
+func (f *lintFile) position(field string) (int, int) {
+    for path := field; path != ""; path = parentRegex.ReplaceAllString(path, "") {
+        if node := f.node(path); node != nil && nodeToken(node) != nil {
+            return nodeToken(node).Position.Line, nodeToken(node).Position.Column
+        }
+        if !parentRegex.MatchString(path) { break }
+    }
+
+    return 1, 1
+}
+
+// nodeToken returns the token the given node starts at. Block mappings are located
+// at their first key, rather than at the ':' that follows it.
+func nodeToken(node ast.Node) *token.Token {
+    switch n := node.(type) {
+    case *ast.MappingValueNode:
+        if n.Key != nil { return n.Key.GetToken() }
+    case *ast.MappingNode:
+        if !n.IsFlowStyle && len(n.Values) > 0 && n.Values[0].Key != nil { return n.Values[0].Key.GetToken() }
+    }
+    return node.GetToken()
+}
+
+// Lint checks the manifest at the given path and returns every problem found.
+func (l *Linter) Lint(filePath string) []Problem {
+    f := &lintFile { path: filePath }
+
+    format, formatErr := FormatOf(filePath)
+    if formatErr != nil {
+        f.addErr(formatErr)
+        return f.problems
+    }
+
+    content, readErr := os.ReadFile(filePath)
+    if readErr != nil {
+        f.addErr(fmt.Errorf("Failed to read manifest: %w", readErr))
+        return f.problems
+    }
+
+    // JSON is a subset of YAML, so both formats can be located using the YAML parser.
+    file, parseErr := parser.ParseBytes(content, 0)
+    if parseErr != nil {
+        f.addErr(parseErr)
+        return f.problems
+    }
+    f.ast = file
+
+    if format == JSON {
+        if err := json.Unmarshal(content, &Pkg {}); err != nil {
+            f.addErr(err)
+            return f.problems
+        }
+    }
+
+    pkg := Pkg {}
+    if err := yaml.UnmarshalWithOptions(content, &pkg, yaml.Strict()); err != nil {
+        f.addErr(err)
+
+        // Keep going with the lenient decoder, so the remaining problems are still reported.
+        if err := yaml.Unmarshal(content, &pkg); err != nil { return f.problems }
+    }
+
+    validationErr := ValidationError (nil)
+    if errors.As(pkg.Validate(), &validationErr) {
+        for _, fieldErr := range validationErr { f.add(fieldErr.Field, "%s", fieldErr.Msg) }
+    }
+
+    l.lintStyle(f, &pkg)
+
+    return f.problems
+}
+
+// lintStyle runs the checks that go beyond the manifest schema.
+func (l *Linter) lintStyle(f *lintFile, pkg *Pkg) {
+    if node := f.node("version"); node != nil && node.Type() != ast.StringType {
+        f.add("version", "must be quoted, otherwise %q is read as a %s", node.String(), node.Type().YAMLName())
+    }
+
+    if pkg.License != nil && pkg.License.Identifier() != "" {
+        field := "license"
+        if pkg.License.License != nil { field = "license.identifier" }
+
+        if unknown := checkSpdxExpression(pkg.License.Identifier()); len(unknown) > 0 {
+            f.add(field, "unknown SPDX license identifier(s): %s", strings.Join(unknown, ", "))
+        }
+    }
+
+    for i, name := range pkg.SupportedOs {
+        if name != "" && !slices.Contains(SUPPORTED_OS, name) {
+            f.add(fmt.Sprintf("supported_os[%d]", i), "unknown OS %q, expected one of %s",
+                name, strings.Join(SUPPORTED_OS, ", "))
+        }
+    }
+
+    urls := map[string]string { "homepage": pkg.Homepage, "gpg_key_url": pkg.GpgKeyUrl }
+    for _, key := range pkg.Arch.Keys() { urls["arch." + key + ".url"] = pkg.Arch.Get(key).Url }
+    if pkg.Repo != nil && pkg.Repo.UrlRepo != nil {
+        urls["repo.url_repo.url"] = pkg.Repo.UrlRepo.Url
+        urls["repo.url_repo.gpg_key_url"] = pkg.Repo.UrlRepo.GpgKeyUrl
+    }
+    if pkg.Source != nil && pkg.Source.Gitlab != nil {
+        urls["source.gitlab.instance_url"] = pkg.Source.Gitlab.InstanceUrl
+    }
+    if pkg.Source != nil && pkg.Source.Html != nil { urls["source.html.url"] = pkg.Source.Html.Url }
+    if pkg.VersionSource != nil { urls["version_source.url"] = pkg.VersionSource.Url }
+
+    fields := lo.Keys(urls)
+    slices.Sort(fields)
+    for _, field := range fields {
+        if urls[field] == "" { continue }
+        if msg := checkUrl(urls[field]); msg != "" { f.add(field, "%s", msg) }
+    }
+
+    if pkg.Name != "" {
+        if other, ok := l.names[pkg.Name]; ok {
+            f.add("name", "duplicate package name %q, already declared in %s", pkg.Name, other)
+        } else {
+            l.names[pkg.Name] = f.path
+        }
+    }
+}
+
+// checkUrl returns a description of what's wrong with the given URL, or an empty string.
+func checkUrl(raw string) string {
+    u, err := url.Parse(raw)
+    switch {
+    case err != nil:
+        return fmt.Sprintf("malformed URL %q", raw)
+    case u.Scheme != "https" && u.Scheme != "http":
+        return fmt.Sprintf("URL %q must use http or https", raw)
+    case u.Host == "":
+        return fmt.Sprintf("URL %q is missing a host", raw)
+    default:
+        return ""
+    }
+}
@@ -0,0 +1,195 @@
+package manifest
+
+import (
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+    "testing"
+)
+
+// writeManifest writes a manifest to the given directory and returns its path.
+func writeManifest(t *testing.T, dir string, name string, content string) string {
+    t.Helper()
+
+    filePath := filepath.Join(dir, name)
+    if err := os.WriteFile(filePath, []byte(content), 0644); err != nil { t.Fatal(err) }
+    return filePath
+}
+
+// findProblem returns the problem reported for the given field, or nil.
+func findProblem(problems []Problem, field string) *Problem {
+    for i := range problems {
+        if problems[i].Field == field { return &problems[i] }
+    }
+    return nil
+}
+
+func TestLintValid(t *testing.T) {
+    dir := t.TempDir()
+
+    for name, content := range map[string]string {
+        "app.yaml": directManifest,
+        "copr.yaml": "name: copr\nversion: \"1.0\"\ndescription: From copr\npkg_arches: [x86_64]\nrepo:\n  coprRepo: { username: user, project: copr }\n",
+        "repo.json": `{"name":"repo","version":"1.0","description":"From a repo","pkg_arches":["x86_64"],"repo":{"url_repo":{"url":"https://example.com/repo.repo"}}}`,
+    } {
+        if problems := NewLinter().Lint(writeManifest(t, dir, name, content)); len(problems) > 0 {
+            t.Errorf("Lint(%s) = %v, want no problems", name, problems)
+        }
+    }
+}
+
+func TestLintPositions(t *testing.T) {
+    content := `name: app
+version: 1.2
+description: A direct download package
+supported_os:
+  - fedora
+  - debian
+pkg_arches: [x86_64, arm64]
+arch:
+  x86_64:
+    url: https://downloads.example.com/app.x86_64.rpm
+`
+    filePath := writeManifest(t, t.TempDir(), "app.yaml", content)
+    problems := NewLinter().Lint(filePath)
+
+    tests := []struct {
+        field string
+        line int
+        column int
+    }{
+        { "version", 2, 10 },
+        { "supported_os[1]", 6, 5 },
+        // Missing fields are located at their closest present parent.
+        { "arch.arm64", 9, 3 },
+    }
+
+    for _, test := range tests {
+        problem := findProblem(problems, test.field)
+        switch {
+        case problem == nil:
+            t.Errorf("Lint() reported %v, want %s", problems, test.field)
+        case problem.File != filePath || problem.Line != test.line || problem.Column != test.column:
+            t.Errorf("Lint() located %s at %s:%d:%d, want %s:%d:%d",
+                test.field, problem.File, problem.Line, problem.Column, filePath, test.line, test.column)
+        }
+    }
+}
+
+func TestLintUnknownFields(t *testing.T) {
+    dir := t.TempDir()
+
+    tests := map[string]struct {
+        content string
+        line int
+    }{
+        "top.yaml": { "name: app\ndescription: App\npkg_arches: [x86_64]\nhomepgae: https://example.com\n", 4 },
+        "nested.yaml": { "name: app\ndescription: App\npkg_arches: [x86_64]\nrepo:\n  url_repo:\n    url: https://example.com/app.repo\n    gpg_key: https://example.com/key.asc\n", 7 },
+        "repo.yaml": { "name: app\ndescription: App\npkg_arches: [x86_64]\nrepo:\n  copr: { username: user, project: app }\n", 5 },
+    }
+
+    for name, test := range tests {
+        problems := NewLinter().Lint(writeManifest(t, dir, name, test.content))
+
+        found := slices.ContainsFunc(problems, func(problem Problem) bool {
+            return problem.Field == "" && problem.Line == test.line && strings.Contains(problem.Msg, "unknown field")
+        })
+        if !found { t.Errorf("Lint(%s) = %v, want an unknown field at line %d", name, problems, test.line) }
+    }
+}
+
+func TestLintLicense(t *testing.T) {
+    dir := t.TempDir()
+    base := "name: app\ndescription: App\npkg_arches: [x86_64]\narch: { x86_64: { url: https://example.com/app.rpm } }\nversion: \"1.0\"\n"
+
+    tests := []struct {
+        license string
+        field string
+        unknown string
+    }{
+        { "license: MIT", "", "" },
+        { "license: (MIT OR Apache-2.0) AND GPL-2.0-or-later WITH Classpath-exception-2.0", "", "" },
+        { "license: LicenseRef-Proprietary", "", "" },
+        { "license: MIT-ish", "license", "MIT-ish" },
+        { "license: MIT OR Apache-3.0", "license", "Apache-3.0" },
+        { "license: GPL-2.0-only WITH Some-exception", "license", "Some-exception" },
+        { "license:\n  identifier: Propietary\n  url: https://example.com/EULA", "license.identifier", "Propietary" },
+    }
+
+    for _, test := range tests {
+        problems := NewLinter().Lint(writeManifest(t, dir, "app.yaml", base + test.license + "\n"))
+
+        if test.field == "" {
+            if len(problems) > 0 { t.Errorf("Lint(%q) = %v, want no problems", test.license, problems) }
+            continue
+        }
+
+        problem := findProblem(problems, test.field)
+        if problem == nil || !strings.HasSuffix(problem.Msg, ": " + test.unknown) {
+            t.Errorf("Lint(%q) = %v, want %s to report %s", test.license, problems, test.field, test.unknown)
+        }
+    }
+}
+
+func TestLintUrls(t *testing.T) {
+    content := `name: app
+version: "1.0"
+description: App
+homepage: ftp://example.com
+pkg_arches: [x86_64, arm64]
+arch:
+  x86_64:
+    url: https://
+  arm64:
+    url: "https://exa mple.com/%zz"
+source:
+  gitlab:
+    instance_url: gitlab.example.com
+    project_id: group/app
+`
+    problems := NewLinter().Lint(writeManifest(t, t.TempDir(), "app.yaml", content))
+
+    tests := map[string]string {
+        "homepage": "must use http or https",
+        "arch.x86_64.url": "is missing a host",
+        "arch.arm64.url": "malformed URL",
+        "source.gitlab.instance_url": "must use http or https",
+    }
+    for field, msg := range tests {
+        if problem := findProblem(problems, field); problem == nil || !strings.Contains(problem.Msg, msg) {
+            t.Errorf("Lint() = %v, want %s to report %q", problems, field, msg)
+        }
+    }
+}
+
+func TestLintSupportedOs(t *testing.T) {
+    content := "name: app\nversion: \"1.0\"\ndescription: App\nsupported_os: [fedora, Fedora, ubuntu, opensuse-leap]\npkg_arches: [x86_64]\narch: { x86_64: { url: https://example.com/app.rpm } }\n"
+    problems := NewLinter().Lint(writeManifest(t, t.TempDir(), "app.yaml", content))
+
+    fields := []string {}
+    for _, problem := range problems { fields = append(fields, problem.Field) }
+    if want := []string { "supported_os[1]", "supported_os[2]" }; !slices.Equal(fields, want) {
+        t.Errorf("Lint() reported %v, want %v", fields, want)
+    }
+}
+
+func TestLintDuplicateNames(t *testing.T) {
+    dir := t.TempDir()
+    first := writeManifest(t, dir, "app.yaml", directManifest)
+    second := writeManifest(t, dir, "app.json",
+        `{"name":"app","version":"1.0","description":"App","pkg_arches":["x86_64"],"arch":{"x86_64":{"url":"https://example.com/app.rpm"}}}`)
+    other := writeManifest(t, dir, "other.yaml", strings.Replace(directManifest, "name: app", "name: other", 1))
+
+    linter := NewLinter()
+    if problems := linter.Lint(first); len(problems) > 0 { t.Fatalf("Lint(%s) = %v, want no problems", first, problems) }
+    if problems := linter.Lint(other); len(problems) > 0 { t.Errorf("Lint(%s) = %v, want no problems", other, problems) }
+
+    problem := findProblem(linter.Lint(second), "name")
+    if problem == nil || !strings.Contains(problem.Msg, first) || problem.Line != 1 {
+        t.Errorf("Lint(%s) = %v, want a duplicate of %s on line 1", second, problem, first)
+    }
+
+    // A new linter doesn't remember the names seen by another one.
+    if problems := NewLinter().Lint(second); len(problems) > 0 { t.Errorf("Lint(%s) = %v, want no problems", second, problems) }
+}
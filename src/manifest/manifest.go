@@ -0,0 +1,396 @@
+// Package manifest contains the schema for rpm-get package manifests,
+// as well as the helpers used to decode and validate them.
+package manifest
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+
+    // third-party imports
+    "github.com/goccy/go-json"
+    "github.com/goccy/go-yaml"
+    "github.com/samber/lo"
+)
+
+// Format is the encoding of a package manifest.
+type Format string
+
+const (
+    // YAML is the format used by manifests ending in `.yaml` or `.yml`.
+    YAML Format = "yaml"
+    // JSON is the format used by manifests ending in `.json`.
+    JSON Format = "json"
+)
+
+// Architecture keys used by `pkg_arches` and `arch`.
+const (
+    ARCH_X86_64 string = "x86_64"
+    ARCH_X86 string = "x86"
+    ARCH_ARM64 string = "arm64"
+)
+
+// ARCHES lists every architecture key a manifest may declare.
+var ARCHES = []string { ARCH_X86_64, ARCH_X86, ARCH_ARM64 }
+
+// SUPPORTED_OS lists the `ID` values from `/etc/os-release` that may appear in `supported_os`.
+var SUPPORTED_OS = []string {
+    "fedora", "rhel", "centos", "rocky", "almalinux", "ol", "nobara", "ultramarine",
+    "opensuse-leap", "opensuse-tumbleweed",
+}
+
+// LicenseObject is the object form of a package license.
+type LicenseObject struct {
+    // Package license based on SPDX license format, and license list: https://spdx.org/licenses/
+    Identifier string   `yaml:"identifier" json:"identifier"`
+    // License URL
+    Url string          `yaml:"url,omitempty" json:"url,omitempty"`
+}
+
+// License is a package license based on SPDX license format, and license list: https://spdx.org/licenses/
+// It may either be written as a plain string or as a `LicenseObject`.
+type License struct {
+    // Package license based on SPDX license format, and license list: https://spdx.org/licenses/
+    LicenseString string
+    // Package license based on SPDX license format, and license list: https://spdx.org/licenses/
+    License *LicenseObject
+}
+
+// Identifier returns the SPDX identifier of the license, whichever form it was written in.
+func (l *License) Identifier() string {
+    if l == nil { return "" }
+    if l.License != nil { return l.License.Identifier }
+    return l.LicenseString
+}
+
+// UnmarshalYAML decodes either form of a license.
+func (l *License) UnmarshalYAML(unmarshal func(any) error) error {
+    str := ""
+    if err := unmarshal(&str); err == nil {
+        l.LicenseString = str
+        return nil
+    }
+
+    obj := LicenseObject {}
+    if err := unmarshal(&obj); err != nil {
+        return fmt.Errorf("license must be a string or an object with an identifier: %w", err)
+    }
+    l.License = &obj
+
+    return nil
+}
+
+// UnmarshalJSON decodes either form of a license.
+func (l *License) UnmarshalJSON(data []byte) error {
+    str := ""
+    if err := json.Unmarshal(data, &str); err == nil {
+        l.LicenseString = str
+        return nil
+    }
+
+    obj := LicenseObject {}
+    if err := json.Unmarshal(data, &obj); err != nil {
+        return fmt.Errorf("license must be a string or an object with an identifier: %w", err)
+    }
+    l.License = &obj
+
+    return nil
+}
+
+// MarshalYAML encodes the license in the form it was written in.
+func (l License) MarshalYAML() (any, error) {
+    if l.License != nil { return l.License, nil }
+    return l.LicenseString, nil
+}
+
+// MarshalJSON encodes the license in the form it was written in.
+func (l License) MarshalJSON() ([]byte, error) {
+    if l.License != nil { return json.Marshal(l.License) }
+    return json.Marshal(l.LicenseString)
+}
+
+// Release types of a `GithubSource`.
+const (
+    // REL_TYPE_LATEST only considers stable releases.
+    REL_TYPE_LATEST string = "latest"
+    // REL_TYPE_PRERELEASE considers prereleases as well.
+    REL_TYPE_PRERELEASE string = "prerelease"
+)
+
+// PkgArch contains the architecture-specific download information.
+type PkgArch struct {
+    // Download URL for the architecture. With an `html` source, the page or "latest" URL
+    // to scrape for the architecture instead of the one of the source.
+    Url string          `yaml:"url,omitempty" json:"url,omitempty"`
+    // Regex matching the name of the RPM release asset for the architecture
+    AssetRegex string   `yaml:"asset_regex,omitempty" json:"asset_regex,omitempty"`
+    // Expected SHA-256 hash of the RPM, in hex
+    Sha256 string       `yaml:"sha256,omitempty" json:"sha256,omitempty"`
+    // Expected SHA-512 hash of the RPM, in hex
+    Sha512 string       `yaml:"sha512,omitempty" json:"sha512,omitempty"`
+}
+
+// Arch groups the `PkgArch` entries of a package by architecture.
+type Arch struct {
+    X86_64 *PkgArch   `yaml:"x86_64,omitempty" json:"x86_64,omitempty"`
+    X86 *PkgArch      `yaml:"x86,omitempty" json:"x86,omitempty"`
+    Arm64 *PkgArch    `yaml:"arm64,omitempty" json:"arm64,omitempty"`
+}
+
+// Get returns the entry for the given architecture key, or nil if it's not declared.
+func (a *Arch) Get(key string) *PkgArch {
+    switch key {
+    case ARCH_X86_64:
+        return a.X86_64
+    case ARCH_X86:
+        return a.X86
+    case ARCH_ARM64:
+        return a.Arm64
+    default:
+        return nil
+    }
+}
+
+// Set replaces the entry for the given architecture key.
+func (a *Arch) Set(key string, pkgArch *PkgArch) {
+    switch key {
+    case ARCH_X86_64:
+        a.X86_64 = pkgArch
+    case ARCH_X86:
+        a.X86 = pkgArch
+    case ARCH_ARM64:
+        a.Arm64 = pkgArch
+    }
+}
+
+// Keys returns the architecture keys that have an entry, in the order of `ARCHES`.
+func (a *Arch) Keys() []string {
+    keys := []string {}
+    for _, key := range ARCHES {
+        if a.Get(key) != nil { keys = append(keys, key) }
+    }
+    return keys
+}
+
+// ArchKey converts a Go architecture (such as `runtime.GOARCH`) to a manifest architecture key.
+// An empty string is returned for unsupported architectures.
+func ArchKey(goarch string) string {
+    switch goarch {
+    case "amd64":
+        return ARCH_X86_64
+    case "386":
+        return ARCH_X86
+    case "arm64":
+        return ARCH_ARM64
+    default:
+        return ""
+    }
+}
+
+// UrlRepo is an RPM repository described by a `.repo` file.
+type UrlRepo struct {
+    // Package repository URL
+    Url string         `yaml:"url" json:"url"`
+    // Repository GPG key URL
+    GpgKeyUrl string   `yaml:"gpg_key_url,omitempty" json:"gpg_key_url,omitempty"`
+}
+
+// CoprRepo is a Fedora COPR repository.
+type CoprRepo struct {
+    // Copr user name
+    Username string   `yaml:"username" json:"username"`
+    // Copr project name
+    Project string    `yaml:"project" json:"project"`
+}
+
+// Repo contains information about an RPM/Copr repository.
+// Only one of its fields may be set.
+type Repo struct {
+    UrlRepo *UrlRepo     `yaml:"url_repo,omitempty" json:"url_repo,omitempty"`
+    CoprRepo *CoprRepo   `yaml:"copr_repo,omitempty" json:"copr_repo,omitempty"`
+}
+
+// repoSpellings accepts the `urlRepo` and `coprRepo` spellings of the repo keys
+// next to the canonical `url_repo` and `copr_repo`.
+type repoSpellings struct {
+    UrlRepo *UrlRepo          `yaml:"url_repo" json:"url_repo"`
+    UrlRepoCamel *UrlRepo     `yaml:"urlRepo" json:"urlRepo"`
+    CoprRepo *CoprRepo        `yaml:"copr_repo" json:"copr_repo"`
+    CoprRepoCamel *CoprRepo   `yaml:"coprRepo" json:"coprRepo"`
+}
+
+// toRepo merges both spellings, refusing a key written both ways.
+func (s *repoSpellings) toRepo(r *Repo) error {
+    if s.UrlRepo != nil && s.UrlRepoCamel != nil {
+        return fmt.Errorf("repo must not set both url_repo and urlRepo")
+    }
+    if s.CoprRepo != nil && s.CoprRepoCamel != nil {
+        return fmt.Errorf("repo must not set both copr_repo and coprRepo")
+    }
+
+    r.UrlRepo = lo.Ternary(s.UrlRepo != nil, s.UrlRepo, s.UrlRepoCamel)
+    r.CoprRepo = lo.Ternary(s.CoprRepo != nil, s.CoprRepo, s.CoprRepoCamel)
+    return nil
+}
+
+// UnmarshalYAML decodes a repo written with either spelling of its keys.
+func (r *Repo) UnmarshalYAML(unmarshal func(any) error) error {
+    spellings := repoSpellings {}
+    if err := unmarshal(&spellings); err != nil { return err }
+    return spellings.toRepo(r)
+}
+
+// UnmarshalJSON decodes a repo written with either spelling of its keys.
+func (r *Repo) UnmarshalJSON(data []byte) error {
+    spellings := repoSpellings {}
+    if err := json.Unmarshal(data, &spellings); err != nil { return err }
+    return spellings.toRepo(r)
+}
+
+// GithubSource is a GitHub repository whose releases have the RPMs attached.
+type GithubSource struct {
+    // GitHub user or organization
+    Creator string        `yaml:"creator" json:"creator"`
+    // GitHub repository
+    Project string        `yaml:"project" json:"project"`
+    // Either `latest` (the default) or `prerelease`
+    RelType string        `yaml:"rel_type,omitempty" json:"rel_type,omitempty"`
+    // Regex extracting the version from the tag or title of the release
+    VersionRegex string   `yaml:"version_regex,omitempty" json:"version_regex,omitempty"`
+}
+
+// GitlabSource is a project on gitlab.com, or on a self-hosted GitLab instance,
+// whose releases link to the RPMs.
+type GitlabSource struct {
+    // URL of the GitLab instance, https://gitlab.com when it's not set
+    InstanceUrl string    `yaml:"instance_url,omitempty" json:"instance_url,omitempty"`
+    // Numeric ID or `namespace/project` path of the project
+    ProjectId string      `yaml:"project_id" json:"project_id"`
+    // Regex extracting the version from the tag or title of the release
+    VersionRegex string   `yaml:"version_regex,omitempty" json:"version_regex,omitempty"`
+}
+
+// HtmlSource is a vendor download page that links to the RPMs,
+// or a "latest" URL that redirects to the RPM.
+type HtmlSource struct {
+    // URL of the download page
+    Url string            `yaml:"url" json:"url"`
+    // XPath expression selecting the links of the page, every link when it's not set
+    Xpath string          `yaml:"xpath,omitempty" json:"xpath,omitempty"`
+    // Regex extracting the version from the RPM file names or the text of the page
+    VersionRegex string   `yaml:"version_regex" json:"version_regex"`
+}
+
+// Source describes where the newest version of a package is published.
+// Only one of its fields may be set.
+type Source struct {
+    Github *GithubSource   `yaml:"github,omitempty" json:"github,omitempty"`
+    Gitlab *GitlabSource   `yaml:"gitlab,omitempty" json:"gitlab,omitempty"`
+    Html *HtmlSource       `yaml:"html,omitempty" json:"html,omitempty"`
+}
+
+// VersionSource is a JSON or YAML document, such as the `latest.yml` of an Electron app,
+// from which the newest version of a package is extracted.
+type VersionSource struct {
+    // URL of the document
+    Url string       `yaml:"url" json:"url"`
+    // Format of the document
+    Format Format    `yaml:"format" json:"format"`
+    // JSONPath/YAMLPath expression selecting the version, e.g. `$.version`
+    Path string      `yaml:"path" json:"path"`
+    // Regex that the selected value must match
+    Regex string     `yaml:"regex,omitempty" json:"regex,omitempty"`
+    // Template built from the regex match, e.g. `$1.$2`, the first capture group when it's not set
+    Replace string   `yaml:"replace,omitempty" json:"replace,omitempty"`
+}
+
+// Pkg is the schema for package manifests.
+type Pkg struct {
+    // List of operating systems (that use RPM) supported by this package
+    SupportedOs []string   `yaml:"supported_os" json:"supported_os"`
+    // Package version, resolved from `source` or `version_source` when either is set
+    Version string         `yaml:"version" json:"version"`
+    // Package name
+    Name string            `yaml:"name" json:"name"`
+    // Package license based on SPDX license format, and license list: https://spdx.org/licenses/
+    License *License       `yaml:"license,omitempty" json:"license,omitempty"`
+    // Package homepage
+    Homepage string        `yaml:"homepage,omitempty" json:"homepage,omitempty"`
+    // Package description
+    Description string     `yaml:"description" json:"description"`
+    // Additional notes about the package
+    Notes string           `yaml:"notes,omitempty" json:"notes,omitempty"`
+    // Architectures supported by this package
+    PkgArches []string     `yaml:"pkg_arches" json:"pkg_arches"`
+    // Architecture-specific download information
+    Arch Arch              `yaml:"arch,omitempty" json:"arch,omitempty"`
+    // Information about an RPM/Copr repository
+    Repo *Repo             `yaml:"repo,omitempty" json:"repo,omitempty"`
+    // Where the newest version of the package is published
+    Source *Source         `yaml:"source,omitempty" json:"source,omitempty"`
+    // Where the newest version of the package is published, for direct download packages
+    // whose `arch` URLs contain a `{version}` placeholder
+    VersionSource *VersionSource   `yaml:"version_source,omitempty" json:"version_source,omitempty"`
+    // URL of the GPG key the RPMs of direct download packages are signed with
+    GpgKeyUrl string       `yaml:"gpg_key_url,omitempty" json:"gpg_key_url,omitempty"`
+    // List of package dependencies
+    Depends []string       `yaml:"depends,omitempty" json:"depends,omitempty"`
+    // List of recommended packages
+    Recommends []string    `yaml:"recommends,omitempty" json:"recommends,omitempty"`
+    // List of suggested packages
+    Suggests []string      `yaml:"suggests,omitempty" json:"suggests,omitempty"`
+    // List of conflicting packages
+    Conflicts []string     `yaml:"conflicts,omitempty" json:"conflicts,omitempty"`
+    // List of packages that this package replaces
+    Replaces []string      `yaml:"replaces,omitempty" json:"replaces,omitempty"`
+}
+
+// FormatOf returns the manifest format matching the extension of the given file.
+func FormatOf(filePath string) (Format, error) {
+    switch strings.ToLower(filepath.Ext(filePath)) {
+    case ".yaml", ".yml":
+        return YAML, nil
+    case ".json":
+        return JSON, nil
+    default:
+        return "", fmt.Errorf("Unknown manifest format: %s", filePath)
+    }
+}
+
+// Decode decodes a package manifest without validating it.
+func Decode(content []byte, format Format) (*Pkg, error) {
+    pkg := Pkg {}
+
+    switch format {
+    case YAML:
+        if err := yaml.Unmarshal(content, &pkg); err != nil {
+            return nil, fmt.Errorf("Failed to decode YAML manifest: %w", err)
+        }
+    case JSON:
+        if err := json.Unmarshal(content, &pkg); err != nil {
+            return nil, fmt.Errorf("Failed to decode JSON manifest: %w", err)
+        }
+    default:
+        return nil, fmt.Errorf("Unknown manifest format: %s", format)
+    }
+
+    return &pkg, nil
+}
+
+// Load reads, decodes and validates the package manifest at the given path.
+// Validation problems are returned as a `ValidationError`.
+func Load(filePath string) (*Pkg, error) {
+    format, formatErr := FormatOf(filePath)
+    if formatErr != nil { return nil, formatErr }
+
+    content, readErr := os.ReadFile(filePath)
+    if readErr != nil {
+        return nil, fmt.Errorf("Failed to read manifest: %w", readErr)
+    }
+
+    pkg, err := Decode(content, format)
+    if err != nil { return nil, err }
+
+    return pkg, pkg.Validate()
+}
@@ -0,0 +1,163 @@
+package manifest
+
+import (
+    "os"
+    "path/filepath"
+    "slices"
+    "testing"
+)
+
+const directManifest = `name: app
+version: "1.2.0"
+description: A direct download package
+license: MIT
+supported_os: [fedora, opensuse-tumbleweed]
+pkg_arches: [x86_64, arm64]
+arch:
+  x86_64:
+    url: https://downloads.example.com/app-1.2.0.x86_64.rpm
+  arm64:
+    url: https://downloads.example.com/app-1.2.0.aarch64.rpm
+`
+
+func TestDecode(t *testing.T) {
+    tests := []struct {
+        content string
+        format Format
+        license string
+        licenseUrl string
+        urlRepo string
+        coprRepo string
+    }{
+        { directManifest, YAML, "MIT", "", "", "" },
+        {
+            `{"name":"app","license":"Apache-2.0","repo":{"url_repo":{"url":"https://example.com/app.repo"}}}`,
+            JSON, "Apache-2.0", "", "https://example.com/app.repo", "",
+        },
+        {
+            "name: app\nlicense:\n  identifier: GPL-3.0-only\n  url: https://example.com/LICENSE\nrepo:\n  copr_repo: { username: user, project: app }\n",
+            YAML, "GPL-3.0-only", "https://example.com/LICENSE", "", "user/app",
+        },
+        {
+            `{"name":"app","license":{"identifier":"MPL-2.0"},"repo":{"coprRepo":{"username":"user","project":"app"}}}`,
+            JSON, "MPL-2.0", "", "", "user/app",
+        },
+        {
+            "name: app\nrepo:\n  urlRepo:\n    url: https://example.com/app.repo\n",
+            YAML, "", "", "https://example.com/app.repo", "",
+        },
+    }
+
+    for _, test := range tests {
+        pkg, err := Decode([]byte(test.content), test.format)
+        if err != nil { t.Errorf("Decode(%q) failed: %v", test.content, err); continue }
+
+        if pkg.Name != "app" { t.Errorf("Decode(%q) name = %q, want app", test.content, pkg.Name) }
+        if got := pkg.License.Identifier(); got != test.license {
+            t.Errorf("Decode(%q) license = %q, want %q", test.content, got, test.license)
+        }
+        if pkg.License != nil && pkg.License.License != nil && pkg.License.License.Url != test.licenseUrl {
+            t.Errorf("Decode(%q) license url = %q, want %q", test.content, pkg.License.License.Url, test.licenseUrl)
+        }
+
+        urlRepo, coprRepo := "", ""
+        if pkg.Repo != nil && pkg.Repo.UrlRepo != nil { urlRepo = pkg.Repo.UrlRepo.Url }
+        if pkg.Repo != nil && pkg.Repo.CoprRepo != nil {
+            coprRepo = pkg.Repo.CoprRepo.Username + "/" + pkg.Repo.CoprRepo.Project
+        }
+        if urlRepo != test.urlRepo || coprRepo != test.coprRepo {
+            t.Errorf("Decode(%q) repo = %q, %q, want %q, %q", test.content, urlRepo, coprRepo, test.urlRepo, test.coprRepo)
+        }
+    }
+
+    invalid := []struct {
+        content string
+        format Format
+    }{
+        { "name: [app", YAML },
+        { `{"name":`, JSON },
+        { "license: [MIT]", YAML },
+        { `{"license":42}`, JSON },
+        { "repo:\n  url_repo: { url: https://a.example.com }\n  urlRepo: { url: https://b.example.com }\n", YAML },
+        { `{"repo":{"copr_repo":{"username":"a"},"coprRepo":{"username":"b"}}}`, JSON },
+        { "name: app", "toml" },
+    }
+    for _, test := range invalid {
+        if _, err := Decode([]byte(test.content), test.format); err == nil {
+            t.Errorf("Decode(%q, %s) succeeded", test.content, test.format)
+        }
+    }
+}
+
+func TestLoad(t *testing.T) {
+    dir := t.TempDir()
+
+    valid := filepath.Join(dir, "app.yml")
+    if err := os.WriteFile(valid, []byte(directManifest), 0644); err != nil { t.Fatal(err) }
+    if pkg, err := Load(valid); err != nil || pkg.Version != "1.2.0" { t.Errorf("Load() = %v, %v", pkg, err) }
+
+    invalid := filepath.Join(dir, "app.json")
+    if err := os.WriteFile(invalid, []byte(`{"name":"App"}`), 0644); err != nil { t.Fatal(err) }
+    if _, err := Load(invalid); err == nil { t.Error("Load() accepted an invalid manifest") }
+
+    if _, err := Load(filepath.Join(dir, "app.toml")); err == nil { t.Error("Load() accepted an unknown format") }
+}
+
+func TestValidate(t *testing.T) {
+    pkg, err := Decode([]byte(directManifest), YAML)
+    if err != nil { t.Fatal(err) }
+    if fields := fieldErrors(t, pkg); fields != nil { t.Fatalf("Validate() reported %v, want no problems", fields) }
+
+    tests := []struct {
+        edit func(pkg *Pkg)
+        fields []string
+    }{
+        { func(pkg *Pkg) { pkg.Name = "" }, []string { "name" } },
+        { func(pkg *Pkg) { pkg.Name = "My App" }, []string { "name" } },
+        { func(pkg *Pkg) { pkg.Version = "" }, []string { "version" } },
+        { func(pkg *Pkg) { pkg.Description = "" }, []string { "description" } },
+        { func(pkg *Pkg) { pkg.License = &License { License: &LicenseObject {} } }, []string { "license.identifier" } },
+        { func(pkg *Pkg) { pkg.SupportedOs = []string { "fedora", " " } }, []string { "supported_os[1]" } },
+        { func(pkg *Pkg) { pkg.PkgArches = nil }, []string { "pkg_arches", "arch.x86_64", "arch.arm64" } },
+        { func(pkg *Pkg) { pkg.PkgArches = []string { "x86_64", "riscv64" } }, []string { "pkg_arches[1]", "arch.arm64" } },
+        { func(pkg *Pkg) { pkg.PkgArches = []string { "x86_64", "arm64", "x86_64" } }, []string { "pkg_arches[2]" } },
+        { func(pkg *Pkg) { pkg.PkgArches = append(pkg.PkgArches, "x86") }, []string { "arch.x86" } },
+        { func(pkg *Pkg) { pkg.Arch.Arm64.Url = "" }, []string { "arch.arm64.url" } },
+        { func(pkg *Pkg) { pkg.Arch.X86_64.Sha256 = "abc" }, []string { "arch.x86_64.sha256" } },
+        { func(pkg *Pkg) { pkg.Arch.X86_64.AssetRegex = "(" }, []string { "arch.x86_64.asset_regex" } },
+        {
+            func(pkg *Pkg) {
+                pkg.Repo = &Repo {
+                    UrlRepo: &UrlRepo { Url: "https://example.com/app.repo" },
+                    CoprRepo: &CoprRepo { Username: "user", Project: "app" },
+                }
+            },
+            []string { "repo" },
+        },
+        { func(pkg *Pkg) { pkg.Repo = &Repo {} }, []string { "repo" } },
+        { func(pkg *Pkg) { pkg.Repo = &Repo { UrlRepo: &UrlRepo {} } }, []string { "repo.url_repo.url" } },
+        {
+            func(pkg *Pkg) { pkg.Repo = &Repo { CoprRepo: &CoprRepo {} } },
+            []string { "repo.copr_repo.username", "repo.copr_repo.project" },
+        },
+        {
+            func(pkg *Pkg) {
+                pkg.GpgKeyUrl = "https://example.com/key.asc"
+                pkg.Repo = &Repo { CoprRepo: &CoprRepo { Username: "user", Project: "app" } }
+            },
+            []string { "gpg_key_url" },
+        },
+        { func(pkg *Pkg) { pkg.Source = &Source {} }, []string { "source" } },
+        { func(pkg *Pkg) { pkg.Source = &Source { Github: &GithubSource {} } }, []string { "source.github.creator", "source.github.project" } },
+    }
+
+    for _, test := range tests {
+        pkg, _ := Decode([]byte(directManifest), YAML)
+        test.edit(pkg)
+
+        fields := fieldErrors(t, pkg)
+        for _, field := range test.fields {
+            if !slices.Contains(fields, field) { t.Errorf("Validate() reported %v, want %s", fields, field) }
+        }
+    }
+}
@@ -0,0 +1,148 @@
+package manifest
+
+import (
+    "slices"
+    "strings"
+    "unicode"
+)
+
+// Weights of the fields of a package when searching, as a match in the name
+// is more relevant than one in the description.
+const (
+    NAME_WEIGHT int = 4
+    HOMEPAGE_WEIGHT int = 2
+    DESCRIPTION_WEIGHT int = 1
+)
+
+// Scores of the ways a search term may match a field, from the best to the worst.
+const (
+    scoreExact int = 100
+    scorePrefix int = 80
+    scoreWord int = 60
+    scoreSubstring int = 40
+    // Upper bound of the score of a typo, or of the letters of the term appearing in order
+    scoreFuzzy int = 30
+)
+
+// Score ranks how well the package matches every given search term, case-insensitively.
+// Terms are looked for in the name, homepage and description of the package, allowing typos
+// and missing letters. Zero is returned when any term doesn't match.
+func (p *Pkg) Score(terms []string) int {
+    total := 0
+    for _, term := range terms {
+        term = strings.ToLower(strings.TrimSpace(term))
+        if term == "" { continue }
+
+        best := max(
+            NAME_WEIGHT * scoreField(term, p.Name),
+            HOMEPAGE_WEIGHT * scoreField(term, strings.TrimPrefix(strings.TrimPrefix(p.Homepage, "https://"), "http://")),
+            DESCRIPTION_WEIGHT * scoreField(term, p.Description),
+        )
+        if best == 0 { return 0 }
+        total += best
+    }
+
+    return total
+}
+
+// SupportsOs reports whether the package supports the distribution with the given
+// `ID` from `/etc/os-release`.
+func (p *Pkg) SupportsOs(id string) bool {
+    return slices.ContainsFunc(p.SupportedOs, func(os string) bool { return strings.EqualFold(os, id) })
+}
+
+// SupportsArch reports whether the package is available for the given architecture, which may
+// be a manifest architecture key such as `x86_64` or a Go architecture such as `amd64`.
+func (p *Pkg) SupportsArch(arch string) bool {
+    if key := ArchKey(arch); key != "" { arch = key }
+    return slices.ContainsFunc(p.PkgArches, func(key string) bool { return strings.EqualFold(key, arch) })
+}
+
+// HasLicense reports whether the given SPDX identifier appears in the license of the package,
+// e.g. `MIT` in `MIT OR Apache-2.0`.
+func (p *Pkg) HasLicense(id string) bool {
+    ids := strings.FieldsFunc(p.License.Identifier(), func(r rune) bool {
+        return unicode.IsSpace(r) || r == '(' || r == ')'
+    })
+    return slices.ContainsFunc(ids, func(license string) bool { return strings.EqualFold(license, id) })
+}
+
+// scoreField ranks how well the given field matches the lowercase term, zero being no match.
+func scoreField(term string, field string) int {
+    field = strings.ToLower(field)
+    if field == "" { return 0 }
+
+    switch {
+    case field == term:
+        return scoreExact
+    case strings.HasPrefix(field, term):
+        return scorePrefix
+    case slices.ContainsFunc(splitWords(field), func(word string) bool { return strings.HasPrefix(word, term) }):
+        return scoreWord
+    case strings.Contains(field, term):
+        return scoreSubstring
+    }
+
+    // Short terms would match nearly anything fuzzily.
+    if len(term) < 3 { return 0 }
+
+    best := 0
+    for _, word := range splitWords(field) {
+        if distance := levenshtein(term, word); distance <= len(term) / 3 {
+            best = max(best, scoreFuzzy - distance * 5)
+        }
+    }
+    // Letters scattered over a long description would match nearly any term.
+    if span := subsequenceSpan(term, field); span > 0 && span <= 3 * len(term) {
+        // The closer the letters of the term are to each other, the better the match.
+        best = max(best, scoreFuzzy * len(term) / span)
+    }
+
+    return best
+}
+
+// splitWords splits the given text into words of letters and digits.
+func splitWords(text string) []string {
+    return strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+}
+
+// subsequenceSpan returns the length of the shortest part of `text` containing every letter of
+// `term` in order, or zero when there is none.
+func subsequenceSpan(term string, text string) int {
+    termRunes, textRunes := []rune(term), []rune(text)
+
+    best := 0
+    for start := range textRunes {
+        if textRunes[start] != termRunes[0] { continue }
+
+        matched := 0
+        end := start
+        for ; end < len(textRunes) && matched < len(termRunes); end++ {
+            if textRunes[end] == termRunes[matched] { matched++ }
+        }
+        if matched == len(termRunes) && (best == 0 || end - start < best) { best = end - start }
+    }
+
+    return best
+}
+
+// levenshtein returns the number of insertions, deletions and substitutions turning `a` into `b`.
+func levenshtein(a string, b string) int {
+    aRunes, bRunes := []rune(a), []rune(b)
+
+    previous := make([]int, len(bRunes) + 1)
+    current := make([]int, len(bRunes) + 1)
+    for j := range previous { previous[j] = j }
+
+    for i := 1; i <= len(aRunes); i++ {
+        current[0] = i
+        for j := 1; j <= len(bRunes); j++ {
+            cost := 1
+            if aRunes[i - 1] == bRunes[j - 1] { cost = 0 }
+            current[j] = min(previous[j] + 1, current[j - 1] + 1, previous[j - 1] + cost)
+        }
+        previous, current = current, previous
+    }
+
+    return previous[len(bRunes)]
+}
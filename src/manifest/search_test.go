@@ -0,0 +1,53 @@
+package manifest
+
+import (
+    "testing"
+)
+
+func TestScore(t *testing.T) {
+    firefox := &Pkg { Name: "firefox", Homepage: "https://www.mozilla.org/firefox", Description: "Web browser from Mozilla" }
+    brave := &Pkg { Name: "brave-browser", Homepage: "https://brave.com", Description: "Privacy focused web browser" }
+    code := &Pkg { Name: "code", Homepage: "https://code.visualstudio.com", Description: "Code editor by Microsoft" }
+
+    tests := []struct {
+        terms []string
+        better *Pkg
+        worse *Pkg
+    }{
+        // A match in the name ranks above one in the description.
+        { []string { "browser" }, brave, firefox },
+        { []string { "code" }, code, firefox },
+        // Typos and missing letters still match.
+        { []string { "fierfox" }, firefox, code },
+        { []string { "brvbrwsr" }, brave, code },
+    }
+
+    for _, test := range tests {
+        better, worse := test.better.Score(test.terms), test.worse.Score(test.terms)
+        if better == 0 || better <= worse {
+            t.Errorf("Score(%v) = %d for %s and %d for %s, want the first to be higher",
+                test.terms, better, test.better.Name, worse, test.worse.Name)
+        }
+    }
+
+    // Every term must match.
+    if score := firefox.Score([]string { "mozilla", "editor" }); score != 0 { t.Errorf("Score() = %d, want 0", score) }
+    if score := code.Score([]string { "xyz" }); score != 0 { t.Errorf("Score() = %d, want 0", score) }
+}
+
+func TestFilters(t *testing.T) {
+    pkg := &Pkg {
+        SupportedOs: []string { "fedora", "opensuse-tumbleweed" },
+        PkgArches: []string { ARCH_X86_64, ARCH_ARM64 },
+        License: &License { LicenseString: "(MIT OR Apache-2.0)" },
+    }
+
+    if !pkg.SupportsOs("Fedora") || pkg.SupportsOs("rhel") { t.Error("SupportsOs() is wrong") }
+    if !pkg.SupportsArch("arm64") || !pkg.SupportsArch("amd64") || pkg.SupportsArch("x86") {
+        t.Error("SupportsArch() is wrong")
+    }
+    if !pkg.HasLicense("mit") || !pkg.HasLicense("Apache-2.0") || pkg.HasLicense("GPL-3.0-only") {
+        t.Error("HasLicense() is wrong")
+    }
+    if (&Pkg {}).HasLicense("MIT") { t.Error("HasLicense() matched a package without a license") }
+}
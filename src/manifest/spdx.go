@@ -0,0 +1,95 @@
+package manifest
+
+import (
+    "strings"
+)
+
+// spdxLicenses is the set of SPDX license identifiers accepted in the `license` field.
+// See: https://spdx.org/licenses/
+var spdxLicenses = toSet([]string {
+    "0BSD", "AAL", "AFL-1.1", "AFL-1.2", "AFL-2.0", "AFL-2.1", "AFL-3.0", "AGPL-1.0-only",
+    "AGPL-1.0-or-later", "AGPL-3.0-only", "AGPL-3.0-or-later", "Apache-1.0", "Apache-1.1",
+    "Apache-2.0", "APSL-1.0", "APSL-1.1", "APSL-1.2", "APSL-2.0", "Artistic-1.0",
+    "Artistic-1.0-Perl", "Artistic-2.0", "BitTorrent-1.0", "BitTorrent-1.1", "BlueOak-1.0.0",
+    "BSD-1-Clause", "BSD-2-Clause", "BSD-2-Clause-Patent", "BSD-3-Clause",
+    "BSD-3-Clause-Clear", "BSD-4-Clause", "BSL-1.0", "BUSL-1.1", "bzip2-1.0.6", "CAL-1.0",
+    "CC-BY-1.0", "CC-BY-2.0", "CC-BY-2.5", "CC-BY-3.0", "CC-BY-4.0", "CC-BY-NC-4.0",
+    "CC-BY-NC-ND-4.0", "CC-BY-NC-SA-4.0", "CC-BY-ND-4.0", "CC-BY-SA-3.0", "CC-BY-SA-4.0",
+    "CC0-1.0", "CDDL-1.0", "CDDL-1.1", "CECILL-2.1", "CPAL-1.0", "CPL-1.0", "curl",
+    "ECL-2.0", "EFL-2.0", "Elastic-2.0", "EPL-1.0", "EPL-2.0", "EUPL-1.1", "EUPL-1.2",
+    "FSFAP", "FTL", "GFDL-1.3-only", "GFDL-1.3-or-later", "GPL-1.0-only", "GPL-1.0-or-later",
+    "GPL-2.0-only", "GPL-2.0-or-later", "GPL-3.0-only", "GPL-3.0-or-later", "HPND", "ICU",
+    "IJG", "ImageMagick", "Info-ZIP", "IPL-1.0", "ISC", "JSON", "LGPL-2.0-only",
+    "LGPL-2.0-or-later", "LGPL-2.1-only", "LGPL-2.1-or-later", "LGPL-3.0-only",
+    "LGPL-3.0-or-later", "Libpng", "libpng-2.0", "LPL-1.02", "LPPL-1.3c", "MirOS", "MIT",
+    "MIT-0", "MIT-CMU", "MPL-1.0", "MPL-1.1", "MPL-2.0", "MPL-2.0-no-copyleft-exception",
+    "MS-PL", "MS-RL", "MulanPSL-2.0", "NCSA", "Nokia", "NTP", "OFL-1.0", "OFL-1.1",
+    "OpenSSL", "OSL-1.0", "OSL-2.0", "OSL-2.1", "OSL-3.0", "PHP-3.0", "PHP-3.01",
+    "PostgreSQL", "PSF-2.0", "Python-2.0", "QPL-1.0", "Ruby", "SISSL", "Sleepycat",
+    "SSPL-1.0", "TCL", "Unicode-3.0", "Unicode-DFS-2016", "Unlicense", "UPL-1.0", "Vim",
+    "W3C", "WTFPL", "X11", "XFree86-1.1", "Zlib", "zlib-acknowledgement", "ZPL-2.0",
+    "ZPL-2.1",
+    // Deprecated identifiers that are still commonly used
+    "AGPL-3.0", "GPL-2.0", "GPL-2.0+", "GPL-3.0", "GPL-3.0+", "LGPL-2.0", "LGPL-2.1",
+    "LGPL-2.1+", "LGPL-3.0", "LGPL-3.0+",
+})
+
+// spdxExceptions is the set of SPDX exception identifiers accepted after `WITH`.
+// See: https://spdx.org/licenses/exceptions-index.html
+var spdxExceptions = toSet([]string {
+    "Autoconf-exception-2.0", "Autoconf-exception-3.0", "Bison-exception-2.2",
+    "Classpath-exception-2.0", "GCC-exception-2.0", "GCC-exception-3.1",
+    "LLVM-exception", "OpenJDK-assembly-exception-1.0", "openvpn-openssl-exception",
+    "Qt-GPL-exception-1.0", "Qt-LGPL-exception-1.1", "Swift-exception",
+})
+
+// toSet converts a list of identifiers to a case-insensitive lookup set.
+func toSet(ids []string) map[string]struct{} {
+    set := make(map[string]struct{}, len(ids))
+    for _, id := range ids { set[strings.ToLower(id)] = struct{}{} }
+    return set
+}
+
+// isSpdxLicense reports whether the given string is a known SPDX license identifier,
+// or a user defined `LicenseRef-` identifier.
+func isSpdxLicense(id string) bool {
+    if strings.HasPrefix(id, "LicenseRef-") || strings.HasPrefix(id, "DocumentRef-") {
+        return len(id) > len("LicenseRef-")
+    }
+
+    _, ok := spdxLicenses[strings.ToLower(id)]
+    if !ok && strings.HasSuffix(id, "+") {
+        _, ok = spdxLicenses[strings.ToLower(strings.TrimSuffix(id, "+"))]
+    }
+
+    return ok
+}
+
+// checkSpdxExpression returns the identifiers of an SPDX license expression
+// (such as `MIT OR Apache-2.0`) that are not known.
+func checkSpdxExpression(expr string) []string {
+    unknown := []string {}
+    fields := strings.Fields(strings.NewReplacer("(", " ", ")", " ").Replace(expr))
+    if len(fields) == 0 { return []string { expr } }
+
+    afterWith := false
+    for _, field := range fields {
+        switch field {
+        case "AND", "OR":
+            afterWith = false
+            continue
+        case "WITH":
+            afterWith = true
+            continue
+        }
+
+        if afterWith {
+            if _, ok := spdxExceptions[strings.ToLower(field)]; !ok { unknown = append(unknown, field) }
+            afterWith = false
+        } else if !isSpdxLicense(field) {
+            unknown = append(unknown, field)
+        }
+    }
+
+    return unknown
+}
@@ -0,0 +1,232 @@
+package manifest
+
+import (
+    "fmt"
+    "regexp"
+    "slices"
+    "strings"
+
+    // third-party imports
+    "github.com/antchfx/xpath"
+    "github.com/goccy/go-yaml"
+    "github.com/samber/lo"
+)
+
+// nameRegex matches valid package names.
+var nameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9._+-]*$`)
+
+// sha256Regex and sha512Regex match hex-encoded hashes.
+var (
+    sha256Regex = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+    sha512Regex = regexp.MustCompile(`^[0-9a-fA-F]{128}$`)
+)
+
+// ValidName reports whether the given string is a valid package name.
+// Valid names are also safe to use as file names.
+func ValidName(name string) bool { return nameRegex.MatchString(name) }
+
+// FieldError is a validation problem with a single manifest field.
+type FieldError struct {
+    // Path to the field, e.g. `arch.x86_64.url` or `pkg_arches[1]`
+    Field string
+    // Description of the problem
+    Msg string
+}
+
+func (e *FieldError) Error() string { return e.Field + ": " + e.Msg }
+
+// ValidationError holds every problem found in a manifest.
+type ValidationError []*FieldError
+
+func (e ValidationError) Error() string {
+    msgs := make([]string, 0, len(e))
+    for _, fieldErr := range e { msgs = append(msgs, fieldErr.Error()) }
+    return "Invalid manifest: " + strings.Join(msgs, "; ")
+}
+
+// add records a problem with the given field.
+func (e *ValidationError) add(field string, format string, args ...any) {
+    *e = append(*e, &FieldError { Field: field, Msg: fmt.Sprintf(format, args...) })
+}
+
+// Validate checks the manifest for missing or inconsistent fields.
+// It returns nil or a `ValidationError`.
+func (p *Pkg) Validate() error {
+    errs := ValidationError {}
+
+    if p.Name == "" {
+        errs.add("name", "is required")
+    } else if !nameRegex.MatchString(p.Name) {
+        errs.add("name", "%q must be lowercase and only contain letters, digits, '.', '_', '+' or '-'", p.Name)
+    }
+
+    if p.Version == "" && p.Source == nil && p.VersionSource == nil {
+        errs.add("version", "is required when no source or version_source is set")
+    }
+    if p.Description == "" { errs.add("description", "is required") }
+
+    if p.License != nil && p.License.Identifier() == "" {
+        field := "license"
+        if p.License.License != nil { field = "license.identifier" }
+        errs.add(field, "is required")
+    }
+
+    for i, name := range p.SupportedOs {
+        if strings.TrimSpace(name) == "" { errs.add(fmt.Sprintf("supported_os[%d]", i), "must not be empty") }
+    }
+
+    p.validateArches(&errs)
+    p.validateRepo(&errs)
+    p.validateSource(&errs)
+    p.validateVersionSource(&errs)
+
+    if p.GpgKeyUrl != "" && p.Repo != nil {
+        errs.add("gpg_key_url", "can't be set with repo, use repo.url_repo.gpg_key_url instead")
+    }
+
+    if len(errs) == 0 { return nil }
+    return errs
+}
+
+// validateArches checks that `pkg_arches` and `arch` agree with each other.
+func (p *Pkg) validateArches(errs *ValidationError) {
+    if len(p.PkgArches) == 0 { errs.add("pkg_arches", "must list at least one architecture") }
+
+    for i, key := range p.PkgArches {
+        field := fmt.Sprintf("pkg_arches[%d]", i)
+
+        switch {
+        case !slices.Contains(ARCHES, key):
+            errs.add(field, "unknown architecture %q, expected one of %s", key, strings.Join(ARCHES, ", "))
+        case slices.Index(p.PkgArches, key) != i:
+            errs.add(field, "duplicate architecture %q", key)
+        case p.Arch.Get(key) == nil && p.Repo == nil && p.Source == nil:
+            errs.add("arch." + key, "is required because %q is listed in pkg_arches", key)
+        }
+    }
+
+    for _, key := range p.Arch.Keys() {
+        field := "arch." + key
+
+        if !slices.Contains(p.PkgArches, key) {
+            errs.add(field, "is not listed in pkg_arches")
+        }
+        if p.Arch.Get(key).Url == "" && p.Repo == nil && p.Source == nil {
+            errs.add(field + ".url", "is required when no repo or source is set")
+        }
+        validateRegex(errs, field + ".asset_regex", p.Arch.Get(key).AssetRegex)
+        p.validateHashes(errs, field, p.Arch.Get(key))
+    }
+}
+
+// validateHashes checks the hashes of an `arch` entry.
+func (p *Pkg) validateHashes(errs *ValidationError, field string, pkgArch *PkgArch) {
+    hashes := []struct {
+        name string
+        value string
+        regex *regexp.Regexp
+    }{
+        { "sha256", pkgArch.Sha256, sha256Regex },
+        { "sha512", pkgArch.Sha512, sha512Regex },
+    }
+
+    for _, hash := range hashes {
+        switch {
+        case hash.value == "":
+            continue
+        case !hash.regex.MatchString(hash.value):
+            errs.add(field + "." + hash.name, "must be a hex-encoded %s hash", strings.ToUpper(hash.name))
+        case p.Source != nil || p.VersionSource != nil:
+            errs.add(field + "." + hash.name, "can't be set with source or version_source, the RPM changes with every version")
+        }
+    }
+}
+
+// validateRepo checks that exactly one kind of repository is described.
+func (p *Pkg) validateRepo(errs *ValidationError) {
+    if p.Repo == nil { return }
+
+    urlRepo, coprRepo := p.Repo.UrlRepo, p.Repo.CoprRepo
+
+    switch {
+    case urlRepo != nil && coprRepo != nil:
+        errs.add("repo", "must set only one of url_repo or copr_repo")
+    case urlRepo == nil && coprRepo == nil:
+        errs.add("repo", "must set one of url_repo or copr_repo")
+    }
+
+    if urlRepo != nil && urlRepo.Url == "" {
+        errs.add("repo.url_repo.url", "is required")
+    }
+
+    if coprRepo != nil {
+        if coprRepo.Username == "" { errs.add("repo.copr_repo.username", "is required") }
+        if coprRepo.Project == "" { errs.add("repo.copr_repo.project", "is required") }
+    }
+}
+
+// validateSource checks that exactly one release source is described, and that it's complete.
+func (p *Pkg) validateSource(errs *ValidationError) {
+    if p.Source == nil { return }
+
+    if p.Repo != nil { errs.add("source", "must not be set together with repo") }
+
+    github, gitlab, html := p.Source.Github, p.Source.Gitlab, p.Source.Html
+
+    switch count := lo.Count([]bool { github != nil, gitlab != nil, html != nil }, true); {
+    case count > 1:
+        errs.add("source", "must set only one of github, gitlab or html")
+    case count == 0:
+        errs.add("source", "must set one of github, gitlab or html")
+    }
+
+    if github != nil {
+        if github.Creator == "" { errs.add("source.github.creator", "is required") }
+        if github.Project == "" { errs.add("source.github.project", "is required") }
+        if github.RelType != "" && github.RelType != REL_TYPE_LATEST && github.RelType != REL_TYPE_PRERELEASE {
+            errs.add("source.github.rel_type", "must be %q or %q", REL_TYPE_LATEST, REL_TYPE_PRERELEASE)
+        }
+        validateRegex(errs, "source.github.version_regex", github.VersionRegex)
+    }
+
+    if gitlab != nil {
+        if gitlab.ProjectId == "" { errs.add("source.gitlab.project_id", "is required") }
+        validateRegex(errs, "source.gitlab.version_regex", gitlab.VersionRegex)
+    }
+
+    if html != nil {
+        if html.Url == "" { errs.add("source.html.url", "is required") }
+        if html.VersionRegex == "" { errs.add("source.html.version_regex", "is required") }
+        validateRegex(errs, "source.html.version_regex", html.VersionRegex)
+        if html.Xpath != "" {
+            if _, err := xpath.Compile(html.Xpath); err != nil { errs.add("source.html.xpath", "%s", err) }
+        }
+    }
+}
+
+// validateRegex checks that the given field, when it's set, is a valid regex.
+func validateRegex(errs *ValidationError, field string, pattern string) {
+    if pattern == "" { return }
+    if _, err := regexp.Compile(pattern); err != nil { errs.add(field, "%s", err) }
+}
+
+// validateVersionSource checks that the version source is complete.
+func (p *Pkg) validateVersionSource(errs *ValidationError) {
+    if p.VersionSource == nil { return }
+
+    if p.Repo != nil || p.Source != nil {
+        errs.add("version_source", "must not be set together with repo or source")
+    }
+
+    versionSource := p.VersionSource
+    if versionSource.Url == "" { errs.add("version_source.url", "is required") }
+    if versionSource.Format != YAML && versionSource.Format != JSON {
+        errs.add("version_source.format", "must be %q or %q", JSON, YAML)
+    }
+    if versionSource.Path == "" {
+        errs.add("version_source.path", "is required")
+    } else if _, err := yaml.PathString(versionSource.Path); err != nil {
+        errs.add("version_source.path", "%s", err)
+    }
+    validateRegex(errs, "version_source.regex", versionSource.Regex)
+}
@@ -0,0 +1,63 @@
+package manifest
+
+import (
+    "errors"
+    "slices"
+    "testing"
+)
+
+const versionSourceManifest = `name: app
+description: An Electron app
+supported_os: [fedora]
+pkg_arches: [x86_64]
+arch:
+  x86_64:
+    url: https://downloads.example.com/App-{version}.x86_64.rpm
+version_source:
+  url: https://downloads.example.com/latest-linux.yml
+  format: yaml
+  path: $.version
+`
+
+// fieldErrors returns the fields reported by `Validate`.
+func fieldErrors(t *testing.T, pkg *Pkg) []string {
+    err := pkg.Validate()
+    if err == nil { return nil }
+
+    validationErr := ValidationError (nil)
+    if !errors.As(err, &validationErr) { t.Fatalf("Validate() = %v, want a ValidationError", err) }
+
+    fields := []string {}
+    for _, fieldErr := range validationErr { fields = append(fields, fieldErr.Field) }
+    return fields
+}
+
+func TestValidateVersionSource(t *testing.T) {
+    pkg, err := Decode([]byte(versionSourceManifest), YAML)
+    if err != nil { t.Fatal(err) }
+    if fields := fieldErrors(t, pkg); fields != nil { t.Fatalf("Validate() reported %v, want no problems", fields) }
+
+    tests := []struct {
+        edit func(source *VersionSource)
+        field string
+    }{
+        { func(source *VersionSource) { source.Url = "" }, "version_source.url" },
+        { func(source *VersionSource) { source.Format = "toml" }, "version_source.format" },
+        { func(source *VersionSource) { source.Path = "" }, "version_source.path" },
+        { func(source *VersionSource) { source.Path = "version" }, "version_source.path" },
+        { func(source *VersionSource) { source.Regex = "v(" }, "version_source.regex" },
+    }
+
+    for _, test := range tests {
+        pkg, _ := Decode([]byte(versionSourceManifest), YAML)
+        test.edit(pkg.VersionSource)
+        if fields := fieldErrors(t, pkg); !slices.Contains(fields, test.field) {
+            t.Errorf("Validate() reported %v, want %s", fields, test.field)
+        }
+    }
+
+    pkg.Repo = &Repo { CoprRepo: &CoprRepo { Username: "user", Project: "app" } }
+    if fields := fieldErrors(t, pkg); !slices.Contains(fields, "version_source") {
+        t.Errorf("Validate() reported %v, want version_source to conflict with repo", fields)
+    }
+}
@@ -0,0 +1,150 @@
+package manifest
+
+import (
+    "strconv"
+    "strings"
+
+    // third-party imports
+    "github.com/samber/lo"
+)
+
+// Evr is an RPM `epoch:version-release`.
+type Evr struct {
+    // Epoch, 0 when it's not set
+    Epoch int
+    Version string
+    // Release, empty when it's not set
+    Release string
+}
+
+// ParseEvr parses an `[epoch:]version[-release]` string, such as a manifest `version`
+// or the output of `rpm -q --qf '%{EPOCHNUM}:%{VERSION}-%{RELEASE}'`.
+func ParseEvr(evr string) Evr {
+    result := Evr {}
+
+    if epoch, rest, ok := strings.Cut(evr, ":"); ok {
+        if num, err := strconv.Atoi(epoch); err == nil {
+            result.Epoch = num
+            evr = rest
+        }
+    }
+
+    result.Version = evr
+    if i := strings.LastIndex(evr, "-"); i >= 0 {
+        result.Version, result.Release = evr[:i], evr[i + 1:]
+    }
+
+    return result
+}
+
+func (e Evr) String() string {
+    result := e.Version
+    if e.Epoch != 0 { result = strconv.Itoa(e.Epoch) + ":" + result }
+    if e.Release != "" { result += "-" + e.Release }
+    return result
+}
+
+// Compare compares two EVRs the way rpm does, returning -1, 0 or 1.
+// The releases are only compared when both EVRs have one, so that a manifest
+// `version` without a release matches every release of that version.
+func (e Evr) Compare(other Evr) int {
+    switch {
+    case e.Epoch < other.Epoch:
+        return -1
+    case e.Epoch > other.Epoch:
+        return 1
+    }
+
+    if result := Vercmp(e.Version, other.Version); result != 0 { return result }
+    if e.Release == "" || other.Release == "" { return 0 }
+    return Vercmp(e.Release, other.Release)
+}
+
+// CompareEvr parses and compares two `[epoch:]version[-release]` strings, returning -1, 0 or 1.
+func CompareEvr(a string, b string) int { return ParseEvr(a).Compare(ParseEvr(b)) }
+
+// Vercmp compares two version (or release) strings, returning -1, 0 or 1.
+// It is a port of `rpmvercmp()` from rpm, including the `~` (sorts before anything)
+// and `^` (sorts after the base version, but before anything else) separators.
+func Vercmp(a string, b string) int {
+    if a == b { return 0 }
+
+    one, two := a, b
+    for len(one) > 0 || len(two) > 0 {
+        one = strings.TrimLeftFunc(one, isSeparator)
+        two = strings.TrimLeftFunc(two, isSeparator)
+
+        // Handle the tilde separator, it sorts before everything else.
+        if strings.HasPrefix(one, "~") || strings.HasPrefix(two, "~") {
+            if !strings.HasPrefix(one, "~") { return 1 }
+            if !strings.HasPrefix(two, "~") { return -1 }
+            one, two = one[1:], two[1:]
+            continue
+        }
+
+        // Handle the caret separator. It sorts after the end of a version,
+        // but before anything else.
+        if strings.HasPrefix(one, "^") || strings.HasPrefix(two, "^") {
+            if one == "" { return -1 }
+            if two == "" { return 1 }
+            if !strings.HasPrefix(one, "^") { return 1 }
+            if !strings.HasPrefix(two, "^") { return -1 }
+            one, two = one[1:], two[1:]
+            continue
+        }
+
+        // If we ran to the end of either, we are finished with the loop.
+        if one == "" || two == "" { break }
+
+        // Grab the first completely alpha or completely numeric segment of each string.
+        isNum := isDigit(one[0])
+        segFunc := lo.Ternary(isNum, isDigit, isAlpha)
+        seg1, seg2 := leadingSegment(one, segFunc), leadingSegment(two, segFunc)
+        one, two = one[len(seg1):], two[len(seg2):]
+
+        // This cannot happen, as we previously tested to make sure that
+        // the first string has a non-null segment.
+        if seg1 == "" { return -1 }
+
+        // Take care of the case where the two version segments are different types:
+        // one numeric, the other alpha (i.e. empty). Numeric segments are always newer.
+        if seg2 == "" { return lo.Ternary(isNum, 1, -1) }
+
+        if isNum {
+            // Throw away any leading zeros, whichever number has more digits wins.
+            seg1, seg2 = strings.TrimLeft(seg1, "0"), strings.TrimLeft(seg2, "0")
+            if len(seg1) > len(seg2) { return 1 }
+            if len(seg2) > len(seg1) { return -1 }
+        }
+
+        // Both segments are now either numbers of the same length, or letters.
+        if result := strings.Compare(seg1, seg2); result != 0 { return result }
+    }
+
+    // Whichever version still has characters left over wins.
+    switch {
+    case one == "" && two == "":
+        return 0
+    case one == "":
+        return -1
+    default:
+        return 1
+    }
+}
+
+// leadingSegment returns the longest prefix of `s` whose bytes all match `match`.
+func leadingSegment(s string, match func(byte) bool) string {
+    i := 0
+    for i < len(s) && match(s[i]) { i++ }
+    return s[:i]
+}
+
+// isSeparator reports whether the rune is skipped between version segments.
+// Like rpm, only ASCII letters and digits are significant.
+func isSeparator(r rune) bool {
+    return r != '~' && r != '^' && (r > 127 || !(isDigit(byte(r)) || isAlpha(byte(r))))
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isAlpha(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
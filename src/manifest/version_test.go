@@ -0,0 +1,197 @@
+package manifest
+
+import (
+    "testing"
+)
+
+// Test cases from rpm's own test suite (tests/rpmvercmp.at).
+var vercmpTests = []struct {
+    a string
+    b string
+    want int
+}{
+    { "1.0", "1.0", 0 },
+    { "1.0", "2.0", -1 },
+    { "2.0", "1.0", 1 },
+
+    { "2.0.1", "2.0.1", 0 },
+    { "2.0", "2.0.1", -1 },
+    { "2.0.1", "2.0", 1 },
+
+    { "2.0.1a", "2.0.1a", 0 },
+    { "2.0.1a", "2.0.1", 1 },
+    { "2.0.1", "2.0.1a", -1 },
+
+    { "5.5p1", "5.5p1", 0 },
+    { "5.5p1", "5.5p2", -1 },
+    { "5.5p2", "5.5p1", 1 },
+
+    { "5.5p10", "5.5p10", 0 },
+    { "5.5p1", "5.5p10", -1 },
+    { "5.5p10", "5.5p1", 1 },
+
+    { "10xyz", "10.1xyz", -1 },
+    { "10.1xyz", "10xyz", 1 },
+
+    { "xyz10", "xyz10", 0 },
+    { "xyz10", "xyz10.1", -1 },
+    { "xyz10.1", "xyz10", 1 },
+
+    { "xyz.4", "xyz.4", 0 },
+    { "xyz.4", "8", -1 },
+    { "8", "xyz.4", 1 },
+    { "xyz.4", "2", -1 },
+    { "2", "xyz.4", 1 },
+
+    { "5.5p2", "5.6p1", -1 },
+    { "5.6p1", "5.5p2", 1 },
+
+    { "5.6p1", "6.5p1", -1 },
+    { "6.5p1", "5.6p1", 1 },
+
+    { "6.0.rc1", "6.0", 1 },
+    { "6.0", "6.0.rc1", -1 },
+
+    { "10b2", "10a1", 1 },
+    { "10a2", "10b2", -1 },
+
+    { "1.0aa", "1.0aa", 0 },
+    { "1.0a", "1.0aa", -1 },
+    { "1.0aa", "1.0a", 1 },
+
+    { "10.0001", "10.0001", 0 },
+    { "10.0001", "10.1", 0 },
+    { "10.1", "10.0001", 0 },
+    { "10.0001", "10.0039", -1 },
+    { "10.0039", "10.0001", 1 },
+
+    { "4.999.9", "5.0", -1 },
+    { "5.0", "4.999.9", 1 },
+
+    { "20101121", "20101121", 0 },
+    { "20101121", "20101122", -1 },
+    { "20101122", "20101121", 1 },
+
+    { "2_0", "2_0", 0 },
+    { "2.0", "2_0", 0 },
+    { "2_0", "2.0", 0 },
+
+    // RhBug:178798 case
+    { "a", "a", 0 },
+    { "a+", "a+", 0 },
+    { "a+", "a_", 0 },
+    { "a_", "a+", 0 },
+    { "+a", "+a", 0 },
+    { "+a", "_a", 0 },
+    { "_a", "+a", 0 },
+    { "+_", "+_", 0 },
+    { "_+", "+_", 0 },
+    { "_+", "_", 0 },
+    { "+", "_", 0 },
+    { "_", "+", 0 },
+
+    // Basic testcases for tilde sorting
+    { "1.0~rc1", "1.0~rc1", 0 },
+    { "1.0~rc1", "1.0", -1 },
+    { "1.0", "1.0~rc1", 1 },
+    { "1.0~rc1", "1.0~rc2", -1 },
+    { "1.0~rc2", "1.0~rc1", 1 },
+    { "1.0~rc1~git123", "1.0~rc1~git123", 0 },
+    { "1.0~rc1~git123", "1.0~rc1", -1 },
+    { "1.0~rc1", "1.0~rc1~git123", 1 },
+
+    // Basic testcases for caret sorting
+    { "1.0^", "1.0^", 0 },
+    { "1.0^", "1.0", 1 },
+    { "1.0", "1.0^", -1 },
+    { "1.0^git1", "1.0^git1", 0 },
+    { "1.0^git1", "1.0", 1 },
+    { "1.0", "1.0^git1", -1 },
+    { "1.0^git1", "1.0^git2", -1 },
+    { "1.0^git2", "1.0^git1", 1 },
+    { "1.0^git1", "1.01", -1 },
+    { "1.01", "1.0^git1", 1 },
+    { "1.0^20160101", "1.0^20160101", 0 },
+    { "1.0^20160101", "1.0.1", -1 },
+    { "1.0.1", "1.0^20160101", 1 },
+    { "1.0^20160101^git1", "1.0^20160101^git1", 0 },
+    { "1.0^20160102", "1.0^20160101^git1", 1 },
+    { "1.0^20160101^git1", "1.0^20160102", -1 },
+
+    // Basic testcases for tilde and caret sorting
+    { "1.0~rc1^git1", "1.0~rc1^git1", 0 },
+    { "1.0~rc1^git1", "1.0~rc1", 1 },
+    { "1.0~rc1", "1.0~rc1^git1", -1 },
+    { "1.0^git1~pre", "1.0^git1~pre", 0 },
+    { "1.0^git1", "1.0^git1~pre", 1 },
+    { "1.0^git1~pre", "1.0^git1", -1 },
+
+    // Arguably buggy behaviors, documented by rpm to catch unintended changes
+    { "1b.fc17", "1b.fc17", 0 },
+    { "1b.fc17", "1.fc17", -1 },
+    { "1.fc17", "1b.fc17", 1 },
+    { "1g.fc17", "1g.fc17", 0 },
+    { "1g.fc17", "1.fc17", 1 },
+    { "1.fc17", "1g.fc17", -1 },
+
+    // Non-ASCII characters are considered equal
+    { "1.1.α", "1.1.α", 0 },
+    { "1.1.α", "1.1.β", 0 },
+    { "1.1.β", "1.1.α", 0 },
+    { "1.1.αα", "1.1.α", 0 },
+    { "1.1.α", "1.1.ββ", 0 },
+    { "1.1.ββ", "1.1.αα", 0 },
+}
+
+func TestVercmp(t *testing.T) {
+    for _, test := range vercmpTests {
+        if got := Vercmp(test.a, test.b); got != test.want {
+            t.Errorf("Vercmp(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+        }
+    }
+}
+
+func TestParseEvr(t *testing.T) {
+    tests := []struct {
+        evr string
+        want Evr
+    }{
+        { "1.0", Evr { Version: "1.0" } },
+        { "1.0-1.fc40", Evr { Version: "1.0", Release: "1.fc40" } },
+        { "0:1.0-1.fc40", Evr { Version: "1.0", Release: "1.fc40" } },
+        { "2:1.0", Evr { Epoch: 2, Version: "1.0" } },
+        { "3:1.2.3-4", Evr { Epoch: 3, Version: "1.2.3", Release: "4" } },
+        { "1.0-beta-2", Evr { Version: "1.0-beta", Release: "2" } },
+        { "abc:1.0", Evr { Version: "abc:1.0" } },
+    }
+
+    for _, test := range tests {
+        if got := ParseEvr(test.evr); got != test.want {
+            t.Errorf("ParseEvr(%q) = %+v, want %+v", test.evr, got, test.want)
+        }
+    }
+}
+
+func TestCompareEvr(t *testing.T) {
+    tests := []struct {
+        a string
+        b string
+        want int
+    }{
+        { "1.0", "1.0", 0 },
+        { "1.0", "0:1.0-1.fc40", 0 },
+        { "1.1", "0:1.0-1.fc40", 1 },
+        { "1.0-2", "0:1.0-1.fc40", 1 },
+        { "1.0-1.fc39", "0:1.0-1.fc40", -1 },
+        { "1:0.1", "0:9.9-9", 1 },
+        { "0.1", "1:0.1-1", -1 },
+        { "1.0~beta1", "0:1.0-1", -1 },
+        { "1.0^20250101git1", "0:1.0-1", 1 },
+    }
+
+    for _, test := range tests {
+        if got := CompareEvr(test.a, test.b); got != test.want {
+            t.Errorf("CompareEvr(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+        }
+    }
+}
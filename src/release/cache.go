@@ -0,0 +1,240 @@
+package release
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "os"
+    "path/filepath"
+    "slices"
+    "strconv"
+    "sync"
+    "time"
+
+    "github.com/FlawlessCasual17/rpm-get/httpclient"
+
+    // third-party imports
+    "github.com/goccy/go-json"
+)
+
+// DEFAULT_CACHE_TTL is how long a cached API response is used without asking the API again.
+const DEFAULT_CACHE_TTL time.Duration = 15 * time.Minute
+
+// RATE_LIMITS_NAME is the name of the file, inside the cache directory, storing until when
+// each API host is rate limited.
+const RATE_LIMITS_NAME string = "rate-limits.json"
+
+// RateLimitError is returned when the rate limit of an API is exhausted.
+type RateLimitError struct {
+    Host string
+    // When the rate limit resets, zero when it's unknown
+    Reset time.Time
+}
+
+func (e *RateLimitError) Error() string {
+    msg := "The API rate limit of " + e.Host + " is exhausted"
+    if !e.Reset.IsZero() { msg += ", it resets at " + e.Reset.Local().Format("15:04") }
+    return msg
+}
+
+// Cache stores the responses of release APIs on disk. Responses are reused for `TTL`, then
+// revalidated with `If-None-Match`, which doesn't count against the GitHub rate limit.
+// Expired responses are used when the API is rate limited or unreachable.
+// Failing to write to the cache is not an error, as it's only writable by root.
+type Cache struct {
+    Dir string
+    TTL time.Duration
+    // OnStale is called when an expired response is used because the API failed with `err`.
+    OnStale func(rawUrl string, fetchedAt time.Time, err error)
+
+    mutex sync.Mutex
+}
+
+// cacheEntry is a cached API response.
+type cacheEntry struct {
+    Url string             `json:"url"`
+    ETag string            `json:"etag,omitempty"`
+    FetchedAt time.Time    `json:"fetched_at"`
+    Body json.RawMessage   `json:"body"`
+}
+
+// NewCache returns a cache of API responses in the given directory.
+func NewCache(dir string) *Cache { return &Cache { Dir: dir, TTL: DEFAULT_CACHE_TTL } }
+
+// entryPath returns the file caching the response of `rawUrl`. Responses fetched with different
+// credentials are cached separately, as they may not see the same releases.
+func (c *Cache) entryPath(rawUrl string, headers map[string]string) string {
+    hash := sha256.Sum256([]byte(rawUrl + "\n" + headers["Authorization"] + headers["PRIVATE-TOKEN"]))
+    return filepath.Join(c.Dir, hex.EncodeToString(hash[:]) + ".json")
+}
+
+// load returns the cached response of `rawUrl`, or nil when it's not cached.
+func (c *Cache) load(rawUrl string, headers map[string]string) *cacheEntry {
+    if c == nil { return nil }
+
+    content, err := os.ReadFile(c.entryPath(rawUrl, headers))
+    if err != nil { return nil }
+
+    entry := &cacheEntry {}
+    if err := json.Unmarshal(content, entry); err != nil || entry.Url != rawUrl { return nil }
+    return entry
+}
+
+// fresh reports whether the given entry can be used without asking the API again.
+func (c *Cache) fresh(entry *cacheEntry) bool {
+    return c != nil && entry != nil && time.Since(entry.FetchedAt) < c.TTL
+}
+
+// store caches the given entry. Cached responses may come from private projects,
+// so they're only readable by their owner.
+func (c *Cache) store(entry *cacheEntry, headers map[string]string) {
+    if c == nil { return }
+
+    content, err := json.Marshal(entry)
+    if err != nil { return }
+    _ = writeCacheFile(c.entryPath(entry.Url, headers), content)
+}
+
+// stale reports the use of an expired entry.
+func (c *Cache) stale(entry *cacheEntry, err error) {
+    if c != nil && c.OnStale != nil { c.OnStale(entry.Url, entry.FetchedAt, err) }
+}
+
+// rateLimit returns a `RateLimitError` when the API at `rawUrl` is known to be rate limited.
+func (c *Cache) rateLimit(rawUrl string) error {
+    if c == nil { return nil }
+
+    host := hostOf(rawUrl)
+    reset, ok := c.readRateLimits()[host]
+    if !ok || time.Now().After(reset) { return nil }
+
+    return &RateLimitError { Host: host, Reset: reset }
+}
+
+// updateRateLimit records until when the API is rate limited, from the rate limit headers
+// of GitHub (`X-RateLimit-*`) or GitLab (`RateLimit-*`). A `RateLimitError` is returned
+// when the response was refused because of the rate limit.
+func (c *Cache) updateRateLimit(resp *http.Response) error {
+    remaining := headerValue(resp.Header, "X-RateLimit-Remaining", "RateLimit-Remaining")
+    if remaining != "0" { return nil }
+
+    host := resp.Request.URL.Host
+    reset := time.Time {}
+    resetHeader := headerValue(resp.Header, "X-RateLimit-Reset", "RateLimit-Reset")
+    if seconds, err := strconv.ParseInt(resetHeader, 10, 64); err == nil { reset = time.Unix(seconds, 0) }
+
+    if c != nil && !reset.IsZero() {
+        c.mutex.Lock()
+        rateLimits := c.readRateLimits()
+        rateLimits[host] = reset
+        if content, err := json.Marshal(rateLimits); err == nil {
+            _ = writeCacheFile(filepath.Join(c.Dir, RATE_LIMITS_NAME), content)
+        }
+        c.mutex.Unlock()
+    }
+
+    refused := resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests
+    if !refused { return nil }
+    return &RateLimitError { Host: host, Reset: reset }
+}
+
+// readRateLimits returns until when each API host is rate limited.
+func (c *Cache) readRateLimits() map[string]time.Time {
+    result := map[string]time.Time {}
+
+    content, err := os.ReadFile(filepath.Join(c.Dir, RATE_LIMITS_NAME))
+    if err != nil { return result }
+    if err := json.Unmarshal(content, &result); err != nil { return map[string]time.Time {} }
+
+    return result
+}
+
+// usableWhenStale reports whether an expired response may be used after `err`, which is
+// the case when the API is rate limited, unreachable or failing, but not when it's gone.
+func usableWhenStale(err error) bool {
+    rateLimitErr := &RateLimitError {}
+    statusErr := &httpclient.StatusError {}
+
+    switch {
+    case errors.As(err, &rateLimitErr):
+        return true
+    case errors.As(err, &statusErr):
+        return statusErr.StatusCode >= 500
+    default:
+        return true
+    }
+}
+
+// writeCacheFile atomically writes a file of the cache, creating its directory.
+func writeCacheFile(filePath string, content []byte) error {
+    if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil { return err }
+
+    tmpFile, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath) + ".*.tmp")
+    if err != nil { return err }
+
+    _, writeErr := tmpFile.Write(content)
+    if err := errors.Join(writeErr, tmpFile.Close()); err != nil {
+        _ = os.Remove(tmpFile.Name())
+        return fmt.Errorf("Failed to write %s: %w", filePath, err)
+    }
+    if err := os.Rename(tmpFile.Name(), filePath); err != nil {
+        _ = os.Remove(tmpFile.Name())
+        return fmt.Errorf("Failed to write %s: %w", filePath, err)
+    }
+
+    return nil
+}
+
+// headerValue returns the value of the first of the given headers that is set.
+func headerValue(header http.Header, keys ...string) string {
+    for _, key := range keys {
+        if value := header.Get(key); value != "" { return value }
+    }
+    return ""
+}
+
+// hostOf returns the host of the given URL.
+func hostOf(rawUrl string) string {
+    u, err := url.Parse(rawUrl)
+    if err != nil { return rawUrl }
+    return u.Host
+}
+
+// CachedResponse describes a response in the cache.
+type CachedResponse struct {
+    Url string
+    FetchedAt time.Time
+    // Path of the cache file
+    Path string
+    // Size of the cache file
+    Size int64
+}
+
+// List returns the responses in the cache, oldest first.
+func (c *Cache) List() ([]CachedResponse, error) {
+    entries, err := os.ReadDir(c.Dir)
+    if errors.Is(err, os.ErrNotExist) { return nil, nil }
+    if err != nil { return nil, fmt.Errorf("Failed to read the API cache: %w", err) }
+
+    result := []CachedResponse {}
+    for _, dirEntry := range entries {
+        name := dirEntry.Name()
+        if dirEntry.IsDir() || name == RATE_LIMITS_NAME || filepath.Ext(name) != ".json" { continue }
+
+        filePath := filepath.Join(c.Dir, name)
+        content, readErr := os.ReadFile(filePath)
+        if readErr != nil { continue }
+
+        entry := &cacheEntry {}
+        if err := json.Unmarshal(content, entry); err != nil { continue }
+        result = append(result, CachedResponse {
+            Url: entry.Url, FetchedAt: entry.FetchedAt, Path: filePath, Size: int64(len(content)),
+        })
+    }
+
+    slices.SortFunc(result, func(a CachedResponse, b CachedResponse) int { return a.FetchedAt.Compare(b.FetchedAt) })
+    return result, nil
+}
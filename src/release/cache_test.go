@@ -0,0 +1,92 @@
+package release
+
+import (
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "strconv"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+// newRateLimitedServer returns a fake GitHub API serving `githubReleaseJson` with an ETag,
+// which refuses every request once `limited` is set.
+func newRateLimitedServer(t *testing.T, limited *atomic.Bool) (*httptest.Server, *atomic.Int32) {
+    requests := &atomic.Int32 {}
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        requests.Add(1)
+        if limited.Load() {
+            w.Header().Set("X-RateLimit-Remaining", "0")
+            w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+            http.Error(w, `{"message": "API rate limit exceeded"}`, http.StatusForbidden)
+            return
+        }
+
+        w.Header().Set("ETag", `"v1"`)
+        if r.Header.Get("If-None-Match") == `"v1"` {
+            w.WriteHeader(http.StatusNotModified)
+            return
+        }
+        _, _ = w.Write([]byte(githubReleaseJson))
+    }))
+
+    t.Cleanup(server.Close)
+    return server, requests
+}
+
+func TestCache(t *testing.T) {
+    limited := &atomic.Bool {}
+    server, requests := newRateLimitedServer(t, limited)
+
+    stale := 0
+    cache := NewCache(t.TempDir())
+    cache.OnStale = func(string, time.Time, error) { stale++ }
+    github := &Github { BaseUrl: server.URL, Client: server.Client(), Cache: cache }
+
+    latest := func() {
+        t.Helper()
+        rel, err := github.Latest("bitwarden", "clients", false, "")
+        if err != nil { t.Fatal(err) }
+        if rel.Version != "2025.4.2" { t.Errorf("Latest() = %q, want 2025.4.2", rel.Version) }
+    }
+
+    // Fresh responses are reused without asking the API.
+    latest()
+    latest()
+    if requests.Load() != 1 { t.Errorf("got %d requests, want 1", requests.Load()) }
+
+    // Expired responses are revalidated.
+    cache.TTL = 0
+    latest()
+    if requests.Load() != 2 { t.Errorf("got %d requests, want 2", requests.Load()) }
+
+    // Expired responses are used while the rate limit is exhausted, without asking the API again.
+    limited.Store(true)
+    latest()
+    latest()
+    if requests.Load() != 3 || stale != 2 {
+        t.Errorf("got %d requests and %d stale responses, want 3 and 2", requests.Load(), stale)
+    }
+
+    // The rate limits file is not a response.
+    responses, err := cache.List()
+    if err != nil { t.Fatal(err) }
+    if len(responses) != 1 || responses[0].Url != server.URL + "/repos/bitwarden/clients/releases/latest" {
+        t.Errorf("List() = %+v, want the latest release response", responses)
+    }
+}
+
+func TestCacheRateLimited(t *testing.T) {
+    limited := &atomic.Bool {}
+    limited.Store(true)
+    server, _ := newRateLimitedServer(t, limited)
+
+    github := &Github { BaseUrl: server.URL, Client: server.Client(), Cache: NewCache(t.TempDir()) }
+    _, err := github.Latest("bitwarden", "clients", false, "")
+
+    rateLimitErr := &RateLimitError {}
+    if !errors.As(err, &rateLimitErr) || rateLimitErr.Reset.IsZero() {
+        t.Errorf("Latest() = %v, want a RateLimitError with its reset time", err)
+    }
+}
@@ -0,0 +1,95 @@
+package release
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "net/http"
+    "regexp"
+
+    "github.com/FlawlessCasual17/rpm-get/httpclient"
+
+    // third-party imports
+    "github.com/goccy/go-json"
+    "github.com/goccy/go-yaml"
+    "github.com/samber/lo"
+)
+
+// Formats of the documents a version can be extracted from.
+const (
+    FORMAT_JSON string = "json"
+    FORMAT_YAML string = "yaml"
+)
+
+// Document resolves versions from JSON or YAML documents published by vendors,
+// such as the `latest.json` or `latest.yml` files of Electron apps.
+type Document struct {
+    UserAgent string
+    // HTTP client used for requests, `http.DefaultClient` when nil
+    Client *http.Client
+}
+
+// NewDocument returns a resolver for JSON and YAML documents.
+func NewDocument() *Document { return &Document {} }
+
+// Version fetches the document at `docUrl` and extracts the version from it with `ParseVersion`.
+func (d *Document) Version(docUrl string, format string, path string, regex string, replace string) (string, error) {
+    client := d.Client
+    if client == nil { client = http.DefaultClient }
+
+    request, reqErr := http.NewRequest("GET", docUrl, nil)
+    if reqErr != nil { return "", fmt.Errorf("Invalid request: %w", reqErr) }
+    if d.UserAgent != "" { request.Header.Set("User-Agent", d.UserAgent) }
+
+    resp, respErr := client.Do(request)
+    if respErr != nil { return "", fmt.Errorf("Request failed: %w", respErr) }
+    //nolint:errcheck
+    defer resp.Body.Close()
+
+    if err := httpclient.CheckStatus(resp); err != nil { return "", err }
+
+    content, readErr := io.ReadAll(resp.Body)
+    if readErr != nil { return "", fmt.Errorf("Failed to read %s: %w", docUrl, readErr) }
+
+    return ParseVersion(content, format, path, regex, replace)
+}
+
+// ParseVersion selects a string from a JSON or YAML document with a path expression, such as
+// `$.version` or `$.releases[0].name`. When `regex` is set, the selected string must match it,
+// and the version is the `replace` template (e.g. `$1.$2`) expanded with the first match,
+// or the first capture group of that match when `replace` is empty.
+func ParseVersion(content []byte, format string, path string, regex string, replace string) (string, error) {
+    data := any (nil)
+
+    switch format {
+    case FORMAT_JSON:
+        jsonPath, pathErr := json.CreatePath(path)
+        if pathErr != nil { return "", fmt.Errorf("Failed to parse JSONPath: %w", pathErr) }
+        if err := jsonPath.Unmarshal(content, &data); err != nil {
+            return "", fmt.Errorf("Failed to parse JSON with JSONPath: %w", err)
+        }
+    case FORMAT_YAML:
+        yamlPath, pathErr := yaml.PathString(path)
+        if pathErr != nil { return "", fmt.Errorf("Failed to parse YAMLPath: %w", pathErr) }
+        if err := yamlPath.Read(bytes.NewReader(content), &data); err != nil {
+            return "", fmt.Errorf("Failed to parse YAML with YAMLPath: %w", err)
+        }
+    default:
+        return "", fmt.Errorf("Unknown document format: %s", format)
+    }
+
+    // A JSONPath may select several values, of which only the first is used.
+    if values, ok := data.([]any); ok && len(values) > 0 { data = values[0] }
+
+    value, ok := data.(string)
+    if !ok { return "", fmt.Errorf("%s did not select a string value: %T, expected string", path, data) }
+    if regex == "" { return value, nil }
+
+    compiled, regexErr := regexp.Compile(regex)
+    if regexErr != nil { return "", fmt.Errorf("Failed to parse regex: %w", regexErr) }
+    match := compiled.FindStringSubmatchIndex(value)
+    if match == nil { return "", fmt.Errorf("%q does not match %q", value, regex) }
+
+    if replace == "" { replace = lo.Ternary(compiled.NumSubexp() > 0, "${1}", "${0}") }
+    return string(compiled.ExpandString(nil, replace, value, match)), nil
+}
@@ -0,0 +1,92 @@
+package release
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+const electronLatestYml = `version: 1.34.2
+files:
+  - url: App-1.34.2.x86_64.rpm
+    sha512: c2hhNTEy
+    size: 98765432
+path: App-1.34.2.x86_64.rpm
+releaseDate: '2025-05-01T16:37:19.000Z'
+`
+
+const githubReleasesDocument = `[
+    { "tag_name": "desktop-mac-v2025.4.2", "name": "Desktop v2025.4.2", "prerelease": false },
+    { "tag_name": "desktop-v2025.4.1", "name": "Desktop v2025.4.1", "prerelease": false }
+]`
+
+func TestParseVersion(t *testing.T) {
+    tests := []struct {
+        content string
+        format string
+        path string
+        regex string
+        replace string
+        want string
+    }{
+        { githubReleasesDocument, FORMAT_JSON, "$[0].name", `Desktop v([\d.]+)`, "", "2025.4.2" },
+        { githubReleasesDocument, FORMAT_JSON, "$[1].tag_name", `^.*-v(\d+)\.(\d+)\.(\d+)$`, "$1.$2~$3", "2025.4~1" },
+        { `{ "stable": { "version": "4.17.0" } }`, FORMAT_JSON, "$.stable.version", "", "", "4.17.0" },
+        { electronLatestYml, FORMAT_YAML, "$.version", "", "", "1.34.2" },
+        { electronLatestYml, FORMAT_YAML, "$.files[0].url", `^App-([\d.]+)\.`, "", "1.34.2" },
+        // JSON documents can be read as YAML as well.
+        { githubReleasesDocument, FORMAT_YAML, "$[1].name", `v([\d.]+)`, "", "2025.4.1" },
+    }
+
+    for _, test := range tests {
+        got, err := ParseVersion([]byte(test.content), test.format, test.path, test.regex, test.replace)
+        if err != nil { t.Errorf("ParseVersion(%s, %s): %v", test.format, test.path, err); continue }
+        if got != test.want { t.Errorf("ParseVersion(%s, %s) = %q, want %q", test.format, test.path, got, test.want) }
+    }
+}
+
+func TestParseVersionErrors(t *testing.T) {
+    tests := []struct {
+        content string
+        format string
+        path string
+        regex string
+    }{
+        // Not a string
+        { electronLatestYml, FORMAT_YAML, "$.files[0].size", "" },
+        // Missing field
+        { `{ "version": "1.0" }`, FORMAT_JSON, "$.latest", "" },
+        // Regex doesn't match
+        { `{ "version": "nightly" }`, FORMAT_JSON, "$.version", `(\d+)` },
+        // Invalid regex
+        { `{ "version": "1.0" }`, FORMAT_JSON, "$.version", `(` },
+        // Unknown format
+        { `version = "1.0"`, "toml", "$.version", "" },
+    }
+
+    for _, test := range tests {
+        if got, err := ParseVersion([]byte(test.content), test.format, test.path, test.regex, ""); err == nil {
+            t.Errorf("ParseVersion(%s, %s, %s) = %q, want an error", test.format, test.path, test.regex, got)
+        }
+    }
+}
+
+func TestDocumentVersion(t *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path != "/latest-linux.yml" {
+            http.NotFound(w, r)
+            return
+        }
+        _, _ = w.Write([]byte(electronLatestYml))
+    }))
+    defer server.Close()
+
+    resolver := &Document { Client: server.Client() }
+    version, err := resolver.Version(server.URL + "/latest-linux.yml", FORMAT_YAML, "$.version", "", "")
+    if err != nil { t.Fatal(err) }
+    if version != "1.34.2" { t.Errorf("Version() = %q, want 1.34.2", version) }
+
+    if _, err := resolver.Version(server.URL + "/latest.yml", FORMAT_YAML, "$.version", "", ""); err == nil {
+        t.Error("Version() of a missing document succeeded")
+    }
+}
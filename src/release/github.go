@@ -0,0 +1,89 @@
+package release
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+)
+
+// GITHUB_API_URL is the base URL of the GitHub REST API.
+const GITHUB_API_URL string = "https://api.github.com"
+
+// Github resolves releases with the GitHub REST API.
+type Github struct {
+    // Base URL of the API, `GITHUB_API_URL` unless testing or using GitHub Enterprise
+    BaseUrl string
+    // Personal access token, optional but raises the rate limit
+    Token string
+    UserAgent string
+    // HTTP client used for requests, `http.DefaultClient` when nil
+    Client *http.Client
+    // Cache of API responses, nothing is cached when nil
+    Cache *Cache
+}
+
+// githubRelease is the subset of a release returned by the GitHub API that we use.
+type githubRelease struct {
+    TagName string             `json:"tag_name"`
+    Name string                `json:"name"`
+    Draft bool                 `json:"draft"`
+    Prerelease bool            `json:"prerelease"`
+    Assets []githubAsset       `json:"assets"`
+}
+
+type githubAsset struct {
+    Name string                 `json:"name"`
+    BrowserDownloadUrl string   `json:"browser_download_url"`
+    Size int64                  `json:"size"`
+}
+
+// NewGithub returns a resolver for github.com, authenticated with the given token when it's set.
+func NewGithub(token string) *Github { return &Github { BaseUrl: GITHUB_API_URL, Token: token } }
+
+// Latest returns the newest release of `creator/project`. Prereleases are only considered when
+// `prerelease` is set. The version is extracted from the tag, or the title, with `versionRegex`.
+func (g *Github) Latest(creator string, project string, prerelease bool, versionRegex string) (*Release, error) {
+    base := strings.TrimSuffix(g.BaseUrl, "/")
+    if base == "" { base = GITHUB_API_URL }
+    reposUrl := fmt.Sprintf("%s/repos/%s/%s/releases", base, url.PathEscape(creator), url.PathEscape(project))
+
+    found := (*githubRelease)(nil)
+    if prerelease {
+        // The `latest` endpoint skips prereleases, so look through the most recent releases instead.
+        releases := []githubRelease {}
+        if err := getJson(g.Client, g.Cache, reposUrl + "?per_page=30", g.headers(), &releases); err != nil { return nil, err }
+
+        for i := range releases {
+            if !releases[i].Draft { found = &releases[i]; break }
+        }
+        if found == nil { return nil, errors.New("No releases were found for " + creator + "/" + project) }
+    } else {
+        found = &githubRelease {}
+        if err := getJson(g.Client, g.Cache, reposUrl + "/latest", g.headers(), found); err != nil { return nil, err }
+    }
+
+    version, err := ExtractVersion(versionRegex, found.TagName, found.Name)
+    if err != nil { return nil, err }
+
+    result := &Release {
+        Tag: found.TagName, Name: found.Name, Version: version, Prerelease: found.Prerelease,
+    }
+    for _, asset := range found.Assets {
+        result.Assets = append(result.Assets, Asset { Name: asset.Name, Url: asset.BrowserDownloadUrl, Size: asset.Size })
+    }
+
+    return result, nil
+}
+
+// headers returns the headers sent with every request.
+func (g *Github) headers() map[string]string {
+    headers := map[string]string {
+        "Accept": "application/vnd.github+json",
+        "X-GitHub-Api-Version": "2022-11-28",
+        "User-Agent": g.UserAgent,
+    }
+    if g.Token != "" { headers["Authorization"] = "Bearer " + g.Token }
+    return headers
+}
@@ -0,0 +1,103 @@
+package release
+
+import (
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+const githubReleaseJson = `{
+    "tag_name": "desktop-v2025.4.2",
+    "name": "Desktop v2025.4.2",
+    "draft": false,
+    "prerelease": false,
+    "assets": [
+        { "name": "Bitwarden-2025.4.2-x86_64.AppImage", "browser_download_url": "https://example.com/Bitwarden-2025.4.2-x86_64.AppImage", "size": 1 },
+        { "name": "Bitwarden-2025.4.2-aarch64.rpm", "browser_download_url": "https://example.com/Bitwarden-2025.4.2-aarch64.rpm", "size": 2 },
+        { "name": "Bitwarden-2025.4.2-x86_64.rpm", "browser_download_url": "https://example.com/Bitwarden-2025.4.2-x86_64.rpm", "size": 3 }
+    ]
+}`
+
+const githubReleasesJson = `[
+    { "tag_name": "v2.0.0-rc.1", "name": "", "draft": true, "prerelease": true, "assets": [] },
+    { "tag_name": "v1.1.0-rc.2", "name": "", "draft": false, "prerelease": true, "assets": [
+        { "name": "app-1.1.0~rc.2.x86_64.rpm", "browser_download_url": "https://example.com/app.rpm", "size": 4 }
+    ] }
+]`
+
+// newGithubServer returns a fake GitHub API serving the releases of `bitwarden/clients`.
+func newGithubServer(t *testing.T, token string) *httptest.Server {
+    mux := http.NewServeMux()
+    mux.HandleFunc("/repos/bitwarden/clients/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+        if token != "" && r.Header.Get("Authorization") != "Bearer " + token {
+            http.Error(w, `{"message": "Bad credentials"}`, http.StatusUnauthorized)
+            return
+        }
+        _, _ = w.Write([]byte(githubReleaseJson))
+    })
+    mux.HandleFunc("/repos/bitwarden/clients/releases", func(w http.ResponseWriter, _ *http.Request) {
+        _, _ = w.Write([]byte(githubReleasesJson))
+    })
+
+    server := httptest.NewServer(mux)
+    t.Cleanup(server.Close)
+    return server
+}
+
+func TestGithubLatest(t *testing.T) {
+    server := newGithubServer(t, "secret")
+    github := &Github { BaseUrl: server.URL, Token: "secret", Client: server.Client() }
+
+    rel, err := github.Latest("bitwarden", "clients", false, "")
+    if err != nil { t.Fatal(err) }
+    if rel.Tag != "desktop-v2025.4.2" || rel.Version != "2025.4.2" {
+        t.Errorf("Latest() = %q (version %q), want desktop-v2025.4.2 (version 2025.4.2)", rel.Tag, rel.Version)
+    }
+
+    for archKey, want := range map[string]string {
+        "x86_64": "Bitwarden-2025.4.2-x86_64.rpm",
+        "arm64": "Bitwarden-2025.4.2-aarch64.rpm",
+    } {
+        asset, err := PickAsset(rel.Assets, "", archKey)
+        if err != nil { t.Errorf("PickAsset(%s): %v", archKey, err); continue }
+        if asset.Name != want { t.Errorf("PickAsset(%s) = %q, want %q", archKey, asset.Name, want) }
+    }
+
+    if _, err := PickAsset(rel.Assets, "", "x86"); !errors.Is(err, ErrNoAsset) {
+        t.Errorf("PickAsset(x86) error = %v, want ErrNoAsset", err)
+    }
+}
+
+func TestGithubLatestVersionRegex(t *testing.T) {
+    server := newGithubServer(t, "")
+    github := &Github { BaseUrl: server.URL, Client: server.Client() }
+
+    rel, err := github.Latest("bitwarden", "clients", false, `^Desktop v([\d.]+)$`)
+    if err != nil { t.Fatal(err) }
+    if rel.Version != "2025.4.2" { t.Errorf("Version = %q, want 2025.4.2", rel.Version) }
+
+    asset, err := PickAsset(rel.Assets, `x86_64\.rpm$`, "x86_64")
+    if err != nil { t.Fatal(err) }
+    if asset.Url != "https://example.com/Bitwarden-2025.4.2-x86_64.rpm" { t.Errorf("Url = %q", asset.Url) }
+}
+
+func TestGithubLatestPrerelease(t *testing.T) {
+    server := newGithubServer(t, "")
+    github := &Github { BaseUrl: server.URL, Client: server.Client() }
+
+    rel, err := github.Latest("bitwarden", "clients", true, "")
+    if err != nil { t.Fatal(err) }
+    if rel.Tag != "v1.1.0-rc.2" || !rel.Prerelease {
+        t.Errorf("Latest() = %q (prerelease %t), want the newest non-draft release", rel.Tag, rel.Prerelease)
+    }
+}
+
+func TestGithubLatestBadToken(t *testing.T) {
+    server := newGithubServer(t, "secret")
+    github := &Github { BaseUrl: server.URL, Token: "wrong", Client: server.Client() }
+
+    if _, err := github.Latest("bitwarden", "clients", false, ""); err == nil {
+        t.Error("Latest() with a bad token succeeded")
+    }
+}
@@ -0,0 +1,93 @@
+package release
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+)
+
+// GITLAB_URL is the URL of gitlab.com, used when a manifest doesn't set its instance URL.
+const GITLAB_URL string = "https://gitlab.com"
+
+// Gitlab resolves releases with the REST API of gitlab.com or a self-hosted GitLab instance.
+type Gitlab struct {
+    // URL of the GitLab instance, `GITLAB_URL` when empty
+    InstanceUrl string
+    // Personal or project access token, sent as `PRIVATE-TOKEN`
+    Token string
+    UserAgent string
+    // HTTP client used for requests, `http.DefaultClient` when nil
+    Client *http.Client
+    // Cache of API responses, nothing is cached when nil
+    Cache *Cache
+}
+
+// gitlabRelease is the subset of a release returned by the GitLab API that we use.
+type gitlabRelease struct {
+    TagName string              `json:"tag_name"`
+    Name string                 `json:"name"`
+    UpcomingRelease bool        `json:"upcoming_release"`
+    Assets struct {
+        Links []gitlabLink      `json:"links"`
+    }                           `json:"assets"`
+}
+
+type gitlabLink struct {
+    Name string                 `json:"name"`
+    Url string                  `json:"url"`
+    DirectAssetUrl string       `json:"direct_asset_url"`
+}
+
+// NewGitlab returns a resolver for the given GitLab instance, authenticated with the given token when it's set.
+func NewGitlab(instanceUrl string, token string) *Gitlab {
+    return &Gitlab { InstanceUrl: instanceUrl, Token: token }
+}
+
+// Latest returns the newest release of the given project, which is either its numeric ID
+// or its `namespace/project` path. Upcoming releases are skipped. The version is extracted
+// from the tag, or the title, with `versionRegex`.
+func (g *Gitlab) Latest(projectId string, versionRegex string) (*Release, error) {
+    base := strings.TrimSuffix(g.InstanceUrl, "/")
+    if base == "" { base = GITLAB_URL }
+    releasesUrl := fmt.Sprintf("%s/api/v4/projects/%s/releases?per_page=20", base, url.PathEscape(projectId))
+
+    // Releases are sorted by release date, newest first.
+    releases := []gitlabRelease {}
+    if err := getJson(g.Client, g.Cache, releasesUrl, g.headers(), &releases); err != nil { return nil, err }
+
+    found := (*gitlabRelease)(nil)
+    for i := range releases {
+        if !releases[i].UpcomingRelease { found = &releases[i]; break }
+    }
+    if found == nil { return nil, errors.New("No releases were found for GitLab project " + projectId) }
+
+    version, err := ExtractVersion(versionRegex, found.TagName, found.Name)
+    if err != nil { return nil, err }
+
+    result := &Release { Tag: found.TagName, Name: found.Name, Version: version }
+    for _, link := range found.Assets.Links {
+        assetUrl := link.DirectAssetUrl
+        if assetUrl == "" { assetUrl = link.Url }
+        result.Assets = append(result.Assets, Asset { Name: assetName(link.Name, assetUrl), Url: assetUrl })
+    }
+
+    return result, nil
+}
+
+// headers returns the headers sent with every request.
+func (g *Gitlab) headers() map[string]string {
+    return map[string]string { "PRIVATE-TOKEN": g.Token, "User-Agent": g.UserAgent }
+}
+
+// assetName returns the name of a release link, preferring the file name of its URL when
+// the link has a descriptive title instead, e.g. `RPM package (x86_64)`.
+func assetName(name string, rawUrl string) string {
+    if u, err := url.Parse(rawUrl); err == nil {
+        if base := u.Path[strings.LastIndex(u.Path, "/") + 1:]; strings.HasSuffix(strings.ToLower(base), ".rpm") {
+            return base
+        }
+    }
+    return name
+}
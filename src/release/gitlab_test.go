@@ -0,0 +1,56 @@
+package release
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+const gitlabReleasesJson = `[
+    { "tag_name": "v3.0.0", "name": "3.0.0", "upcoming_release": true, "assets": { "links": [] } },
+    { "tag_name": "v2.4.1", "name": "Release 2.4.1", "upcoming_release": false, "assets": { "links": [
+        { "name": "RPM package (aarch64)", "url": "https://gitlab.example.com/-/project/42/uploads/ab/app-2.4.1-1.aarch64.rpm" },
+        { "name": "app-2.4.1-1.x86_64.rpm", "url": "https://gitlab.example.com/group/app/-/releases/v2.4.1/downloads/app.rpm",
+          "direct_asset_url": "https://gitlab.example.com/group/app/-/releases/v2.4.1/downloads/app-2.4.1-1.x86_64.rpm" },
+        { "name": "Checksums", "url": "https://gitlab.example.com/group/app/-/releases/v2.4.1/downloads/SHA256SUMS" }
+    ] } }
+]`
+
+func TestGitlabLatest(t *testing.T) {
+    mux := http.NewServeMux()
+    mux.HandleFunc("/api/v4/projects/{id}/releases", func(w http.ResponseWriter, r *http.Request) {
+        if r.PathValue("id") != "group/app" && r.PathValue("id") != "42" {
+            http.NotFound(w, r)
+            return
+        }
+        if r.Header.Get("PRIVATE-TOKEN") != "secret" {
+            http.Error(w, `{"message": "401 Unauthorized"}`, http.StatusUnauthorized)
+            return
+        }
+        _, _ = w.Write([]byte(gitlabReleasesJson))
+    })
+    server := httptest.NewServer(mux)
+    defer server.Close()
+
+    for _, projectId := range []string { "42", "group/app" } {
+        gitlab := &Gitlab { InstanceUrl: server.URL + "/", Token: "secret", Client: server.Client() }
+
+        rel, err := gitlab.Latest(projectId, "")
+        if err != nil { t.Fatalf("Latest(%s): %v", projectId, err) }
+        if rel.Tag != "v2.4.1" || rel.Version != "2.4.1" {
+            t.Errorf("Latest(%s) = %q (version %q), want v2.4.1 (version 2.4.1)", projectId, rel.Tag, rel.Version)
+        }
+
+        for archKey, want := range map[string]string {
+            "x86_64": "https://gitlab.example.com/group/app/-/releases/v2.4.1/downloads/app-2.4.1-1.x86_64.rpm",
+            "arm64": "https://gitlab.example.com/-/project/42/uploads/ab/app-2.4.1-1.aarch64.rpm",
+        } {
+            asset, err := PickAsset(rel.Assets, "", archKey)
+            if err != nil { t.Errorf("PickAsset(%s): %v", archKey, err); continue }
+            if asset.Url != want { t.Errorf("PickAsset(%s) = %q, want %q", archKey, asset.Url, want) }
+        }
+    }
+
+    gitlab := &Gitlab { InstanceUrl: server.URL, Client: server.Client() }
+    if _, err := gitlab.Latest("42", ""); err == nil { t.Error("Latest() without a token succeeded") }
+}
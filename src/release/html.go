@@ -0,0 +1,120 @@
+package release
+
+import (
+    "errors"
+    "fmt"
+    "mime"
+    "net/http"
+    "net/url"
+    "path"
+    "strings"
+
+    "github.com/FlawlessCasual17/rpm-get/httpclient"
+
+    // third-party imports
+    "github.com/antchfx/htmlquery"
+)
+
+// DEFAULT_XPATH selects every link of a page.
+const DEFAULT_XPATH string = "//a/@href"
+
+// rpmContentTypes are the content types servers use for RPMs.
+var rpmContentTypes = []string { "application/x-rpm", "application/x-redhat-package-manager" }
+
+// Html resolves releases by scraping the download page of a vendor.
+type Html struct {
+    UserAgent string
+    // HTTP client used for requests, `http.DefaultClient` when nil
+    Client *http.Client
+}
+
+// NewHtml returns a resolver for download pages.
+func NewHtml() *Html { return &Html {} }
+
+// Latest fetches the page at `pageUrl` and returns the `.rpm` links selected by `xpathExpr`
+// (`DEFAULT_XPATH` when empty), resolved against the URL of the page.
+// When `pageUrl` redirects straight to an RPM instead, like "latest" download URLs do,
+// that RPM is the only asset, named after the final file name.
+// The version is extracted from the asset names, then from the text of the page, with `versionRegex`.
+func (h *Html) Latest(pageUrl string, xpathExpr string, versionRegex string) (*Release, error) {
+    client := h.Client
+    if client == nil { client = http.DefaultClient }
+
+    request, reqErr := http.NewRequest("GET", pageUrl, nil)
+    if reqErr != nil { return nil, fmt.Errorf("Invalid request: %w", reqErr) }
+    if h.UserAgent != "" { request.Header.Set("User-Agent", h.UserAgent) }
+
+    // Redirects are followed by the client.
+    resp, respErr := client.Do(request)
+    if respErr != nil { return nil, fmt.Errorf("Request failed: %w", respErr) }
+    //nolint:errcheck
+    defer resp.Body.Close()
+
+    if err := httpclient.CheckStatus(resp); err != nil { return nil, err }
+
+    finalUrl := resp.Request.URL
+    result := &Release { Name: finalUrl.String() }
+    candidates := []string {}
+
+    if fileName, ok := rpmResponseFileName(resp); ok {
+        result.Assets = []Asset { { Name: fileName, Url: finalUrl.String(), Size: resp.ContentLength } }
+        candidates = append(candidates, fileName)
+    } else {
+        doc, parseErr := htmlquery.Parse(resp.Body)
+        if parseErr != nil { return nil, fmt.Errorf("Failed to parse %s: %w", pageUrl, parseErr) }
+
+        if xpathExpr == "" { xpathExpr = DEFAULT_XPATH }
+        nodes, queryErr := htmlquery.QueryAll(doc, xpathExpr)
+        if queryErr != nil { return nil, fmt.Errorf("Invalid XPath expression %q: %w", xpathExpr, queryErr) }
+
+        for _, node := range nodes {
+            link := htmlquery.SelectAttr(node, "href")
+            if link == "" { link = htmlquery.InnerText(node) }
+
+            asset, ok := rpmLink(finalUrl, strings.TrimSpace(link))
+            if !ok { continue }
+            result.Assets = append(result.Assets, asset)
+            candidates = append(candidates, asset.Name)
+        }
+
+        candidates = append(candidates, htmlquery.InnerText(doc))
+    }
+
+    if len(result.Assets) == 0 { return nil, fmt.Errorf("No RPM links were found on %s", pageUrl) }
+
+    version, err := ExtractVersion(versionRegex, candidates...)
+    if err != nil { return nil, errors.New("No version was found on " + pageUrl) }
+    result.Version = version
+
+    return result, nil
+}
+
+// rpmResponseFileName returns the file name of the response when it's an RPM rather than a page.
+func rpmResponseFileName(resp *http.Response) (string, bool) {
+    fileName := path.Base(resp.Request.URL.Path)
+    if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+        fileName = path.Base(params["filename"])
+    }
+
+    contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+    for _, rpmType := range rpmContentTypes {
+        if contentType == rpmType { return fileName, true }
+    }
+
+    return fileName, contentType != "text/html" && strings.HasSuffix(strings.ToLower(fileName), ".rpm")
+}
+
+// rpmLink resolves the given link against the URL of its page, and reports whether it points to an RPM.
+func rpmLink(base *url.URL, link string) (Asset, bool) {
+    ref, err := url.Parse(link)
+    if err != nil { return Asset {}, false }
+
+    resolved := base.ResolveReference(ref)
+    if resolved.Scheme != "http" && resolved.Scheme != "https" { return Asset {}, false }
+
+    name := path.Base(resolved.Path)
+    if unescaped, err := url.PathUnescape(name); err == nil { name = unescaped }
+    if !strings.HasSuffix(strings.ToLower(name), ".rpm") { return Asset {}, false }
+
+    return Asset { Name: name, Url: resolved.String() }, true
+}
@@ -0,0 +1,95 @@
+package release
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+const downloadPage = `<!DOCTYPE html>
+<html>
+<body>
+    <h1>Download App</h1>
+    <p>Latest version: 4.17.0</p>
+    <ul id="linux">
+        <li><a href="/files/app-4.17.0-1.x86_64.rpm">Fedora (64-bit)</a></li>
+        <li><a href="files/app-4.17.0-1.aarch64.rpm">Fedora (ARM)</a></li>
+        <li><a href="https://cdn.example.com/app_4.17.0_amd64.deb">Debian</a></li>
+    </ul>
+    <a href="/old/app-4.16.0-1.x86_64.rpm">Previous version</a>
+</body>
+</html>`
+
+// newDownloadServer returns a fake vendor site, with a download page and "latest" redirects.
+func newDownloadServer(t *testing.T) *httptest.Server {
+    mux := http.NewServeMux()
+    mux.HandleFunc("/download/", func(w http.ResponseWriter, _ *http.Request) {
+        w.Header().Set("Content-Type", "text/html; charset=utf-8")
+        _, _ = w.Write([]byte(downloadPage))
+    })
+    mux.HandleFunc("/latest/rpm", func(w http.ResponseWriter, r *http.Request) {
+        http.Redirect(w, r, "/files/app-4.17.0-1.x86_64.rpm", http.StatusFound)
+    })
+    mux.HandleFunc("/latest/rpm-arm", func(w http.ResponseWriter, r *http.Request) {
+        http.Redirect(w, r, "/dl?id=42", http.StatusFound)
+    })
+    mux.HandleFunc("/files/", func(w http.ResponseWriter, _ *http.Request) {
+        w.Header().Set("Content-Type", "application/octet-stream")
+        _, _ = w.Write([]byte("rpm"))
+    })
+    mux.HandleFunc("/dl", func(w http.ResponseWriter, _ *http.Request) {
+        w.Header().Set("Content-Type", "application/x-rpm")
+        w.Header().Set("Content-Disposition", `attachment; filename="app-4.17.0-1.aarch64.rpm"`)
+        _, _ = w.Write([]byte("rpm"))
+    })
+
+    server := httptest.NewServer(mux)
+    t.Cleanup(server.Close)
+    return server
+}
+
+func TestHtmlLatestPage(t *testing.T) {
+    server := newDownloadServer(t)
+    resolver := &Html { Client: server.Client() }
+
+    rel, err := resolver.Latest(server.URL + "/download/", `//ul[@id="linux"]//a/@href`, `app-([\d.]+)-`)
+    if err != nil { t.Fatal(err) }
+    if rel.Version != "4.17.0" { t.Errorf("Version = %q, want 4.17.0", rel.Version) }
+    if len(rel.Assets) != 2 { t.Fatalf("Assets = %v, want the 2 RPM links of the list", rel.Assets) }
+
+    for archKey, want := range map[string]string {
+        "x86_64": server.URL + "/files/app-4.17.0-1.x86_64.rpm",
+        "arm64": server.URL + "/download/files/app-4.17.0-1.aarch64.rpm",
+    } {
+        asset, err := PickAsset(rel.Assets, "", archKey)
+        if err != nil { t.Errorf("PickAsset(%s): %v", archKey, err); continue }
+        if asset.Url != want { t.Errorf("PickAsset(%s) = %q, want %q", archKey, asset.Url, want) }
+    }
+}
+
+func TestHtmlLatestPageText(t *testing.T) {
+    server := newDownloadServer(t)
+    resolver := &Html { Client: server.Client() }
+
+    rel, err := resolver.Latest(server.URL + "/download/", "", `Latest version: ([\d.]+)`)
+    if err != nil { t.Fatal(err) }
+    if rel.Version != "4.17.0" { t.Errorf("Version = %q, want 4.17.0", rel.Version) }
+    if len(rel.Assets) != 3 { t.Errorf("Assets = %v, want every RPM link of the page", rel.Assets) }
+}
+
+func TestHtmlLatestRedirect(t *testing.T) {
+    server := newDownloadServer(t)
+    resolver := &Html { Client: server.Client() }
+
+    for pageUrl, want := range map[string]Asset {
+        "/latest/rpm": { Name: "app-4.17.0-1.x86_64.rpm", Url: server.URL + "/files/app-4.17.0-1.x86_64.rpm" },
+        "/latest/rpm-arm": { Name: "app-4.17.0-1.aarch64.rpm", Url: server.URL + "/dl?id=42" },
+    } {
+        rel, err := resolver.Latest(server.URL + pageUrl, "", `app-([\d.]+)-`)
+        if err != nil { t.Errorf("Latest(%s): %v", pageUrl, err); continue }
+        if rel.Version != "4.17.0" { t.Errorf("Latest(%s) version = %q, want 4.17.0", pageUrl, rel.Version) }
+        if len(rel.Assets) != 1 || rel.Assets[0].Name != want.Name || rel.Assets[0].Url != want.Url {
+            t.Errorf("Latest(%s) assets = %v, want %v", pageUrl, rel.Assets, want)
+        }
+    }
+}
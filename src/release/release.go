@@ -0,0 +1,149 @@
+// Package release resolves the newest version of a package, and the URL of its RPM,
+// from the place its vendor publishes releases.
+package release
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "regexp"
+    "strings"
+    "time"
+
+    "github.com/FlawlessCasual17/rpm-get/httpclient"
+
+    // third-party imports
+    "github.com/goccy/go-json"
+)
+
+// ErrNoAsset is returned when no release asset matches the requested architecture.
+var ErrNoAsset = errors.New("no matching RPM asset was found")
+
+// DEFAULT_VERSION_REGEX extracts the version from a tag such as `v1.2.3` or `desktop-v2025.4.2`.
+const DEFAULT_VERSION_REGEX string = `(\d[\w.+~^]*)`
+
+// archAssetRegexes are the default asset regexes used for each manifest architecture key.
+var archAssetRegexes = map[string]string {
+    "x86_64": `(?i)[._-](x86_64|amd64|x64)\.rpm$`,
+    "x86": `(?i)[._-](i[3-6]86|x86)\.rpm$`,
+    "arm64": `(?i)[._-](aarch64|arm64)\.rpm$`,
+}
+
+// Release is a published release of a package.
+type Release struct {
+    // Name of the tag the release was created from
+    Tag string
+    // Title of the release
+    Name string
+    // Version extracted from the tag or title
+    Version string
+    Prerelease bool
+    Assets []Asset
+}
+
+// Asset is a file attached to a release.
+type Asset struct {
+    Name string
+    // Download URL
+    Url string
+    Size int64
+}
+
+// ExtractVersion extracts the version from the first of the given strings that matches
+// `pattern`, using its first capture group (or the whole match when it has none).
+// `DEFAULT_VERSION_REGEX` is used when `pattern` is empty.
+func ExtractVersion(pattern string, candidates ...string) (string, error) {
+    if pattern == "" { pattern = DEFAULT_VERSION_REGEX }
+
+    regex, err := regexp.Compile(pattern)
+    if err != nil { return "", fmt.Errorf("Failed to parse version regex: %w", err) }
+
+    for _, candidate := range candidates {
+        match := regex.FindStringSubmatch(candidate)
+        if match == nil { continue }
+        if len(match) > 1 { return match[1], nil }
+        return match[0], nil
+    }
+
+    return "", fmt.Errorf("No version matching %q was found in %s", pattern, strings.Join(candidates, ", "))
+}
+
+// PickAsset returns the first `.rpm` asset whose name matches `pattern`. When `pattern` is
+// empty, a default regex for the given manifest architecture key is used instead.
+func PickAsset(assets []Asset, pattern string, archKey string) (Asset, error) {
+    if pattern == "" { pattern = archAssetRegexes[archKey] }
+    if pattern == "" { return Asset {}, fmt.Errorf("Unsupported architecture %q: %w", archKey, ErrNoAsset) }
+
+    regex, err := regexp.Compile(pattern)
+    if err != nil { return Asset {}, fmt.Errorf("Failed to parse asset regex: %w", err) }
+
+    for _, asset := range assets {
+        if strings.HasSuffix(strings.ToLower(asset.Name), ".rpm") && regex.MatchString(asset.Name) {
+            return asset, nil
+        }
+    }
+
+    return Asset {}, fmt.Errorf("%w for %s (%s)", ErrNoAsset, archKey, pattern)
+}
+
+// getJson sends a GET request with the given headers and decodes the JSON response into `out`.
+// When `cache` is set, a fresh cached response is used instead, and an expired one is used
+// when the API is rate limited or unreachable.
+func getJson(client *http.Client, cache *Cache, rawUrl string, headers map[string]string, out any) error {
+    entry := cache.load(rawUrl, headers)
+    if cache.fresh(entry) { return decodeJson(rawUrl, entry.Body, out) }
+
+    body, err := fetchJson(client, cache, rawUrl, headers, entry)
+    if err != nil {
+        if entry == nil || !usableWhenStale(err) { return err }
+        cache.stale(entry, err)
+        body = entry.Body
+    }
+
+    return decodeJson(rawUrl, body, out)
+}
+
+// fetchJson fetches a JSON response from the API. The cached `entry` is revalidated when it's set.
+func fetchJson(client *http.Client, cache *Cache, rawUrl string, headers map[string]string, entry *cacheEntry) ([]byte, error) {
+    if client == nil { client = http.DefaultClient }
+    if err := cache.rateLimit(rawUrl); err != nil { return nil, err }
+
+    request, reqErr := http.NewRequest("GET", rawUrl, nil)
+    if reqErr != nil { return nil, fmt.Errorf("Invalid request: %w", reqErr) }
+    for key, value := range headers {
+        if value != "" { request.Header.Set(key, value) }
+    }
+    if entry != nil && entry.ETag != "" { request.Header.Set("If-None-Match", entry.ETag) }
+
+    resp, respErr := client.Do(request)
+    if respErr != nil { return nil, fmt.Errorf("Request failed: %w", respErr) }
+    //nolint:errcheck
+    defer resp.Body.Close()
+
+    if err := cache.updateRateLimit(resp); err != nil { return nil, err }
+
+    if resp.StatusCode == http.StatusNotModified && entry != nil {
+        entry.FetchedAt = time.Now()
+        cache.store(entry, headers)
+        return entry.Body, nil
+    }
+    if err := httpclient.CheckStatus(resp); err != nil { return nil, err }
+
+    body, readErr := io.ReadAll(resp.Body)
+    if readErr != nil { return nil, fmt.Errorf("Failed to read the response of %s: %w", rawUrl, readErr) }
+
+    if json.Valid(body) {
+        cache.store(&cacheEntry { Url: rawUrl, ETag: resp.Header.Get("ETag"), FetchedAt: time.Now(), Body: body }, headers)
+    }
+
+    return body, nil
+}
+
+// decodeJson decodes a JSON response into `out`.
+func decodeJson(rawUrl string, body []byte, out any) error {
+    if err := json.Unmarshal(body, out); err != nil {
+        return fmt.Errorf("Failed to decode the response of %s: %w", rawUrl, err)
+    }
+    return nil
+}
@@ -0,0 +1,259 @@
+// Package state records the packages installed by rpm-get, and how they were installed.
+package state
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+    "syscall"
+    "time"
+
+    // third-party imports
+    "github.com/goccy/go-json"
+    "github.com/samber/lo"
+)
+
+// FILE_NAME is the name of the state file inside the state directory.
+const FILE_NAME string = "state.json"
+
+// LOCK_NAME is the name of the lock file inside the state directory.
+const LOCK_NAME string = "state.lock"
+
+// Install sources of a package.
+const (
+    // SOURCE_DIRECT is used for RPMs downloaded from a URL.
+    SOURCE_DIRECT string = "direct"
+    // SOURCE_REPO is used for packages installed from an RPM repository.
+    SOURCE_REPO string = "repo"
+    // SOURCE_COPR is used for packages installed from a Fedora COPR repository.
+    SOURCE_COPR string = "copr"
+)
+
+// ErrLocked is returned when another rpm-get process holds the lock on the state.
+var ErrLocked = errors.New("the rpm-get state is locked by another process")
+
+// Package is a package installed by rpm-get.
+type Package struct {
+    // Name of the package manifest
+    Name string              `json:"name"`
+    // Name of the RPM package, which may differ from the manifest name
+    RpmName string           `json:"rpm_name"`
+    // Installed `epoch:version-release`, as reported by rpm
+    Version string           `json:"version"`
+    // Manifest architecture key, e.g. `x86_64`
+    Arch string              `json:"arch"`
+    // One of `SOURCE_DIRECT`, `SOURCE_REPO` or `SOURCE_COPR`
+    Source string            `json:"source"`
+    // Download URL of direct download packages
+    Url string               `json:"url,omitempty"`
+    // URL of the repo file, or `user/project` for Copr repos
+    Repo string              `json:"repo,omitempty"`
+    // Name of the repo file in the repos directory of the package manager
+    RepoFile string          `json:"repo_file,omitempty"`
+    // Path of the downloaded RPM in the cache directory
+    File string              `json:"file,omitempty"`
+    // SHA-256 hash of the downloaded RPM
+    Sha256 string            `json:"sha256,omitempty"`
+    // Fingerprints of the GPG keys declared by the manifest
+    GpgKeys []string         `json:"gpg_keys,omitempty"`
+    InstalledAt time.Time    `json:"installed_at"`
+}
+
+// Key is a GPG key that rpm-get imported into the rpm keyring.
+// Keys that were already in the keyring are not recorded.
+type Key struct {
+    // Fingerprint of the key, in uppercase hex
+    Fingerprint string    `json:"fingerprint"`
+    // URL or path the key was imported from
+    Url string            `json:"url"`
+    ImportedAt time.Time  `json:"imported_at"`
+}
+
+// Repo is a repo file that rpm-get added to the repos directory of the package manager.
+type Repo struct {
+    // Name of the repo file in the repos directory
+    File string            `json:"file"`
+    // URL of the repo file, or `user/project` for Copr repos
+    Url string             `json:"url"`
+    AddedAt time.Time      `json:"added_at"`
+}
+
+// DB is the store of installed packages.
+// It must be closed to release its lock.
+type DB struct {
+    dir string
+    lock *os.File
+    // Whether the state was opened with `Open`, and holds an exclusive lock
+    writable bool
+    packages map[string]*Package
+    keys map[string]*Key
+    repos map[string]*Repo
+}
+
+// stateFile is the on-disk format of the state.
+type stateFile struct {
+    Packages map[string]*Package   `json:"packages"`
+    Keys map[string]*Key           `json:"keys,omitempty"`
+    Repos map[string]*Repo         `json:"repos,omitempty"`
+}
+
+// Open opens the state in the given directory for reading and writing.
+// It holds an exclusive lock until the returned `DB` is closed, and fails with
+// `ErrLocked` if another process holds a lock.
+func Open(dir string) (*DB, error) {
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return nil, fmt.Errorf("Unable to create state dir: %w", err)
+    }
+
+    lock, lockErr := os.OpenFile(filepath.Join(dir, LOCK_NAME), os.O_CREATE|os.O_RDWR, 0644)
+    if lockErr != nil { return nil, fmt.Errorf("Unable to open state lock: %w", lockErr) }
+
+    return open(dir, lock, syscall.LOCK_EX)
+}
+
+// Read opens the state in the given directory for reading only.
+// It holds a shared lock when the lock file is accessible to the current user.
+func Read(dir string) (*DB, error) {
+    lock, lockErr := os.Open(filepath.Join(dir, LOCK_NAME))
+    if lockErr != nil {
+        // Without the lock file nothing was ever installed, or the state is unreadable anyway.
+        db := &DB { dir: dir, packages: map[string]*Package {}, keys: map[string]*Key {}, repos: map[string]*Repo {} }
+        return db, db.load()
+    }
+
+    return open(dir, lock, syscall.LOCK_SH)
+}
+
+// open takes the given lock on the lock file and loads the state.
+func open(dir string, lock *os.File, how int) (*DB, error) {
+    if err := syscall.Flock(int(lock.Fd()), how|syscall.LOCK_NB); err != nil {
+        _ = lock.Close()
+        if errors.Is(err, syscall.EWOULDBLOCK) { return nil, ErrLocked }
+        return nil, fmt.Errorf("Unable to lock state: %w", err)
+    }
+
+    db := &DB { dir: dir, lock: lock, writable: how == syscall.LOCK_EX, packages: map[string]*Package {}, keys: map[string]*Key {}, repos: map[string]*Repo {} }
+    if err := db.load(); err != nil {
+        _ = db.Close()
+        return nil, err
+    }
+
+    return db, nil
+}
+
+// load reads the state file, if it exists.
+func (db *DB) load() error {
+    content, readErr := os.ReadFile(filepath.Join(db.dir, FILE_NAME))
+    if errors.Is(readErr, os.ErrNotExist) { return nil }
+    if readErr != nil { return fmt.Errorf("Failed to read state: %w", readErr) }
+
+    data := stateFile {}
+    if err := json.Unmarshal(content, &data); err != nil {
+        return fmt.Errorf("Failed to parse state: %w", err)
+    }
+    if data.Packages != nil { db.packages = data.Packages }
+    if data.Keys != nil { db.keys = data.Keys }
+    if data.Repos != nil { db.repos = data.Repos }
+
+    return nil
+}
+
+// Save atomically writes the state to disk.
+func (db *DB) Save() error {
+    if !db.writable || db.lock == nil { return errors.New("The state was opened read-only") }
+
+    content, err := json.MarshalIndent(stateFile { Packages: db.packages, Keys: db.keys, Repos: db.repos }, "", "    ")
+    if err != nil { return fmt.Errorf("Failed to encode state: %w", err) }
+
+    filePath := filepath.Join(db.dir, FILE_NAME)
+    tmpFilePath := filePath + ".tmp"
+    if err := os.WriteFile(tmpFilePath, content, 0644); err != nil {
+        return fmt.Errorf("Failed to write state: %w", err)
+    }
+    if err := os.Rename(tmpFilePath, filePath); err != nil {
+        _ = os.Remove(tmpFilePath)
+        return fmt.Errorf("Failed to write state: %w", err)
+    }
+
+    return nil
+}
+
+// Close releases the lock on the state. Unsaved changes are discarded.
+func (db *DB) Close() error {
+    if db.lock == nil { return nil }
+
+    lock := db.lock
+    db.lock = nil
+    _ = syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+    return lock.Close()
+}
+
+// Get returns the given package, or nil if it wasn't installed by rpm-get.
+func (db *DB) Get(name string) *Package { return db.packages[name] }
+
+// Put adds or replaces a package.
+func (db *DB) Put(pkg *Package) { db.packages[pkg.Name] = pkg }
+
+// Delete forgets the given package.
+func (db *DB) Delete(name string) { delete(db.packages, name) }
+
+// List returns every package installed by rpm-get, sorted by name.
+func (db *DB) List() []*Package {
+    result := lo.Values(db.packages)
+    slices.SortFunc(result, func(a *Package, b *Package) int { return strings.Compare(a.Name, b.Name) })
+    return result
+}
+
+// UsesRepoFile reports whether any package other than `except` was installed from the given repo file.
+func (db *DB) UsesRepoFile(repoFile string, except string) bool {
+    for _, pkg := range db.packages {
+        if pkg.Name != except && pkg.RepoFile == repoFile { return true }
+    }
+    return false
+}
+
+// GetKey returns the key with the given fingerprint, or nil if it wasn't imported by rpm-get.
+func (db *DB) GetKey(fingerprint string) *Key { return db.keys[strings.ToUpper(fingerprint)] }
+
+// PutKey adds or replaces a key.
+func (db *DB) PutKey(key *Key) {
+    key.Fingerprint = strings.ToUpper(key.Fingerprint)
+    db.keys[key.Fingerprint] = key
+}
+
+// DeleteKey forgets the key with the given fingerprint.
+func (db *DB) DeleteKey(fingerprint string) { delete(db.keys, strings.ToUpper(fingerprint)) }
+
+// ListKeys returns every key imported by rpm-get, sorted by fingerprint.
+func (db *DB) ListKeys() []*Key {
+    result := lo.Values(db.keys)
+    slices.SortFunc(result, func(a *Key, b *Key) int { return strings.Compare(a.Fingerprint, b.Fingerprint) })
+    return result
+}
+
+// KeyUsers returns the names of the packages whose manifest declared the key with the given fingerprint.
+func (db *DB) KeyUsers(fingerprint string) []string {
+    result := []string {}
+    for _, pkg := range db.List() {
+        if slices.ContainsFunc(pkg.GpgKeys, func(fp string) bool { return strings.EqualFold(fp, fingerprint) }) {
+            result = append(result, pkg.Name)
+        }
+    }
+    return result
+}
+
+// PutRepo adds or replaces a repo file.
+func (db *DB) PutRepo(repo *Repo) { db.repos[repo.File] = repo }
+
+// DeleteRepo forgets the given repo file.
+func (db *DB) DeleteRepo(file string) { delete(db.repos, file) }
+
+// ListRepos returns every repo file added by rpm-get, sorted by file name.
+func (db *DB) ListRepos() []*Repo {
+    result := lo.Values(db.repos)
+    slices.SortFunc(result, func(a *Repo, b *Repo) int { return strings.Compare(a.File, b.File) })
+    return result
+}
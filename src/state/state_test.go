@@ -0,0 +1,107 @@
+package state
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+func TestSaveRead(t *testing.T) {
+    dir := t.TempDir()
+
+    db, err := Open(dir)
+    if err != nil { t.Fatal(err) }
+    installedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+    db.Put(&Package { Name: "app", RpmName: "app-bin", Version: "0:1.0-1", Source: SOURCE_REPO, RepoFile: "app.repo", InstalledAt: installedAt })
+    db.PutKey(&Key { Fingerprint: "abcd", Url: "https://example.com/key.asc" })
+    db.PutRepo(&Repo { File: "app.repo", Url: "https://example.com/app.repo" })
+    if err := db.Save(); err != nil { t.Fatal(err) }
+    if err := db.Close(); err != nil { t.Fatal(err) }
+
+    if _, err := os.Stat(filepath.Join(dir, FILE_NAME + ".tmp")); !errors.Is(err, os.ErrNotExist) {
+        t.Errorf("the temporary state file was left behind: %v", err)
+    }
+
+    db, err = Read(dir)
+    if err != nil { t.Fatal(err) }
+    //nolint:errcheck
+    defer db.Close()
+
+    pkg := db.Get("app")
+    if pkg == nil || pkg.RpmName != "app-bin" || pkg.Version != "0:1.0-1" || !pkg.InstalledAt.Equal(installedAt) {
+        t.Errorf("Get(app) = %+v after a round trip", pkg)
+    }
+    if key := db.GetKey("ABCD"); key == nil || key.Url != "https://example.com/key.asc" {
+        t.Errorf("GetKey(ABCD) = %+v after a round trip", key)
+    }
+    if repos := db.ListRepos(); len(repos) != 1 || repos[0].File != "app.repo" {
+        t.Errorf("ListRepos() = %+v after a round trip", repos)
+    }
+}
+
+func TestLocked(t *testing.T) {
+    dir := t.TempDir()
+
+    db, err := Open(dir)
+    if err != nil { t.Fatal(err) }
+
+    if _, err := Open(dir); !errors.Is(err, ErrLocked) { t.Errorf("a second Open() = %v, want ErrLocked", err) }
+    if _, err := Read(dir); !errors.Is(err, ErrLocked) { t.Errorf("Read() = %v, want ErrLocked", err) }
+
+    if err := db.Close(); err != nil { t.Fatal(err) }
+
+    // Closing releases the lock, and readers share theirs.
+    reader, err := Read(dir)
+    if err != nil { t.Fatalf("Read() after Close() = %v", err) }
+    //nolint:errcheck
+    defer reader.Close()
+    other, err := Read(dir)
+    if err != nil { t.Fatalf("a second Read() = %v", err) }
+    //nolint:errcheck
+    defer other.Close()
+
+    if _, err := Open(dir); !errors.Is(err, ErrLocked) { t.Errorf("Open() while read = %v, want ErrLocked", err) }
+}
+
+func TestReadOnlySave(t *testing.T) {
+    dir := t.TempDir()
+
+    db, err := Open(dir)
+    if err != nil { t.Fatal(err) }
+    if err := db.Close(); err != nil { t.Fatal(err) }
+
+    reader, err := Read(dir)
+    if err != nil { t.Fatal(err) }
+    //nolint:errcheck
+    defer reader.Close()
+
+    reader.Put(&Package { Name: "app" })
+    if err := reader.Save(); err == nil { t.Error("Save() succeeded on a state opened with Read()") }
+    if _, err := os.Stat(filepath.Join(dir, FILE_NAME)); !errors.Is(err, os.ErrNotExist) {
+        t.Errorf("Save() wrote the state file of a state opened with Read(): %v", err)
+    }
+}
+
+func TestReadMissingLock(t *testing.T) {
+    dir := t.TempDir()
+
+    // Without the lock file, the state file is still read, without locking.
+    content := []byte(`{"packages":{"app":{"name":"app","rpm_name":"app","version":"0:1.0-1"}}}`)
+    if err := os.WriteFile(filepath.Join(dir, FILE_NAME), content, 0644); err != nil { t.Fatal(err) }
+
+    db, err := Read(dir)
+    if err != nil { t.Fatal(err) }
+    if db.Get("app") == nil { t.Error("Get(app) = nil, want the package of the state file") }
+    if err := db.Save(); err == nil { t.Error("Save() succeeded without a lock") }
+    if err := db.Close(); err != nil { t.Errorf("Close() = %v", err) }
+
+    // A missing directory is an empty state.
+    db, err = Read(filepath.Join(dir, "missing"))
+    if err != nil { t.Fatal(err) }
+    if len(db.List()) != 0 { t.Errorf("List() = %v, want no packages", db.List()) }
+    if _, err := os.Stat(filepath.Join(dir, "missing", LOCK_NAME)); !errors.Is(err, os.ErrNotExist) {
+        t.Error("Read() created the lock file")
+    }
+}